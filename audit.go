@@ -0,0 +1,145 @@
+package dalgo2sql
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dal-go/dalgo/dal"
+	"github.com/dal-go/record/update"
+)
+
+// AuditColumns names the audit columns of a recordset, see WithAudit.
+// Empty names are not maintained.
+type AuditColumns struct {
+	// CreatedAt and CreatedBy are set by inserts and never overwritten.
+	CreatedAt string
+	CreatedBy string
+	// UpdatedAt and UpdatedBy are set by inserts and updates.
+	UpdatedAt string
+	UpdatedBy string
+}
+
+// DefaultAuditColumns returns the conventional created_at, created_by,
+// updated_at and updated_by columns.
+func DefaultAuditColumns() AuditColumns {
+	return AuditColumns{
+		CreatedAt: "created_at",
+		CreatedBy: "created_by",
+		UpdatedAt: "updated_at",
+		UpdatedBy: "updated_by",
+	}
+}
+
+// WithAudit makes Insert, Set and Update fill the audit columns of a
+// recordset with the time of DbOptions.Clock and the identity of the
+// context, see WithIdentity. Audit values of records are ignored and updates
+// of the created columns are rejected. Without an identity in the context the
+// created-by and updated-by columns are not stamped, and the created-by
+// column of a record is only written by inserts.
+func WithAudit(columns AuditColumns) RecordsetOption {
+	return func(rs *Recordset) {
+		rs.audit = &columns
+	}
+}
+
+type identityContextKey struct{}
+
+// WithIdentity returns a context carrying the identity of the user or
+// service on whose behalf records are written, see WithAudit.
+func WithIdentity(ctx context.Context, identity string) context.Context {
+	return context.WithValue(ctx, identityContextKey{}, identity)
+}
+
+// IdentityFromContext returns the identity set by WithIdentity.
+func IdentityFromContext(ctx context.Context) (identity string, ok bool) {
+	if ctx == nil {
+		return "", false
+	}
+	identity, ok = ctx.Value(identityContextKey{}).(string)
+	return identity, ok && identity != ""
+}
+
+// stamp is a column that writes set regardless of record data.
+type stamp struct {
+	column string
+	// onInsert and onUpdate are the values set by inserts and updates,
+	// nil onUpdate leaves the column alone on updates.
+	onInsert, onUpdate any
+}
+
+// apply adds the audit columns to the stamps of s.
+func (a *AuditColumns) apply(ctx context.Context, now time.Time, s *statementScope) {
+	add := func(column string, onInsert, onUpdate any) {
+		if column != "" {
+			s.stamps = append(s.stamps, stamp{column: column, onInsert: onInsert, onUpdate: onUpdate})
+		}
+	}
+	add(a.CreatedAt, now, nil)
+	add(a.UpdatedAt, now, now)
+	if identity, ok := IdentityFromContext(ctx); ok {
+		add(a.CreatedBy, identity, nil)
+		add(a.UpdatedBy, identity, identity)
+	}
+	for _, column := range []string{a.CreatedAt, a.CreatedBy} {
+		if column != "" {
+			s.insertOnly = append(s.insertOnly, column)
+		}
+	}
+}
+
+// ignores reports whether writes of the operation ignore a record value of
+// the column, as they stamp the column or must leave it alone.
+func (s statementScope) ignores(o operation, column string) bool {
+	for _, st := range s.stamps {
+		if strings.EqualFold(st.column, column) {
+			return true
+		}
+	}
+	return o == updateOperation && s.isInsertOnly(column)
+}
+
+func (s statementScope) isInsertOnly(column string) bool {
+	return slices.ContainsFunc(s.insertOnly, func(c string) bool { return strings.EqualFold(c, column) })
+}
+
+// stampsOf returns the columns stamped by writes of the operation and their values.
+func (s statementScope) stampsOf(o operation) (columns []string, values []any) {
+	for _, st := range s.stamps {
+		value := st.onInsert
+		if o == updateOperation {
+			value = st.onUpdate
+		}
+		if value != nil {
+			columns = append(columns, st.column)
+			values = append(values, value)
+		}
+	}
+	return columns, values
+}
+
+// stampUpdates rejects updates of insert-only columns and appends the
+// update stamps, which take precedence over updates of the same columns.
+func (s statementScope) stampUpdates(updates []update.Update) ([]update.Update, error) {
+	for _, u := range updates {
+		column := u.FieldName()
+		if fieldPath := u.FieldPath(); len(fieldPath) > 0 {
+			column = fieldPath[0]
+		}
+		if s.isInsertOnly(column) {
+			return nil, fmt.Errorf("%w: update of insert-only column %s", dal.ErrNotSupported, column)
+		}
+	}
+	columns, values := s.stampsOf(updateOperation)
+	if len(columns) == 0 {
+		return updates, nil
+	}
+	stamped := make([]update.Update, len(updates), len(updates)+len(columns))
+	copy(stamped, updates)
+	for i, column := range columns {
+		stamped = append(stamped, update.ByFieldName(column, values[i]))
+	}
+	return stamped, nil
+}
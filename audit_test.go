@@ -0,0 +1,171 @@
+package dalgo2sql
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dal-go/dalgo/dal"
+	dalrecord "github.com/dal-go/record"
+	"github.com/dal-go/record/update"
+)
+
+func TestIdentityFromContext(t *testing.T) {
+	if _, ok := IdentityFromContext(context.Background()); ok {
+		t.Error("expected no identity in background context")
+	}
+	if _, ok := IdentityFromContext(WithIdentity(context.Background(), "")); ok {
+		t.Error("expected empty identity to be ignored")
+	}
+	if identity, ok := IdentityFromContext(WithIdentity(context.Background(), "alice")); !ok || identity != "alice" {
+		t.Errorf("got %q, %v; want alice", identity, ok)
+	}
+}
+
+func TestAudit_DollarPlaceholders(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeDatabase(t, sqlDB)
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	db := NewDatabase(sqlDB, newSchema(), DbOptions{
+		Placeholder: PlaceholderDollar,
+		Clock:       func() time.Time { return now },
+		Recordsets: map[string]*Recordset{
+			"users": NewRecordset("users", Table, []dal.FieldRef{dal.Field("id")}, WithAudit(DefaultAuditColumns())),
+		},
+	})
+	ctx := WithIdentity(context.Background(), "alice")
+	key := dalrecord.NewKeyWithID("users", "u1")
+
+	mock.ExpectExec(`INSERT INTO users\(id, Name, created_at, updated_at, created_by, updated_by\) VALUES \(\$1, \$2, \$3, \$4, \$5, \$6\)`).
+		WithArgs("u1", "John", now, now, "alice", "alice").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if err = db.Insert(ctx, dalrecord.NewRecordWithData(key, &struct{ Name string }{Name: "John"})); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+
+	mock.ExpectExec(`UPDATE users SET\s+Name = \$1,\s+updated_at = \$2,\s+updated_by = \$3\s+WHERE id = \$4`).
+		WithArgs("Jo", now, "alice", "u1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if err = db.Update(ctx, key, []update.Update{update.ByFieldName("Name", "Jo")}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAudit_SQLite(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	options := DbOptions{
+		Clock: func() time.Time { return now },
+		Recordsets: map[string]*Recordset{
+			"docs": NewRecordset("docs", Table, []dal.FieldRef{dal.Field("ID")}, WithAudit(DefaultAuditColumns())),
+		},
+	}
+	sqlDB := openTestSQLiteDB(t, `
+		CREATE TABLE docs (ID TEXT PRIMARY KEY, Title TEXT,
+			created_at TIMESTAMP, created_by TEXT, updated_at TIMESTAMP, updated_by TEXT);`)
+	db := dal.BackendOf(NewDatabase(sqlDB, newSchema(), options)).(*database)
+	alice := WithIdentity(context.Background(), "alice")
+	bob := WithIdentity(context.Background(), "bob")
+
+	type doc struct {
+		Title      string
+		Created_at time.Time
+		Created_by string
+	}
+	key := func(id string) *dalrecord.Key { return dalrecord.NewKeyWithID("docs", id) }
+	type audit struct {
+		createdAt, updatedAt time.Time
+		createdBy, updatedBy *string
+	}
+	read := func(t *testing.T, id string) (a audit) {
+		t.Helper()
+		err := sqlDB.QueryRow(`SELECT created_at, created_by, updated_at, updated_by FROM docs WHERE ID = ?`, id).
+			Scan(&a.createdAt, &a.createdBy, &a.updatedAt, &a.updatedBy)
+		if err != nil {
+			t.Fatalf("SELECT %s: %v", id, err)
+		}
+		return a
+	}
+	str := func(s *string) string {
+		if s == nil {
+			return "<nil>"
+		}
+		return *s
+	}
+	created := now
+
+	t.Run("Insert", func(t *testing.T) {
+		record := &doc{Title: "draft", Created_at: now.Add(-time.Hour), Created_by: "mallory"}
+		if err := db.Insert(alice, dalrecord.NewRecordWithData(key("d1"), record)); err != nil {
+			t.Fatalf("Insert: %v", err)
+		}
+		a := read(t, "d1")
+		if !a.createdAt.Equal(now) || !a.updatedAt.Equal(now) {
+			t.Errorf("created_at = %v, updated_at = %v; want %v", a.createdAt, a.updatedAt, now)
+		}
+		if str(a.createdBy) != "alice" || str(a.updatedBy) != "alice" {
+			t.Errorf("created_by = %s, updated_by = %s; want alice", str(a.createdBy), str(a.updatedBy))
+		}
+	})
+
+	now = now.Add(time.Minute)
+
+	t.Run("Set", func(t *testing.T) {
+		record := &doc{Title: "final", Created_at: now, Created_by: "mallory"}
+		if err := db.Set(bob, dalrecord.NewRecordWithData(key("d1"), record)); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+		a := read(t, "d1")
+		if !a.createdAt.Equal(created) || str(a.createdBy) != "alice" {
+			t.Errorf("created = %v by %s, want the insert-only columns to be kept", a.createdAt, str(a.createdBy))
+		}
+		if !a.updatedAt.Equal(now) || str(a.updatedBy) != "bob" {
+			t.Errorf("updated = %v by %s, want %v by bob", a.updatedAt, str(a.updatedBy), now)
+		}
+	})
+
+	now = now.Add(time.Minute)
+
+	t.Run("Update", func(t *testing.T) {
+		if err := db.Update(alice, key("d1"), []update.Update{update.ByFieldName("Title", "v3")}); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		if a := read(t, "d1"); !a.updatedAt.Equal(now) || str(a.updatedBy) != "alice" {
+			t.Errorf("updated = %v by %s, want %v by alice", a.updatedAt, str(a.updatedBy), now)
+		}
+		everything := dal.Comparison{Operator: dal.GreaterThen, Left: dal.Field("Title"), Right: dal.Constant{Value: ""}}
+		if n, err := db.UpdateWhere(bob, "docs", everything, []update.Update{update.ByFieldName("updated_by", "mallory")}); err != nil || n != 1 {
+			t.Fatalf("UpdateWhere = %d, %v; want 1", n, err)
+		}
+		if a := read(t, "d1"); str(a.updatedBy) != "bob" {
+			t.Errorf("updated_by = %s, want the stamp to take precedence", str(a.updatedBy))
+		}
+		err := db.Update(alice, key("d1"), []update.Update{update.ByFieldName("created_at", now)})
+		if !errors.Is(err, dal.ErrNotSupported) {
+			t.Errorf("expected ErrNotSupported for update of created_at, got %v", err)
+		}
+	})
+
+	t.Run("no_identity", func(t *testing.T) {
+		ctx := context.Background()
+		if err := db.Insert(ctx, dalrecord.NewRecordWithData(key("d2"), &doc{Title: "import", Created_by: "migration"})); err != nil {
+			t.Fatalf("Insert: %v", err)
+		}
+		if a := read(t, "d2"); str(a.createdBy) != "migration" || a.updatedBy != nil {
+			t.Errorf("created_by = %s, updated_by = %s; want the record's creator and no updater", str(a.createdBy), str(a.updatedBy))
+		}
+		if err := db.Set(ctx, dalrecord.NewRecordWithData(key("d2"), &doc{Title: "imported", Created_by: "someone"})); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+		if a := read(t, "d2"); str(a.createdBy) != "migration" {
+			t.Errorf("created_by = %s, want migration", str(a.createdBy))
+		}
+	})
+}
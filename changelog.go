@@ -0,0 +1,377 @@
+package dalgo2sql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dal-go/record"
+)
+
+// DefaultChangelogTable is used when DbOptions.ChangelogTable is not set.
+const DefaultChangelogTable = "dalgo_changelog"
+
+// WithChangelog logs every change of a recordset's rows in
+// DbOptions.ChangelogTable, in the transaction of the change, for
+// ChangeFeed.Watch. The same write paths are covered as by WithHistory.
+//
+// Each changed row gets an entry with the recordset, the key, the operation
+// and the columns of the row after the change as a JSON object, or NULL if
+// the row is gone or not visible to the context, e.g. deleted. The table is
+// expected to look like:
+//
+//	CREATE TABLE dalgo_changelog (
+//		id INTEGER PRIMARY KEY AUTOINCREMENT, -- BIGSERIAL, BIGINT AUTO_INCREMENT
+//		recordset TEXT NOT NULL,
+//		record_key TEXT NOT NULL,
+//		operation TEXT NOT NULL,
+//		data TEXT,
+//		changed_at TIMESTAMP NOT NULL
+//	)
+//
+// The changelog table is scoped by Tenancy like the recordsets,
+// unless it is listed in Tenancy.Shared. It is never pruned by the adapter.
+func WithChangelog() RecordsetOption {
+	return func(rs *Recordset) {
+		rs.changelog = true
+	}
+}
+
+// ChangeEvent is a change of a row logged by WithChangelog.
+type ChangeEvent struct {
+	// Position orders the changes. Watching from it resumes after the change.
+	Position  int64
+	Recordset string
+	Key       string
+	Operation Operation
+	// Data are the columns of the row after the change, nil if there is no row.
+	Data      map[string]any
+	ChangedAt time.Time
+}
+
+// ChangeFeed is implemented by the database of this adapter, see dal.BackendOf.
+type ChangeFeed interface {
+	// Watch streams the changes of a collection, or of all collections if
+	// it is empty, that follow a position. Position 0 starts from the first
+	// logged change.
+	Watch(ctx context.Context, collection string, from int64, options ...WatchOption) (*ChangeStream, error)
+	// LatestChangePosition returns the position of the last logged change,
+	// e.g. to watch the changes that follow a snapshot.
+	LatestChangePosition(ctx context.Context) (int64, error)
+}
+
+var _ ChangeFeed = (*database)(nil)
+
+// DefaultWatchBatchSize is used when WatchBatchSize is not set.
+const DefaultWatchBatchSize = 100
+
+// DefaultWatchGapTimeout is used when WatchGapTimeout is not set.
+const DefaultWatchGapTimeout = 10 * time.Second
+
+type watchOptions struct {
+	batchSize    int
+	pollInterval time.Duration
+	gapTimeout   time.Duration
+}
+
+// WatchOption customizes ChangeFeed.Watch.
+type WatchOption func(o *watchOptions)
+
+// WatchBatchSize sets the maximum number of changes read at once,
+// which bounds how far a stream reads ahead of its consumer.
+func WatchBatchSize(n int) WatchOption {
+	return func(o *watchOptions) {
+		o.batchSize = n
+	}
+}
+
+// WatchPollInterval sets how long a stream that caught up waits before
+// looking for new changes. Defaults to a second.
+func WatchPollInterval(d time.Duration) WatchOption {
+	return func(o *watchOptions) {
+		o.pollInterval = d
+	}
+}
+
+// WatchGapTimeout sets how long a stream waits at a gap in positions for
+// the change of a transaction that has not committed yet before it skips
+// the gap, which is never filled if the transaction is rolled back.
+func WatchGapTimeout(d time.Duration) WatchOption {
+	return func(o *watchOptions) {
+		o.gapTimeout = d
+	}
+}
+
+// ChangeStream reads changes of a ChangeFeed as its consumer asks for them:
+// changes are read a batch at a time and only once the previous batch has
+// been consumed, so a slow consumer slows down the polling rather than
+// accumulating changes in memory.
+//
+// Positions are assigned when changes are logged, so with concurrent
+// writers a transaction may commit a change after a change with a greater
+// position. A stream stops at the first gap in positions until the gap is
+// filled or has lasted for WatchGapTimeout, so a change that commits later
+// than that is not streamed. Gaps before changes logged longer than
+// WatchGapTimeout ago are skipped right away. Consumers that can't tolerate this should
+// serialize the writes of watched recordsets, e.g. with row locks on a
+// common row.
+type ChangeStream struct {
+	ctx        context.Context
+	dtb        *database
+	collection string
+	options    watchOptions
+	position   int64
+	// scanned is the position the changelog has been read up to,
+	// including the changes of other collections.
+	scanned  int64
+	buffer   []ChangeEvent
+	caughtUp bool
+	// gaps holds when the gaps in positions were first seen,
+	// by the position that follows them.
+	gaps map[int64]time.Time
+}
+
+func (dtb *database) Watch(ctx context.Context, collection string, from int64, options ...WatchOption) (*ChangeStream, error) {
+	s := &ChangeStream{
+		ctx:        ctx,
+		dtb:        dtb,
+		collection: collection,
+		options:    watchOptions{batchSize: DefaultWatchBatchSize, pollInterval: time.Second, gapTimeout: DefaultWatchGapTimeout},
+		position:   from,
+		scanned:    from,
+		gaps:       make(map[int64]time.Time),
+	}
+	for _, o := range options {
+		o(&s.options)
+	}
+	if s.options.batchSize <= 0 {
+		s.options.batchSize = DefaultWatchBatchSize
+	}
+	if s.options.pollInterval <= 0 {
+		s.options.pollInterval = time.Second
+	}
+	if s.options.gapTimeout <= 0 {
+		s.options.gapTimeout = DefaultWatchGapTimeout
+	}
+	// The first poll reports e.g. a missing changelog table right away.
+	if err := s.poll(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Next returns the next change, waiting for one to be logged if needed,
+// until the context of Watch is done.
+func (s *ChangeStream) Next() (ChangeEvent, error) {
+	for len(s.buffer) == 0 {
+		if err := s.ctx.Err(); err != nil {
+			return ChangeEvent{}, err
+		}
+		if s.caughtUp {
+			timer := time.NewTimer(s.options.pollInterval)
+			select {
+			case <-s.ctx.Done():
+				timer.Stop()
+				return ChangeEvent{}, s.ctx.Err()
+			case <-timer.C:
+			}
+		}
+		if err := s.poll(); err != nil {
+			return ChangeEvent{}, err
+		}
+	}
+	event := s.buffer[0]
+	s.buffer = s.buffer[1:]
+	s.position = event.Position
+	return event, nil
+}
+
+// Position returns the position of the last change returned by Next,
+// or the position the stream started from, to resume watching later.
+func (s *ChangeStream) Position() int64 {
+	return s.position
+}
+
+func (s *ChangeStream) poll() error {
+	to, n, err := s.scanPositions()
+	if err != nil {
+		return err
+	}
+	events, err := readChanges(s.ctx, s.dtb.options, s.dtb.db.QueryContext, s.collection, s.scanned, to)
+	if err != nil {
+		return err
+	}
+	s.buffer = append(s.buffer, events...)
+	s.scanned = to
+	s.caughtUp = n < s.options.batchSize
+	return nil
+}
+
+// scanPositions reads up to a batch of the positions that follow the
+// scanned one and returns the last of them that precedes the first gap
+// that has not lasted for the gap timeout, with the number of positions up to it.
+// A gap before a change logged longer than the gap timeout ago is not
+// waited for, e.g. when replaying a backlog.
+func (s *ChangeStream) scanPositions() (to int64, n int, err error) {
+	options := s.dtb.options
+	table := options.changelogTable()
+	scope, err := options.scope(s.ctx, table)
+	if err != nil {
+		return 0, 0, err
+	}
+	// The conditions of the scope are left out, as positions are shared by tenants.
+	text := options.Placeholder.rewritePlaceholders(fmt.Sprintf(
+		"SELECT id, changed_at FROM %s WHERE id > ? ORDER BY id LIMIT %d", scope.table, s.options.batchSize))
+	rows, err := options.traceQuery(table, s.dtb.db.QueryContext)(s.ctx, text, s.scanned)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read change positions: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	now := options.now()
+	to = s.scanned
+	previous, held := s.scanned, false
+	for rows.Next() {
+		var position int64
+		var changedAt time.Time
+		if err = rows.Scan(&position, &changedAt); err != nil {
+			return 0, 0, fmt.Errorf("failed to read change positions: %w", err)
+		}
+		if position > previous+1 && now.Sub(changedAt) < s.options.gapTimeout {
+			seen, ok := s.gaps[position]
+			if !ok {
+				seen = now
+				s.gaps[position] = now
+			}
+			held = held || now.Sub(seen) < s.options.gapTimeout
+		}
+		previous = position
+		if !held {
+			to = position
+			n++
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return 0, 0, err
+	}
+	for position := range s.gaps {
+		if position <= to {
+			delete(s.gaps, position)
+		}
+	}
+	return to, n, nil
+}
+
+func (dtb *database) LatestChangePosition(ctx context.Context) (int64, error) {
+	options := dtb.options
+	table := options.changelogTable()
+	scope, err := options.scope(ctx, table)
+	if err != nil {
+		return 0, err
+	}
+	text := fmt.Sprintf("SELECT MAX(id) FROM %s", scope.table)
+	if len(scope.conditions) > 0 {
+		text += " WHERE " + strings.Join(scope.conditions, " AND ")
+	}
+	rows, err := options.traceQuery(table, dtb.db.QueryContext)(ctx, options.Placeholder.rewritePlaceholders(text), scope.args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read latest change position: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	var position sql.NullInt64
+	if rows.Next() {
+		if err = rows.Scan(&position); err != nil {
+			return 0, fmt.Errorf("failed to read latest change position: %w", err)
+		}
+	}
+	return position.Int64, rows.Err()
+}
+
+func (o DbOptions) changelogTable() string {
+	if o.ChangelogTable == "" {
+		return DefaultChangelogTable
+	}
+	return o.ChangelogTable
+}
+
+// logsChanges reports whether changes of any of the recordsets are logged.
+func (o DbOptions) logsChanges(recordsets []string) bool {
+	return slices.ContainsFunc(recordsets, func(name string) bool {
+		rs := o.Recordsets[name]
+		return rs != nil && rs.changelog
+	})
+}
+
+func insertChange(ctx context.Context, options DbOptions, exec statementExecutor, key *record.Key, data []byte) error {
+	table := options.changelogTable()
+	scope, err := options.scope(ctx, table)
+	if err != nil {
+		return err
+	}
+	columns := append([]string{"recordset", "record_key", "operation", "data", "changed_at"}, scope.columns...)
+	var image any
+	if data != nil {
+		image = string(data)
+	}
+	recordKey, err := historyKey(options, key)
+	if err != nil {
+		return err
+	}
+	args := append([]any{key.Collection(), recordKey, string(operationFromContext(ctx)), image, options.now()},
+		scope.values...)
+	text := options.cachedSQL(
+		func() string { return statementShape("changelog", scope.table, strings.Join(columns, ",")) },
+		func() string {
+			return options.Placeholder.rewritePlaceholders(fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+				scope.table, strings.Join(columns, ", "), strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")))
+		})
+	if _, err = options.traceStatement(table, exec)(ctx, text, args...); err != nil {
+		return fmt.Errorf("failed to log change of %v: %w", key, err)
+	}
+	return nil
+}
+
+// readChanges reads the changes of a collection, or of all collections
+// if it is empty, that follow a position up to another one.
+func readChanges(ctx context.Context, options DbOptions, query executeQueryFunc, collection string, from, to int64) ([]ChangeEvent, error) {
+	if to <= from {
+		return nil, nil
+	}
+	table := options.changelogTable()
+	scope, err := options.scope(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+	condition := "id > ? AND id <= ?"
+	args := []any{from, to}
+	if collection != "" {
+		condition += " AND recordset = ?"
+		args = append(args, collection)
+	}
+	text := options.Placeholder.rewritePlaceholders(fmt.Sprintf(
+		"SELECT id, recordset, record_key, operation, data, changed_at FROM %s WHERE %s%s ORDER BY id",
+		scope.table, condition, scope.where()))
+	rows, err := options.traceQuery(table, query)(ctx, text, append(args, scope.args...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read changes: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	var events []ChangeEvent
+	for rows.Next() {
+		var event ChangeEvent
+		var data sql.NullString
+		if err = rows.Scan(&event.Position, &event.Recordset, &event.Key, &event.Operation, &data, &event.ChangedAt); err != nil {
+			return nil, fmt.Errorf("failed to read changes: %w", err)
+		}
+		if data.Valid {
+			if err = json.Unmarshal([]byte(data.String), &event.Data); err != nil {
+				return nil, fmt.Errorf("failed to decode change %d: %w", event.Position, err)
+			}
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,240 @@
+package dalgo2sql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dal-go/dalgo/dal"
+	dalrecord "github.com/dal-go/record"
+	"github.com/dal-go/record/update"
+)
+
+func TestChangelog_SQLite(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	options := DbOptions{
+		Clock: func() time.Time { return now },
+		Recordsets: map[string]*Recordset{
+			"items":  NewRecordset("items", Table, []dal.FieldRef{dal.Field("ID")}, WithChangelog()),
+			"orders": NewRecordset("orders", Table, []dal.FieldRef{dal.Field("ID")}, WithChangelog(), WithHistory()),
+			"logs":   NewRecordset("logs", Table, []dal.FieldRef{dal.Field("ID")}),
+		},
+	}
+	sqlDB := openTestSQLiteDB(t, `
+		CREATE TABLE items (ID TEXT PRIMARY KEY, Name TEXT);
+		CREATE TABLE orders (ID TEXT PRIMARY KEY, Name TEXT);
+		CREATE TABLE logs (ID TEXT PRIMARY KEY, Name TEXT);
+		CREATE TABLE dalgo_history (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			recordset TEXT NOT NULL,
+			record_key TEXT NOT NULL,
+			operation TEXT NOT NULL,
+			before_image TEXT,
+			after_image TEXT,
+			actor TEXT,
+			changed_at TIMESTAMP NOT NULL
+		);
+		CREATE TABLE dalgo_changelog (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			recordset TEXT NOT NULL,
+			record_key TEXT NOT NULL,
+			operation TEXT NOT NULL,
+			data TEXT,
+			changed_at TIMESTAMP NOT NULL
+		);`)
+	db := dal.BackendOf(NewDatabase(sqlDB, newSchema(), options)).(*database)
+	ctx := context.Background()
+
+	type item struct {
+		Name string
+	}
+	key := func(collection, id string) *dalrecord.Key { return dalrecord.NewKeyWithID(collection, id) }
+	if err := db.Insert(ctx, dalrecord.NewRecordWithData(key("items", "i1"), &item{Name: "one"})); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	if err := db.Insert(ctx, dalrecord.NewRecordWithData(key("logs", "l1"), &item{Name: "log"})); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	if err := db.Set(ctx, dalrecord.NewRecordWithData(key("orders", "o1"), &item{Name: "order"})); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := db.Update(ctx, key("items", "i1"), []update.Update{update.ByFieldName("Name", "two")}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if err := db.Delete(ctx, key("items", "i1")); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	next := func(t *testing.T, s *ChangeStream) ChangeEvent {
+		t.Helper()
+		event, err := s.Next()
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		return event
+	}
+
+	t.Run("Watch", func(t *testing.T) {
+		s, err := db.Watch(ctx, "items", 0, WatchBatchSize(2))
+		if err != nil {
+			t.Fatalf("Watch: %v", err)
+		}
+		want := []struct {
+			op   Operation
+			data map[string]any
+		}{
+			{OpInsert, map[string]any{"ID": "i1", "Name": "one"}},
+			{OpUpdate, map[string]any{"ID": "i1", "Name": "two"}},
+			{OpDelete, nil},
+		}
+		var position int64
+		for i, w := range want {
+			event := next(t, s)
+			if event.Recordset != "items" || event.Key != "i1" || event.Operation != w.op || !reflect.DeepEqual(event.Data, w.data) {
+				t.Errorf("event #%d = %+v, want %s of items/i1 with %v", i, event, w.op, w.data)
+			}
+			if event.Position <= position || s.Position() != event.Position || !event.ChangedAt.Equal(now) {
+				t.Errorf("event #%d at position %d changed at %v, stream at %d", i, event.Position, event.ChangedAt, s.Position())
+			}
+			position = event.Position
+		}
+	})
+
+	t.Run("all_collections", func(t *testing.T) {
+		s, err := db.Watch(ctx, "", 0)
+		if err != nil {
+			t.Fatalf("Watch: %v", err)
+		}
+		var recordsets []string
+		for range 4 {
+			recordsets = append(recordsets, next(t, s).Recordset)
+		}
+		if !reflect.DeepEqual(recordsets, []string{"items", "orders", "items", "items"}) {
+			t.Errorf("changes of %v", recordsets)
+		}
+		var n int
+		if err = sqlDB.QueryRow(`SELECT COUNT(*) FROM dalgo_history WHERE recordset = 'orders'`).Scan(&n); err != nil || n != 1 {
+			t.Errorf("%d history entries, %v; want the changelog and the history to be both written", n, err)
+		}
+	})
+
+	t.Run("resume", func(t *testing.T) {
+		latest, err := db.LatestChangePosition(ctx)
+		if err != nil {
+			t.Fatalf("LatestChangePosition: %v", err)
+		}
+		watchCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		s, err := db.Watch(watchCtx, "items", latest, WatchPollInterval(time.Millisecond))
+		if err != nil {
+			t.Fatalf("Watch: %v", err)
+		}
+		if err = db.Insert(ctx, dalrecord.NewRecordWithData(key("items", "i2"), &item{Name: "new"})); err != nil {
+			t.Fatalf("Insert: %v", err)
+		}
+		if event := next(t, s); event.Key != "i2" || event.Position <= latest {
+			t.Errorf("event = %+v, want the insert of i2 after position %d", event, latest)
+		}
+		cancel()
+		if _, err = s.Next(); !errors.Is(err, context.Canceled) {
+			t.Errorf("Next = %v, want context.Canceled", err)
+		}
+	})
+
+	t.Run("rolled_back", func(t *testing.T) {
+		latest, err := db.LatestChangePosition(ctx)
+		if err != nil {
+			t.Fatalf("LatestChangePosition: %v", err)
+		}
+		err = db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx dal.ReadwriteTransaction) error {
+			if err := tx.Set(ctx, dalrecord.NewRecordWithData(key("items", "i3"), &item{Name: "tx"})); err != nil {
+				return err
+			}
+			return context.Canceled
+		})
+		if err == nil {
+			t.Fatal("expected the transaction to fail")
+		}
+		if position, err := db.LatestChangePosition(ctx); err != nil || position != latest {
+			t.Errorf("LatestChangePosition = %d, %v; want %d after rollback", position, err, latest)
+		}
+	})
+
+	t.Run("late_commit", func(t *testing.T) {
+		latest, err := db.LatestChangePosition(ctx)
+		if err != nil {
+			t.Fatalf("LatestChangePosition: %v", err)
+		}
+		// Changes are logged directly to choose the order their positions commit in.
+		logChange := func(t *testing.T, position int64) {
+			t.Helper()
+			if _, err := sqlDB.Exec(`INSERT INTO dalgo_changelog (id, recordset, record_key, operation, data, changed_at) VALUES (?, 'items', ?, 'delete', NULL, ?)`,
+				position, fmt.Sprintf("late%d", position), now); err != nil {
+				t.Fatalf("INSERT: %v", err)
+			}
+		}
+		watchCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		logChange(t, latest+2)
+		s, err := db.Watch(watchCtx, "items", latest, WatchPollInterval(time.Millisecond), WatchGapTimeout(time.Minute))
+		if err != nil {
+			t.Fatalf("Watch: %v", err)
+		}
+		if len(s.buffer) != 0 {
+			t.Errorf("read %+v, want the stream to stop at the gap before position %d", s.buffer, latest+2)
+		}
+		logChange(t, latest+1)
+		for _, want := range []int64{latest + 1, latest + 2} {
+			if event := next(t, s); event.Position != want {
+				t.Errorf("event at position %d, want %d", event.Position, want)
+			}
+		}
+
+		logChange(t, latest+4)
+		if err = s.poll(); err != nil {
+			t.Fatalf("poll: %v", err)
+		}
+		if len(s.buffer) != 0 {
+			t.Errorf("read %+v, want the stream to stop at the gap before position %d", s.buffer, latest+4)
+		}
+		now = now.Add(time.Minute)
+		if event := next(t, s); event.Position != latest+4 {
+			t.Errorf("event at position %d, want the gap before %d skipped once timed out", event.Position, latest+4)
+		}
+	})
+
+	t.Run("old_gaps", func(t *testing.T) {
+		latest, err := db.LatestChangePosition(ctx)
+		if err != nil {
+			t.Fatalf("LatestChangePosition: %v", err)
+		}
+		// A backlog of changes logged long ago, with the gaps of rolled back transactions.
+		for _, position := range []int64{latest + 2, latest + 3, latest + 5} {
+			if _, err := sqlDB.Exec(`INSERT INTO dalgo_changelog (id, recordset, record_key, operation, data, changed_at) VALUES (?, 'items', ?, 'delete', NULL, ?)`,
+				position, fmt.Sprintf("old%d", position), now.Add(-time.Hour)); err != nil {
+				t.Fatalf("INSERT: %v", err)
+			}
+		}
+		s, err := db.Watch(ctx, "items", latest, WatchGapTimeout(time.Minute))
+		if err != nil {
+			t.Fatalf("Watch: %v", err)
+		}
+		var positions []int64
+		for _, event := range s.buffer {
+			positions = append(positions, event.Position)
+		}
+		if want := []int64{latest + 2, latest + 3, latest + 5}; !reflect.DeepEqual(positions, want) {
+			t.Errorf("first poll read positions %v, want %v without waiting at old gaps", positions, want)
+		}
+	})
+}
+
+func TestChangelog_MissingTable(t *testing.T) {
+	db := dal.BackendOf(NewDatabase(openTestSQLiteDB(t, `CREATE TABLE items (ID TEXT PRIMARY KEY);`), newSchema(), DbOptions{})).(*database)
+	if _, err := db.Watch(context.Background(), "items", 0); err == nil {
+		t.Error("expected Watch to report the missing changelog table")
+	}
+}
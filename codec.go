@@ -0,0 +1,263 @@
+package dalgo2sql
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ValueCodec converts values of a custom Go type to SQL arguments and
+// scanned column values back to Go values.
+type ValueCodec interface {
+	// Encode converts a Go value to a value accepted by the database driver.
+	Encode(value any) (any, error)
+	// Decode converts a value scanned by the driver into a value assignable
+	// to target. Target is the type of the destination struct field, or the
+	// empty interface type when reading into maps and recordsets.
+	Decode(value any, target reflect.Type) (any, error)
+}
+
+// Codecs is a registry of value codecs set as DbOptions.Codecs.
+//
+// Codecs registered for a Go type apply to writes of values of that type and
+// to reads into struct fields of that type. Codecs registered for a database
+// column type (as reported by sql.ColumnType.DatabaseTypeName, e.g. "UUID")
+// apply to reads of such columns by Get, GetMulti and query readers.
+type Codecs struct {
+	byType         map[reflect.Type]ValueCodec
+	byColumnType   map[string]ValueCodec
+	textMarshalers bool
+}
+
+// NewCodecs creates an empty codecs registry
+func NewCodecs() *Codecs {
+	return &Codecs{
+		byType:       make(map[reflect.Type]ValueCodec),
+		byColumnType: make(map[string]ValueCodec),
+	}
+}
+
+// ForType registers a codec for values of the given Go type, e.g.
+//
+//	codecs.ForType(reflect.TypeOf(time.Duration(0)), dalgo2sql.DurationCodec{})
+func (c *Codecs) ForType(t reflect.Type, codec ValueCodec) *Codecs {
+	c.byType[t] = codec
+	return c
+}
+
+// ForColumnType registers a codec for reads of columns of the given database type.
+func (c *Codecs) ForColumnType(dbTypeName string, codec ValueCodec) *Codecs {
+	c.byColumnType[strings.ToUpper(dbTypeName)] = codec
+	return c
+}
+
+// WithTextMarshalers makes types that implement both encoding.TextMarshaler
+// and encoding.TextUnmarshaler (UUIDs, decimals, enums, …) use TextCodec
+// unless a codec is registered for the type explicitly.
+func (c *Codecs) WithTextMarshalers() *Codecs {
+	c.textMarshalers = true
+	return c
+}
+
+// codecForType returns the codec registered for a Go type or nil
+func (c *Codecs) codecForType(t reflect.Type) ValueCodec {
+	if c == nil || t == nil {
+		return nil
+	}
+	if codec, ok := c.byType[t]; ok {
+		return codec
+	}
+	if c.textMarshalers && t.Implements(textMarshalerType) && reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return TextCodec{}
+	}
+	return nil
+}
+
+// codecForColumnType returns the codec registered for a database column type or nil
+func (c *Codecs) codecForColumnType(dbTypeName string) ValueCodec {
+	if c == nil || dbTypeName == "" {
+		return nil
+	}
+	return c.byColumnType[strings.ToUpper(dbTypeName)]
+}
+
+func (c *Codecs) hasColumnTypeCodecs() bool {
+	return c != nil && len(c.byColumnType) > 0
+}
+
+var (
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	anyType             = reflect.TypeOf((*any)(nil)).Elem()
+)
+
+// TextCodec stores encoding.TextMarshaler values as text
+type TextCodec struct{}
+
+func (TextCodec) Encode(value any) (any, error) {
+	m, ok := value.(encoding.TextMarshaler)
+	if !ok {
+		return nil, fmt.Errorf("value of type %T does not implement encoding.TextMarshaler", value)
+	}
+	b, err := m.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (TextCodec) Decode(value any, target reflect.Type) (any, error) {
+	text, err := asText(value)
+	if err != nil {
+		return nil, err
+	}
+	if target == anyType {
+		return text, nil
+	}
+	v := reflect.New(target)
+	u, ok := v.Interface().(encoding.TextUnmarshaler)
+	if !ok {
+		return nil, fmt.Errorf("type %v does not implement encoding.TextUnmarshaler", target)
+	}
+	if err = u.UnmarshalText([]byte(text)); err != nil {
+		return nil, err
+	}
+	return v.Elem().Interface(), nil
+}
+
+// TimeUTCCodec normalizes time.Time values to UTC on write and on read.
+// Text values are parsed as RFC 3339.
+type TimeUTCCodec struct{}
+
+func (TimeUTCCodec) Encode(value any) (any, error) {
+	t, ok := value.(time.Time)
+	if !ok {
+		return nil, fmt.Errorf("expected time.Time, got %T", value)
+	}
+	return t.UTC(), nil
+}
+
+func (TimeUTCCodec) Decode(value any, _ reflect.Type) (any, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v.UTC(), nil
+	case string, []byte:
+		text, _ := asText(v)
+		t, err := time.Parse(time.RFC3339Nano, text)
+		if err != nil {
+			return nil, err
+		}
+		return t.UTC(), nil
+	default:
+		return nil, fmt.Errorf("can not decode %T as time.Time", value)
+	}
+}
+
+// UnixTimeCodec stores time.Time values as integer Unix time in the given
+// Unit: time.Second (default), time.Millisecond, time.Microsecond or time.Nanosecond.
+// Nanoseconds only cover the years 1678 to 2262.
+type UnixTimeCodec struct {
+	Unit time.Duration
+}
+
+func (c UnixTimeCodec) unit() time.Duration {
+	if c.Unit <= 0 {
+		return time.Second
+	}
+	return c.Unit
+}
+
+func (c UnixTimeCodec) Encode(value any) (any, error) {
+	t, ok := value.(time.Time)
+	if !ok {
+		return nil, fmt.Errorf("expected time.Time, got %T", value)
+	}
+	if t.IsZero() {
+		return nil, nil
+	}
+	switch unit := c.unit(); unit {
+	case time.Second:
+		return t.Unix(), nil
+	case time.Millisecond:
+		return t.UnixMilli(), nil
+	case time.Microsecond:
+		return t.UnixMicro(), nil
+	default:
+		return t.UnixNano() / int64(unit), nil
+	}
+}
+
+func (c UnixTimeCodec) Decode(value any, _ reflect.Type) (any, error) {
+	n, err := asInt64(value)
+	if err != nil {
+		return nil, err
+	}
+	switch unit := c.unit(); unit {
+	case time.Second:
+		return time.Unix(n, 0).UTC(), nil
+	case time.Millisecond:
+		return time.UnixMilli(n).UTC(), nil
+	case time.Microsecond:
+		return time.UnixMicro(n).UTC(), nil
+	default:
+		return time.Unix(0, n*int64(unit)).UTC(), nil
+	}
+}
+
+// DurationCodec stores time.Duration values (and named types based on
+// int64) as an integer number of nanoseconds.
+type DurationCodec struct{}
+
+func (DurationCodec) Encode(value any) (any, error) {
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Int64 {
+		return nil, fmt.Errorf("expected time.Duration, got %T", value)
+	}
+	return v.Int(), nil
+}
+
+func (DurationCodec) Decode(value any, target reflect.Type) (any, error) {
+	n, err := asInt64(value)
+	if err != nil {
+		return nil, err
+	}
+	if target == anyType {
+		return time.Duration(n), nil
+	}
+	return reflect.ValueOf(n).Convert(target).Interface(), nil
+}
+
+func asText(value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	case fmt.Stringer:
+		return v.String(), nil
+	default:
+		return "", fmt.Errorf("can not decode %T as text", value)
+	}
+}
+
+func asInt64(value any) (int64, error) {
+	switch v := value.(type) {
+	case int64:
+		return v, nil
+	case int:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case float64:
+		return int64(v), nil
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	case []byte:
+		return strconv.ParseInt(string(v), 10, 64)
+	default:
+		return 0, fmt.Errorf("can not decode %T as integer", value)
+	}
+}
@@ -0,0 +1,493 @@
+package dalgo2sql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dal-go/dalgo/dal"
+	dalrecord "github.com/dal-go/record"
+	"github.com/dal-go/record/update"
+)
+
+// testStatus is an enum stored as text through encoding.TextMarshaler.
+type testStatus int
+
+const (
+	testStatusUnknown testStatus = iota
+	testStatusActive
+	testStatusBlocked
+)
+
+var testStatusNames = []string{"unknown", "active", "blocked"}
+
+func (s testStatus) MarshalText() ([]byte, error) {
+	if int(s) < 0 || int(s) >= len(testStatusNames) {
+		return nil, fmt.Errorf("invalid status %d", s)
+	}
+	return []byte(testStatusNames[s]), nil
+}
+
+func (s *testStatus) UnmarshalText(text []byte) error {
+	for i, name := range testStatusNames {
+		if name == string(text) {
+			*s = testStatus(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown status %q", text)
+}
+
+// upperCodec decodes text columns to upper case, to make its use observable.
+type upperCodec struct{}
+
+func (upperCodec) Encode(value any) (any, error) {
+	return value, nil
+}
+
+func (upperCodec) Decode(value any, _ reflect.Type) (any, error) {
+	text, err := asText(value)
+	if err != nil {
+		return nil, err
+	}
+	return strings.ToUpper(text), nil
+}
+
+type codecAccount struct {
+	Name     string
+	Status   testStatus
+	Previous *testStatus
+	Timeout  time.Duration
+	SeenAt   time.Time
+}
+
+func newTestCodecs() *Codecs {
+	return NewCodecs().
+		WithTextMarshalers().
+		ForType(reflect.TypeOf(time.Duration(0)), DurationCodec{}).
+		ForType(reflect.TypeOf(time.Time{}), UnixTimeCodec{Unit: time.Millisecond})
+}
+
+func TestCodecs_codecForType(t *testing.T) {
+	t.Run("nil_registry", func(t *testing.T) {
+		var c *Codecs
+		if codec := c.codecForType(reflect.TypeOf(testStatus(0))); codec != nil {
+			t.Errorf("expected no codec, got %T", codec)
+		}
+		if c.hasColumnTypeCodecs() {
+			t.Error("expected no column type codecs")
+		}
+	})
+	t.Run("text_marshalers_disabled", func(t *testing.T) {
+		if codec := NewCodecs().codecForType(reflect.TypeOf(testStatus(0))); codec != nil {
+			t.Errorf("expected no codec, got %T", codec)
+		}
+	})
+	t.Run("text_marshalers_enabled", func(t *testing.T) {
+		codec := NewCodecs().WithTextMarshalers().codecForType(reflect.TypeOf(testStatus(0)))
+		if _, ok := codec.(TextCodec); !ok {
+			t.Errorf("expected TextCodec, got %T", codec)
+		}
+	})
+	t.Run("explicit_wins_over_text_marshaler", func(t *testing.T) {
+		c := NewCodecs().WithTextMarshalers().ForType(reflect.TypeOf(testStatus(0)), upperCodec{})
+		if _, ok := c.codecForType(reflect.TypeOf(testStatus(0))).(upperCodec); !ok {
+			t.Error("expected explicitly registered codec")
+		}
+	})
+	t.Run("column_type_is_case_insensitive", func(t *testing.T) {
+		c := NewCodecs().ForColumnType("uuid", upperCodec{})
+		if c.codecForColumnType("UUID") == nil {
+			t.Error("expected codec for UUID")
+		}
+		if c.codecForColumnType("") != nil {
+			t.Error("expected no codec for an empty type name")
+		}
+	})
+}
+
+func TestBuiltInCodecs(t *testing.T) {
+	moment := time.Date(2024, 5, 6, 7, 8, 9, 123_000_000, time.FixedZone("X", 3600))
+	// Out of the range of Unix nanoseconds.
+	farPast := time.Date(1200, 1, 2, 3, 4, 5, 0, time.UTC)
+	farFuture := time.Date(3000, 1, 2, 3, 4, 5, 6000, time.UTC)
+	tests := []struct {
+		name       string
+		codec      ValueCodec
+		value      any
+		wantEncode any
+		target     reflect.Type
+		wantDecode any
+	}{
+		{name: "text", codec: TextCodec{}, value: testStatusBlocked, wantEncode: "blocked",
+			target: reflect.TypeOf(testStatus(0)), wantDecode: testStatusBlocked},
+		{name: "text_into_any", codec: TextCodec{}, value: testStatusActive, wantEncode: "active",
+			target: anyType, wantDecode: "active"},
+		{name: "time_utc", codec: TimeUTCCodec{}, value: moment, wantEncode: moment.UTC(),
+			target: reflect.TypeOf(time.Time{}), wantDecode: moment.UTC()},
+		{name: "unix_seconds", codec: UnixTimeCodec{}, value: moment, wantEncode: moment.Unix(),
+			target: reflect.TypeOf(time.Time{}), wantDecode: time.Unix(moment.Unix(), 0).UTC()},
+		{name: "unix_millis", codec: UnixTimeCodec{Unit: time.Millisecond}, value: moment, wantEncode: moment.UnixMilli(),
+			target: reflect.TypeOf(time.Time{}), wantDecode: moment.UTC()},
+		{name: "unix_seconds_far_past", codec: UnixTimeCodec{}, value: farPast, wantEncode: farPast.Unix(),
+			target: reflect.TypeOf(time.Time{}), wantDecode: farPast},
+		{name: "unix_micros_far_future", codec: UnixTimeCodec{Unit: time.Microsecond}, value: farFuture, wantEncode: farFuture.UnixMicro(),
+			target: reflect.TypeOf(time.Time{}), wantDecode: farFuture},
+		{name: "duration", codec: DurationCodec{}, value: 90 * time.Second, wantEncode: int64(90 * time.Second),
+			target: reflect.TypeOf(time.Duration(0)), wantDecode: 90 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := tt.codec.Encode(tt.value)
+			if err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+			if !reflect.DeepEqual(encoded, tt.wantEncode) {
+				t.Errorf("Encode() = %#v, want %#v", encoded, tt.wantEncode)
+			}
+			decoded, err := tt.codec.Decode(encoded, tt.target)
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if !reflect.DeepEqual(decoded, tt.wantDecode) {
+				t.Errorf("Decode() = %#v, want %#v", decoded, tt.wantDecode)
+			}
+		})
+	}
+
+	t.Run("time_utc_from_text", func(t *testing.T) {
+		decoded, err := TimeUTCCodec{}.Decode([]byte("2024-05-06T07:08:09+01:00"), reflect.TypeOf(time.Time{}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := time.Date(2024, 5, 6, 6, 8, 9, 0, time.UTC); !decoded.(time.Time).Equal(want) {
+			t.Errorf("Decode() = %v, want %v", decoded, want)
+		}
+	})
+	t.Run("unix_zero_time_is_null", func(t *testing.T) {
+		if encoded, err := (UnixTimeCodec{}).Encode(time.Time{}); err != nil || encoded != nil {
+			t.Errorf("Encode(zero) = %v, %v; want nil, nil", encoded, err)
+		}
+	})
+	t.Run("errors", func(t *testing.T) {
+		if _, err := (TextCodec{}).Encode(1); err == nil {
+			t.Error("TextCodec.Encode: expected error for a non-marshaler")
+		}
+		if _, err := (TextCodec{}).Decode("x", reflect.TypeOf(testStatus(0))); err == nil {
+			t.Error("TextCodec.Decode: expected error for an unknown enum value")
+		}
+		if _, err := (TimeUTCCodec{}).Encode("x"); err == nil {
+			t.Error("TimeUTCCodec.Encode: expected error for a non-time")
+		}
+		if _, err := (UnixTimeCodec{}).Decode(true, nil); err == nil {
+			t.Error("UnixTimeCodec.Decode: expected error for a bool")
+		}
+		if _, err := (DurationCodec{}).Encode("1s"); err == nil {
+			t.Error("DurationCodec.Encode: expected error for a string")
+		}
+	})
+}
+
+func TestColumnMapper_toSQL_Codecs(t *testing.T) {
+	m := newColumnMapper(DbOptions{Codecs: newTestCodecs()}, "accounts")
+	accountType := reflect.TypeOf(codecAccount{})
+	field := func(name string) *reflect.StructField {
+		f, _ := accountType.FieldByName(name)
+		return &f
+	}
+	blocked := testStatusBlocked
+	tests := []struct {
+		name   string
+		column string
+		field  *reflect.StructField
+		value  any
+		want   any
+	}{
+		{name: "no_codec", column: "Name", field: field("Name"), value: "John", want: "John"},
+		{name: "text_marshaler", column: "Status", field: field("Status"), value: testStatusActive, want: "active"},
+		{name: "pointer", column: "Previous", field: field("Previous"), value: &blocked, want: "blocked"},
+		{name: "nil_pointer", column: "Previous", field: field("Previous"), value: (*testStatus)(nil), want: nil},
+		{name: "duration", column: "Timeout", field: field("Timeout"), value: time.Minute, want: int64(time.Minute)},
+		{name: "map_data_by_value_type", column: "Timeout", value: time.Second, want: int64(time.Second)},
+		{name: "map_data_nil", column: "Timeout", value: nil, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := m.toSQL(tt.column, tt.field, tt.value)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("toSQL() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("encode_error", func(t *testing.T) {
+		if _, err := m.toSQL("Status", field("Status"), testStatus(42)); err == nil {
+			t.Error("expected error for a value the codec cannot encode")
+		}
+	})
+}
+
+func TestColumnMapper_fromSQL_ColumnTypeCodec(t *testing.T) {
+	m := newColumnMapper(DbOptions{Codecs: NewCodecs().ForColumnType("UUID", upperCodec{})}, "accounts")
+	if got, err := m.fromSQL("ID", "uuid", []byte("a1b2")); err != nil || got != "A1B2" {
+		t.Errorf("fromSQL(UUID) = %#v, %v; want \"A1B2\"", got, err)
+	}
+	if got, err := m.fromSQL("Name", "TEXT", []byte("a1b2")); err != nil || got != "a1b2" {
+		t.Errorf("fromSQL(TEXT) = %#v, %v; want \"a1b2\"", got, err)
+	}
+	if got, err := m.fromSQL("ID", "UUID", nil); err != nil || got != nil {
+		t.Errorf("fromSQL(UUID, nil) = %#v, %v; want nil", got, err)
+	}
+	if _, err := m.fromSQL("ID", "UUID", 1.5); err == nil {
+		t.Error("expected decode error")
+	}
+}
+
+func TestCodecs_SQLiteRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	sqlDB := openTestSQLiteDB(t, `CREATE TABLE accounts (
+		ID       TEXT PRIMARY KEY,
+		Name     TEXT,
+		Status   TEXT,
+		Previous TEXT,
+		Timeout  INTEGER,
+		SeenAt   INTEGER
+	)`)
+	db := dal.BackendOf(NewDatabase(sqlDB, newSchema(), DbOptions{
+		Dialect: DialectSQLite,
+		Codecs:  newTestCodecs(),
+		Recordsets: map[string]*Recordset{
+			"accounts": NewRecordset("accounts", Table, []dal.FieldRef{dal.Field("ID")}),
+		},
+	})).(*database)
+
+	active := testStatusActive
+	want := map[string]codecAccount{
+		"a1": {Name: "Ann", Status: testStatusBlocked, Previous: &active, Timeout: 5 * time.Second,
+			SeenAt: time.Date(2024, 1, 2, 3, 4, 5, 6_000_000, time.UTC)},
+		"a2": {Name: "Bob", Status: testStatusActive, Timeout: time.Hour,
+			SeenAt: time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, id := range []string{"a1", "a2"} {
+		data := want[id]
+		if err := db.Insert(ctx, dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("accounts", id), &data)); err != nil {
+			t.Fatalf("Insert(%s): %v", id, err)
+		}
+	}
+
+	t.Run("stored_encoded", func(t *testing.T) {
+		var status string
+		var timeout, seenAt int64
+		row := sqlDB.QueryRow("SELECT Status, Timeout, SeenAt FROM accounts WHERE ID = 'a1'")
+		if err := row.Scan(&status, &timeout, &seenAt); err != nil {
+			t.Fatal(err)
+		}
+		if status != "blocked" || timeout != int64(5*time.Second) || seenAt != want["a1"].SeenAt.UnixMilli() {
+			t.Errorf("stored values = %q, %d, %d", status, timeout, seenAt)
+		}
+	})
+
+	t.Run("Get", func(t *testing.T) {
+		for id, w := range want {
+			var got codecAccount
+			if err := db.Get(ctx, dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("accounts", id), &got)); err != nil {
+				t.Fatalf("Get(%s): %v", id, err)
+			}
+			if !reflect.DeepEqual(got, w) {
+				t.Errorf("Get(%s) = %+v, want %+v", id, got, w)
+			}
+		}
+	})
+
+	t.Run("GetMulti", func(t *testing.T) {
+		got := map[string]*codecAccount{"a1": {}, "a2": {}}
+		records := []dalrecord.Record{
+			dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("accounts", "a1"), got["a1"]),
+			dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("accounts", "a2"), got["a2"]),
+		}
+		if err := db.GetMulti(ctx, records); err != nil {
+			t.Fatalf("GetMulti: %v", err)
+		}
+		for id, w := range want {
+			if !reflect.DeepEqual(*got[id], w) {
+				t.Errorf("GetMulti()[%s] = %+v, want %+v", id, *got[id], w)
+			}
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		key := dalrecord.NewKeyWithID("accounts", "a2")
+		if err := db.Update(ctx, key, []update.Update{
+			update.ByFieldName("Status", testStatusBlocked),
+			update.ByFieldName("Timeout", time.Minute),
+		}); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		var got codecAccount
+		if err := db.Get(ctx, dalrecord.NewRecordWithData(key, &got)); err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if got.Status != testStatusBlocked || got.Timeout != time.Minute {
+			t.Errorf("after Update: Status = %v, Timeout = %v", got.Status, got.Timeout)
+		}
+	})
+
+	t.Run("decode_error", func(t *testing.T) {
+		if _, err := sqlDB.Exec("UPDATE accounts SET Status = 'bogus' WHERE ID = 'a1'"); err != nil {
+			t.Fatal(err)
+		}
+		var got codecAccount
+		err := db.Get(ctx, dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("accounts", "a1"), &got))
+		if err == nil || !strings.Contains(err.Error(), "Status") {
+			t.Errorf("expected decode error mentioning the column, got %v", err)
+		}
+	})
+}
+
+func TestCodecs_SQLiteKeyRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	sqlDB := openTestSQLiteDB(t, `CREATE TABLE statuses (ID TEXT PRIMARY KEY, Name TEXT)`)
+	db := dal.BackendOf(NewDatabase(sqlDB, newSchema(), DbOptions{
+		Dialect: DialectSQLite,
+		Codecs:  newTestCodecs(),
+		Recordsets: map[string]*Recordset{
+			"statuses": NewRecordset("statuses", Table, []dal.FieldRef{dal.Field("ID")}),
+		},
+	})).(*database)
+	type status struct {
+		Name string
+	}
+	key := dalrecord.NewKeyWithID("statuses", testStatusActive)
+
+	if err := db.Insert(ctx, dalrecord.NewRecordWithData(key, &status{Name: "Active"})); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	var id string
+	if err := sqlDB.QueryRow("SELECT ID FROM statuses").Scan(&id); err != nil {
+		t.Fatal(err)
+	}
+	if id != "active" {
+		t.Errorf("stored ID = %q, want %q", id, "active")
+	}
+
+	if err := db.Set(ctx, dalrecord.NewRecordWithData(key, &status{Name: "Set"})); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	var name string
+	if err := sqlDB.QueryRow("SELECT Name FROM statuses").Scan(&name); err != nil {
+		t.Fatal(err)
+	}
+	if name != "Set" {
+		t.Errorf("Name after Set = %q, want %q", name, "Set")
+	}
+	if err := db.Update(ctx, key, []update.Update{update.ByFieldName("Name", "Updated")}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	var got status
+	if err := db.Get(ctx, dalrecord.NewRecordWithData(key, &got)); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.Name != "Updated" {
+		t.Errorf("Get().Name = %q, want %q", got.Name, "Updated")
+	}
+	if exists, err := db.Exists(ctx, key); err != nil || !exists {
+		t.Errorf("Exists() = %v, %v", exists, err)
+	}
+
+	if err := db.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	var count int
+	if err := sqlDB.QueryRow("SELECT COUNT(*) FROM statuses").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("%d rows left after Delete", count)
+	}
+}
+
+func TestReaders_ColumnTypeCodec(t *testing.T) {
+	options := DbOptions{Codecs: NewCodecs().ForColumnType("UUID", upperCodec{})}
+	newRows := func() *sqlmock.Rows {
+		return sqlmock.NewRowsWithColumnDefinition(
+			sqlmock.NewColumn("id").OfType("UUID", ""),
+			sqlmock.NewColumn("name").OfType("TEXT", ""),
+		).AddRow([]byte("ab-cd"), []byte("john"))
+	}
+
+	t.Run("RecordsReader", func(t *testing.T) {
+		sqlDB, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer closeDatabase(t, sqlDB)
+		mock.ExpectQuery("SELECT id, name FROM users").WillReturnRows(newRows())
+
+		rr, err := getRecordsReader(context.Background(), options, dal.NewTextQuery("SELECT id, name FROM users", nil), sqlDB.QueryContext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = rr.Close() }()
+		record, err := rr.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]any{"id": "AB-CD", "name": "john"}
+		if got := record.Data().(map[string]any); !reflect.DeepEqual(got, want) {
+			t.Errorf("data = %#v, want %#v", got, want)
+		}
+	})
+
+	t.Run("RecordsetReader", func(t *testing.T) {
+		sqlDB, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer closeDatabase(t, sqlDB)
+		mock.ExpectQuery("SELECT id, name FROM users").WillReturnRows(newRows())
+
+		rr, err := getRecordsetReader(context.Background(), options, dal.NewTextQuery("SELECT id, name FROM users", nil), sqlDB.QueryContext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = rr.Close() }()
+		row, rs, err := rr.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := row.GetValueByIndex(0, rs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != "AB-CD" {
+			t.Errorf("id = %#v, want \"AB-CD\"", got)
+		}
+	})
+
+	t.Run("decode_error", func(t *testing.T) {
+		sqlDB, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer closeDatabase(t, sqlDB)
+		rows := sqlmock.NewRowsWithColumnDefinition(sqlmock.NewColumn("id").OfType("UUID", "")).AddRow(true)
+		mock.ExpectQuery("SELECT id FROM users").WillReturnRows(rows)
+
+		rr, err := getRecordsReader(context.Background(), options, dal.NewTextQuery("SELECT id FROM users", nil), sqlDB.QueryContext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = rr.Close() }()
+		if _, err = rr.Next(); err == nil || errors.Is(err, dal.ErrNoMoreRecords) {
+			t.Errorf("expected decode error, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,362 @@
+package dalgo2sql
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// jsonTagName is the struct tag that marks a field as stored in a JSON column:
+//
+//	type Customer struct {
+//		Name    string
+//		Address Address `dalgo2sql:"json"`
+//	}
+const jsonTagName = "dalgo2sql"
+
+// columnMapper converts record field values to SQL arguments and scanned
+// column values back to record field values for a single recordset.
+type columnMapper struct {
+	jsonColumns map[string]bool
+	codecs      *Codecs
+}
+
+func newColumnMapper(options DbOptions, collection string) columnMapper {
+	m := columnMapper{codecs: options.Codecs}
+	if rs := options.Recordsets[collection]; rs != nil {
+		m.jsonColumns = rs.jsonColumns
+	}
+	return m
+}
+
+// isJSONField reports whether a struct field is stored in a JSON column.
+func (m columnMapper) isJSONField(field reflect.StructField) bool {
+	if m.jsonColumns[field.Name] {
+		return true
+	}
+	for _, v := range strings.Split(field.Tag.Get(jsonTagName), ",") {
+		if v == "json" {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldCodec returns the codec for a field type, looking through pointers
+// so that *T fields use the codec registered for T.
+func (m columnMapper) fieldCodec(t reflect.Type) (codec ValueCodec, isPointer bool) {
+	if codec = m.codecs.codecForType(t); codec != nil {
+		return codec, false
+	}
+	if t.Kind() == reflect.Pointer {
+		if codec = m.codecs.codecForType(t.Elem()); codec != nil {
+			return codec, true
+		}
+	}
+	return nil, false
+}
+
+// toSQL converts a field value to an SQL argument. Field is nil for map data.
+// Nil pointers become NULL and other pointers are dereferenced, unless they
+// implement driver.Valuer, so that codecs registered for T apply to *T values.
+func (m columnMapper) toSQL(column string, field *reflect.StructField, value any) (any, error) {
+	isJSON := m.jsonColumns[column]
+	if field != nil {
+		isJSON = m.isJSONField(*field)
+	}
+	if isJSON {
+		return encodeJSONValue(column, value)
+	}
+	if field != nil {
+		if codec := m.codecs.codecForType(field.Type); codec != nil {
+			return encodeValue(column, codec, value)
+		}
+	}
+	if value = derefValue(value); value == nil {
+		return nil, nil
+	}
+	if codec := m.codecs.codecForType(reflect.TypeOf(value)); codec != nil {
+		return encodeValue(column, codec, value)
+	}
+	return value, nil
+}
+
+func encodeValue(column string, codec ValueCodec, value any) (any, error) {
+	encoded, err := codec.Encode(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode column %s: %w", column, err)
+	}
+	return encoded, nil
+}
+
+// derefValue follows pointers to the underlying value and returns nil for nil pointers.
+func derefValue(value any) any {
+	for {
+		if _, isValuer := value.(driver.Valuer); isValuer {
+			return value
+		}
+		v := reflect.ValueOf(value)
+		if v.Kind() != reflect.Pointer {
+			return value
+		}
+		if v.IsNil() {
+			return nil
+		}
+		value = v.Elem().Interface()
+	}
+}
+
+// fromSQL converts a scanned column value of map data to a map value.
+// The dbTypeName selects a codec registered by Codecs.ForColumnType, if any.
+func (m columnMapper) fromSQL(column, dbTypeName string, value any) (any, error) {
+	if codec := m.codecs.codecForColumnType(dbTypeName); codec != nil && value != nil {
+		decoded, err := codec.Decode(value, anyType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode column %s: %w", column, err)
+		}
+		return decoded, nil
+	}
+	if b, ok := value.([]byte); ok {
+		// database/sql returns []byte for TEXT columns; convert to string for usability.
+		value = string(b)
+	}
+	if !m.jsonColumns[column] || value == nil {
+		return value, nil
+	}
+	var v any
+	if err := decodeJSONValue(column, value, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// setMapValue stores a column value in map data. NULL is stored as a nil
+// value rather than omitted, so that maps round-trip through the database.
+func setMapValue(mv reflect.Value, column string, value any) {
+	v := reflect.Zero(mv.Type().Elem())
+	if value != nil {
+		v = reflect.ValueOf(value)
+	}
+	mv.SetMapIndex(reflect.ValueOf(column), v)
+}
+
+// assignRowToMap stores scanned column values into map data
+// (map[string]any or *map[string]any), converting them by fromSQL.
+func (m columnMapper) assignRowToMap(cols, dbTypes []string, values []any, data any) error {
+	v := reflect.ValueOf(data)
+	if v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		// If it's a pointer to a nil map, initialize the map first.
+		if v.Elem().Kind() == reflect.Map && v.Elem().IsNil() {
+			v.Elem().Set(reflect.MakeMap(v.Elem().Type()))
+		}
+		v = v.Elem()
+	}
+	for i, col := range cols {
+		val, err := m.fromSQL(col, dbTypes[i], values[i])
+		if err != nil {
+			return err
+		}
+		setMapValue(v, col, val)
+	}
+	return nil
+}
+
+// columnTypeNames returns database type names of the columns when codecs
+// are registered for column types, and empty names otherwise so that drivers
+// are not asked for column types needlessly.
+func (m columnMapper) columnTypeNames(rows *sql.Rows, n int) ([]string, error) {
+	names := make([]string, n)
+	if !m.codecs.hasColumnTypeCodecs() {
+		return names, nil
+	}
+	colTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	for i, ct := range colTypes {
+		if i < n {
+			names[i] = ct.DatabaseTypeName()
+		}
+	}
+	return names, nil
+}
+
+func encodeJSONValue(column string, value any) (any, error) {
+	if value == nil {
+		return nil, nil
+	}
+	switch v := reflect.ValueOf(value); v.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+	default:
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode column %s as JSON: %w", column, err)
+	}
+	return string(b), nil
+}
+
+func decodeJSONValue(column string, value any, target any) error {
+	var b []byte
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		// SQLite columns with NUMERIC affinity return scalar JSON as numbers.
+		b = []byte(fmt.Sprint(v))
+	}
+	if err := json.Unmarshal(b, target); err != nil {
+		return fmt.Errorf("failed to decode JSON column %s: %w", column, err)
+	}
+	return nil
+}
+
+// scanRowIntoStruct scans the current row into the struct pointed by data,
+// matching columns to fields by name; see assignRowToStruct.
+func scanRowIntoStruct(rows *sql.Rows, data any, m columnMapper) error {
+	cols, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	dbTypes, err := m.columnTypeNames(rows, len(cols))
+	if err != nil {
+		return err
+	}
+	values, err := scanRowValues(rows, len(cols))
+	if err != nil {
+		return err
+	}
+	return m.assignRowToStruct(cols, dbTypes, values, data)
+}
+
+// scanRowValues scans the current row into a slice of raw values.
+func scanRowValues(rows *sql.Rows, n int) ([]any, error) {
+	values := make([]any, n)
+	targets := make([]any, n)
+	for i := range values {
+		targets[i] = &values[i]
+	}
+	if err := rows.Scan(targets...); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// assignRowToStruct stores scanned column values into the struct pointed by
+// data, matching columns to fields by name. Fields stored in JSON columns or
+// handled by a codec are decoded; other fields are converted the way
+// database/sql does. Columns without a matching field are skipped.
+func (m columnMapper) assignRowToStruct(cols, dbTypes []string, values []any, data any) error {
+	val := reflect.ValueOf(data)
+	if val.Kind() != reflect.Pointer || val.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("expected a pointer to struct, got %T", data)
+	}
+	val = val.Elem()
+	for i, col := range cols {
+		field, ok := fieldByColumnName(val, col)
+		if !ok {
+			continue
+		}
+		if err := m.assignField(col, dbTypes[i], field, values[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m columnMapper) assignField(column, dbTypeName string, field structField, value any) error {
+	if m.isJSONField(field.sf) {
+		if value == nil {
+			field.v.Set(reflect.Zero(field.v.Type()))
+			return nil
+		}
+		return decodeJSONValue(column, value, field.v.Addr().Interface())
+	}
+	codec, isPointer := m.fieldCodec(field.sf.Type)
+	if codec == nil {
+		codec = m.codecs.codecForColumnType(dbTypeName)
+	}
+	if codec == nil {
+		if err := convertAssign(field.v, value); err != nil {
+			return fmt.Errorf("failed to assign column %s to field %s: %w", column, field.sf.Name, err)
+		}
+		return nil
+	}
+	if value == nil {
+		field.v.Set(reflect.Zero(field.v.Type()))
+		return nil
+	}
+	target := field.v
+	if isPointer {
+		target = reflect.New(field.v.Type().Elem()).Elem()
+	}
+	decoded, err := codec.Decode(value, target.Type())
+	if err != nil {
+		return fmt.Errorf("failed to decode column %s: %w", column, err)
+	}
+	if err = assignValue(target, decoded); err != nil {
+		return fmt.Errorf("failed to assign column %s: %w", column, err)
+	}
+	if isPointer {
+		field.v.Set(target.Addr())
+	}
+	return nil
+}
+
+// assignValue sets target to value, converting between compatible types.
+func assignValue(target reflect.Value, value any) error {
+	if value == nil {
+		target.Set(reflect.Zero(target.Type()))
+		return nil
+	}
+	v := reflect.ValueOf(value)
+	switch {
+	case v.Type().AssignableTo(target.Type()):
+		target.Set(v)
+	case v.Type().ConvertibleTo(target.Type()):
+		target.Set(v.Convert(target.Type()))
+	default:
+		return fmt.Errorf("value of type %T is not assignable to %v", value, target.Type())
+	}
+	return nil
+}
+
+type structField struct {
+	sf reflect.StructField
+	v  reflect.Value
+}
+
+// fieldByColumnName finds an exported field for a column: by exact name
+// first and then case-insensitively, as some drivers fold unquoted identifiers.
+func fieldByColumnName(val reflect.Value, column string) (structField, bool) {
+	t := val.Type()
+	if sf, ok := t.FieldByName(column); ok && sf.IsExported() && len(sf.Index) == 1 {
+		return structField{sf: sf, v: val.FieldByIndex(sf.Index)}, true
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if sf := t.Field(i); sf.IsExported() && strings.EqualFold(sf.Name, column) {
+			return structField{sf: sf, v: val.Field(i)}, true
+		}
+	}
+	return structField{}, false
+}
+
+// isJSONColumnType reports whether a database column type name denotes JSON.
+func isJSONColumnType(dbTypeName string) bool {
+	switch strings.ToUpper(dbTypeName) {
+	case "JSON", "JSONB":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,266 @@
+package dalgo2sql
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dal-go/dalgo/dal"
+	dalrecord "github.com/dal-go/record"
+)
+
+type jsonAddress struct {
+	City string
+	Zip  string
+}
+
+type jsonCustomer struct {
+	Name    string
+	Address jsonAddress       `dalgo2sql:"json"`
+	Tags    []string          `dalgo2sql:"json"`
+	Attrs   map[string]string // declared by WithJSONColumns in tests
+}
+
+func TestColumnMapper_toSQL(t *testing.T) {
+	m := newColumnMapper(DbOptions{
+		Recordsets: map[string]*Recordset{
+			"customers": NewRecordset("customers", Table, []dal.FieldRef{dal.Field("ID")}, WithJSONColumns("Attrs")),
+		},
+	}, "customers")
+	customerType := reflect.TypeOf(jsonCustomer{})
+	field := func(name string) *reflect.StructField {
+		f, _ := customerType.FieldByName(name)
+		return &f
+	}
+
+	tests := []struct {
+		name   string
+		column string
+		field  *reflect.StructField
+		value  any
+		want   any
+	}{
+		{name: "plain", column: "Name", field: field("Name"), value: "John", want: "John"},
+		{name: "tagged_struct", column: "Address", field: field("Address"), value: jsonAddress{City: "Dublin"}, want: `{"City":"Dublin","Zip":""}`},
+		{name: "tagged_slice", column: "Tags", field: field("Tags"), value: []string{"a", "b"}, want: `["a","b"]`},
+		{name: "tagged_nil_slice", column: "Tags", field: field("Tags"), value: []string(nil), want: nil},
+		{name: "declared_map", column: "Attrs", field: field("Attrs"), value: map[string]string{"k": "v"}, want: `{"k":"v"}`},
+		{name: "declared_map_data", column: "Attrs", value: map[string]any{"k": 1}, want: `{"k":1}`},
+		{name: "undeclared_map_data", column: "Other", value: "x", want: "x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := m.toSQL(tt.column, tt.field, tt.value)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("toSQL() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("unsupported_value", func(t *testing.T) {
+		if _, err := m.toSQL("Attrs", nil, make(chan int)); err == nil {
+			t.Error("expected error for a value that cannot be encoded as JSON")
+		}
+	})
+}
+
+func TestDialect_JSONColumnType(t *testing.T) {
+	for d, want := range map[Dialect]string{
+		DialectGeneric:  "TEXT",
+		DialectSQLite:   "TEXT",
+		DialectPostgres: "JSONB",
+		DialectMySQL:    "JSON",
+	} {
+		if got := d.JSONColumnType(); got != want {
+			t.Errorf("%v.JSONColumnType() = %q, want %q", d, got, want)
+		}
+	}
+}
+
+func TestJSONColumns_SQLiteRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	sqlDB := openTestSQLiteDB(t, `CREATE TABLE customers (
+		ID      TEXT PRIMARY KEY,
+		Name    TEXT,
+		Address TEXT,
+		Tags    TEXT,
+		Attrs   JSON
+	)`)
+	db := dal.BackendOf(NewDatabase(sqlDB, newSchema(), DbOptions{
+		Dialect: DialectSQLite,
+		Recordsets: map[string]*Recordset{
+			"customers": NewRecordset("customers", Table, []dal.FieldRef{dal.Field("ID")}, WithJSONColumns("Attrs")),
+		},
+	})).(*database)
+
+	want := jsonCustomer{
+		Name:    "John",
+		Address: jsonAddress{City: "Dublin", Zip: "D01"},
+		Tags:    []string{"vip"},
+		Attrs:   map[string]string{"tier": "gold"},
+	}
+	for _, id := range []string{"c1", "c2"} {
+		data := want
+		if err := db.Insert(ctx, dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("customers", id), &data)); err != nil {
+			t.Fatalf("Insert(%s): %v", id, err)
+		}
+	}
+
+	t.Run("Get", func(t *testing.T) {
+		var got jsonCustomer
+		if err := db.Get(ctx, dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("customers", "c1"), &got)); err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Get() = %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("GetMulti", func(t *testing.T) {
+		var got1, got2 jsonCustomer
+		records := []dalrecord.Record{
+			dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("customers", "c1"), &got1),
+			dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("customers", "c2"), &got2),
+		}
+		if err := db.GetMulti(ctx, records); err != nil {
+			t.Fatalf("GetMulti: %v", err)
+		}
+		for i, got := range []jsonCustomer{got1, got2} {
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("GetMulti()[%d] = %+v, want %+v", i, got, want)
+			}
+		}
+	})
+
+	t.Run("Get_map", func(t *testing.T) {
+		got := map[string]any{}
+		if err := db.Get(ctx, dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("customers", "c1"), got)); err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if !reflect.DeepEqual(got["Attrs"], map[string]any{"tier": "gold"}) {
+			t.Errorf("Attrs = %#v", got["Attrs"])
+		}
+		if got["Tags"] != `["vip"]` {
+			t.Errorf("Tags = %#v, want JSON text as the column is not declared", got["Tags"])
+		}
+	})
+
+	t.Run("RecordsReader", func(t *testing.T) {
+		reader, err := db.ExecuteQueryToRecordsReader(ctx, dal.NewTextQuery("SELECT Attrs FROM customers WHERE ID = 'c1'", nil))
+		if err != nil {
+			t.Fatalf("ExecuteQueryToRecordsReader: %v", err)
+		}
+		defer func() { _ = reader.Close() }()
+		record, err := reader.Next()
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		if got := record.Data().(map[string]any)["Attrs"]; !reflect.DeepEqual(got, map[string]any{"tier": "gold"}) {
+			t.Errorf("Attrs = %#v", got)
+		}
+	})
+
+	t.Run("RecordsetReader", func(t *testing.T) {
+		reader, err := db.ExecuteQueryToRecordsetReader(ctx, dal.NewTextQuery("SELECT Attrs FROM customers WHERE ID = 'c1'", nil))
+		if err != nil {
+			t.Fatalf("ExecuteQueryToRecordsetReader: %v", err)
+		}
+		defer func() { _ = reader.Close() }()
+		row, rs, err := reader.Next()
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		got, err := row.GetValueByIndex(0, rs)
+		if err != nil {
+			t.Fatalf("GetValueByIndex: %v", err)
+		}
+		if !reflect.DeepEqual(got, map[string]any{"tier": "gold"}) {
+			t.Errorf("Attrs = %#v", got)
+		}
+	})
+}
+
+func TestJSONColumns_DeclaredTextColumn_Readers(t *testing.T) {
+	ctx := context.Background()
+	sqlDB := openTestSQLiteDB(t, `CREATE TABLE profiles (ID TEXT PRIMARY KEY, Attrs TEXT)`)
+	db := dal.BackendOf(NewDatabase(sqlDB, newSchema(), DbOptions{
+		Dialect: DialectSQLite,
+		Recordsets: map[string]*Recordset{
+			"profiles": NewRecordset("profiles", Table, []dal.FieldRef{dal.Field("ID")}, WithJSONColumns("Attrs")),
+		},
+	})).(*database)
+	type profile struct {
+		Attrs map[string]string
+	}
+	if err := db.Insert(ctx, dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("profiles", "p1"), &profile{Attrs: map[string]string{"tier": "gold"}})); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	// The recordset of a structured query tells the columns declared WithJSONColumns.
+	profiles := dal.NewQueryBuilder(dal.From(dal.NewRootCollectionRef("profiles", ""))).SelectIntoRecordset()
+	want := map[string]any{"tier": "gold"}
+
+	t.Run("RecordsReader", func(t *testing.T) {
+		reader, err := db.ExecuteQueryToRecordsReader(ctx, profiles)
+		if err != nil {
+			t.Fatalf("ExecuteQueryToRecordsReader: %v", err)
+		}
+		defer func() { _ = reader.Close() }()
+		record, err := reader.Next()
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		if got := record.Data().(map[string]any)["Attrs"]; !reflect.DeepEqual(got, want) {
+			t.Errorf("Attrs = %#v, want %#v", got, want)
+		}
+	})
+
+	t.Run("RecordsetReader", func(t *testing.T) {
+		reader, err := db.ExecuteQueryToRecordsetReader(ctx, profiles)
+		if err != nil {
+			t.Fatalf("ExecuteQueryToRecordsetReader: %v", err)
+		}
+		defer func() { _ = reader.Close() }()
+		row, rs, err := reader.Next()
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		got, err := row.GetValueByIndex(1, rs) // Attrs
+		if err != nil {
+			t.Fatalf("GetValueByIndex: %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Attrs = %#v, want %#v", got, want)
+		}
+	})
+}
+
+func TestRecordsReader_JSONColumnType(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeDatabase(t, sqlDB)
+
+	rows := sqlmock.NewRowsWithColumnDefinition(
+		sqlmock.NewColumn("doc").OfType("JSONB", ""),
+	).AddRow(`{"a":[1,2]}`)
+	mock.ExpectQuery("SELECT doc FROM docs").WillReturnRows(rows)
+
+	rr, err := getRecordsReader(context.Background(), DbOptions{}, dal.NewTextQuery("SELECT doc FROM docs", nil), sqlDB.QueryContext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = rr.Close() }()
+	record, err := rr.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{"a": []any{float64(1), float64(2)}}
+	if got := record.Data().(map[string]any)["doc"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("doc = %#v, want %#v", got, want)
+	}
+}
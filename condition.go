@@ -0,0 +1,167 @@
+package dalgo2sql
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/dal-go/dalgo/dal"
+)
+
+// buildCondition renders a dal.Condition as a parameterized SQL boolean
+// expression. Values are never inlined: every constant becomes a "?"
+// placeholder with a matching entry in args, so the caller is expected to
+// pass the final statement through PlaceholderDialect.rewritePlaceholders.
+//
+// Supported conditions are dal.Comparison (a field reference compared with a
+// constant; IN takes a constant slice) and dal.GroupCondition (AND/OR).
+func buildCondition(cond dal.Condition) (text string, args []any, err error) {
+	switch c := cond.(type) {
+	case nil:
+		return "", nil, errors.New("condition is nil")
+	case dal.Comparison:
+		return buildComparison(c)
+	case *dal.Comparison:
+		return buildComparison(*c)
+	case dal.GroupCondition:
+		return buildGroupCondition(c)
+	case *dal.GroupCondition:
+		return buildGroupCondition(*c)
+	default:
+		return "", nil, fmt.Errorf("%w: condition of type %T", dal.ErrNotSupported, cond)
+	}
+}
+
+func buildGroupCondition(g dal.GroupCondition) (text string, args []any, err error) {
+	var joiner string
+	switch strings.ToUpper(string(g.Operator())) {
+	case "AND":
+		joiner = " AND "
+	case "OR":
+		joiner = " OR "
+	default:
+		return "", nil, fmt.Errorf("%w: group operator %q", dal.ErrNotSupported, g.Operator())
+	}
+	conditions := g.Conditions()
+	if len(conditions) == 0 {
+		return "", nil, errors.New("group condition has no conditions")
+	}
+	parts := make([]string, len(conditions))
+	for i, c := range conditions {
+		var part string
+		var partArgs []any
+		if part, partArgs, err = buildCondition(c); err != nil {
+			return "", nil, err
+		}
+		parts[i] = "(" + part + ")"
+		args = append(args, partArgs...)
+	}
+	return strings.Join(parts, joiner), args, nil
+}
+
+func buildComparison(c dal.Comparison) (text string, args []any, err error) {
+	var operator string
+	switch op := string(c.Operator); op {
+	case "==", "=":
+		operator = "="
+	case "!=", "<>":
+		operator = "<>"
+	case ">", ">=", "<", "<=":
+		operator = op
+	case "In", "IN", "in":
+		return buildInComparison(c)
+	default:
+		return "", nil, fmt.Errorf("%w: comparison operator %q", dal.ErrNotSupported, op)
+	}
+	var left, right string
+	if left, args, err = buildExpression(c.Left, args); err != nil {
+		return "", nil, err
+	}
+	if isNullConstant(c.Right) {
+		switch operator {
+		case "=":
+			return left + " IS NULL", args, nil
+		case "<>":
+			return left + " IS NOT NULL", args, nil
+		}
+	}
+	if right, args, err = buildExpression(c.Right, args); err != nil {
+		return "", nil, err
+	}
+	return left + " " + operator + " " + right, args, nil
+}
+
+func buildInComparison(c dal.Comparison) (text string, args []any, err error) {
+	var left string
+	if left, args, err = buildExpression(c.Left, args); err != nil {
+		return "", nil, err
+	}
+	var values reflect.Value
+	switch right := c.Right.(type) {
+	case dal.Constant:
+		values = reflect.ValueOf(right.Value)
+	case *dal.Constant:
+		values = reflect.ValueOf(right.Value)
+	default:
+		return "", nil, fmt.Errorf("%w: right side of IN must be a constant slice, got %T", dal.ErrNotSupported, c.Right)
+	}
+	if kind := values.Kind(); kind != reflect.Slice && kind != reflect.Array {
+		return "", nil, fmt.Errorf("right side of IN must be a slice or array, got %v", values.Kind())
+	}
+	if values.Len() == 0 {
+		// An empty IN list matches nothing; most SQL dialects reject "IN ()".
+		return "1 = 0", args, nil
+	}
+	placeholders := make([]string, values.Len())
+	for i := 0; i < values.Len(); i++ {
+		placeholders[i] = "?"
+		args = append(args, values.Index(i).Interface())
+	}
+	return left + " IN (" + strings.Join(placeholders, ", ") + ")", args, nil
+}
+
+func buildExpression(expr dal.Expression, args []any) (text string, _ []any, err error) {
+	switch e := expr.(type) {
+	case dal.FieldRef:
+		if err = validateIdentifier(e.Name()); err != nil {
+			return "", args, err
+		}
+		return e.Name(), args, nil
+	case *dal.FieldRef:
+		return buildExpression(*e, args)
+	case dal.Constant:
+		return "?", append(args, e.Value), nil
+	case *dal.Constant:
+		return "?", append(args, e.Value), nil
+	default:
+		return "", args, fmt.Errorf("%w: expression of type %T", dal.ErrNotSupported, expr)
+	}
+}
+
+func isNullConstant(expr dal.Expression) bool {
+	switch e := expr.(type) {
+	case dal.Constant:
+		return e.Value == nil
+	case *dal.Constant:
+		return e != nil && e.Value == nil
+	}
+	return false
+}
+
+// validateIdentifier guards places where a name coming from application code
+// (a field reference, a JSON path segment) is written into SQL text verbatim.
+func validateIdentifier(name string) error {
+	if name == "" {
+		return errors.New("identifier is empty")
+	}
+	for i, r := range name {
+		switch {
+		case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return fmt.Errorf("invalid identifier %q: only letters, digits and underscores are allowed", name)
+		}
+	}
+	return nil
+}
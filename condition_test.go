@@ -0,0 +1,120 @@
+package dalgo2sql
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/dal-go/dalgo/dal"
+)
+
+func TestBuildCondition(t *testing.T) {
+	tests := []struct {
+		name     string
+		cond     dal.Condition
+		wantText string
+		wantArgs []any
+		wantErr  bool
+	}{
+		{
+			name:     "equal",
+			cond:     dal.Comparison{Operator: dal.Equal, Left: dal.Field("Status"), Right: dal.Constant{Value: "active"}},
+			wantText: "Status = ?",
+			wantArgs: []any{"active"},
+		},
+		{
+			name:     "less_than",
+			cond:     dal.Comparison{Operator: dal.LessThen, Left: dal.Field("CreatedAt"), Right: dal.Constant{Value: 100}},
+			wantText: "CreatedAt < ?",
+			wantArgs: []any{100},
+		},
+		{
+			name:     "equal_nil_is_null",
+			cond:     dal.Comparison{Operator: dal.Equal, Left: dal.Field("DeletedAt"), Right: dal.Constant{Value: nil}},
+			wantText: "DeletedAt IS NULL",
+		},
+		{
+			name:     "in",
+			cond:     dal.Comparison{Operator: dal.In, Left: dal.Field("ID"), Right: dal.Constant{Value: []string{"a", "b"}}},
+			wantText: "ID IN (?, ?)",
+			wantArgs: []any{"a", "b"},
+		},
+		{
+			name:     "in_empty",
+			cond:     dal.Comparison{Operator: dal.In, Left: dal.Field("ID"), Right: dal.Constant{Value: []string{}}},
+			wantText: "1 = 0",
+		},
+		{
+			name:    "in_not_a_slice",
+			cond:    dal.Comparison{Operator: dal.In, Left: dal.Field("ID"), Right: dal.Constant{Value: 1}},
+			wantErr: true,
+		},
+		{
+			name:    "unsafe_field_name",
+			cond:    dal.Comparison{Operator: dal.Equal, Left: dal.Field("ID; DROP TABLE users"), Right: dal.Constant{Value: 1}},
+			wantErr: true,
+		},
+		{
+			name:    "nil",
+			cond:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, args, err := buildCondition(tt.cond)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got text=%q", text)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if text != tt.wantText {
+				t.Errorf("text = %q, want %q", text, tt.wantText)
+			}
+			if len(args) != len(tt.wantArgs) || (len(args) > 0 && !reflect.DeepEqual(args, tt.wantArgs)) {
+				t.Errorf("args = %v, want %v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestBuildCondition_Group(t *testing.T) {
+	q := dal.NewQueryBuilder(dal.From(dal.NewRootCollectionRef("orders", ""))).
+		WhereField("Status", dal.Equal, "open").
+		WhereField("Total", dal.GreaterThen, 10).
+		SelectIntoRecordset()
+	text, args, err := buildCondition(q.Where())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if text != "(Status = ?) AND (Total > ?)" {
+		t.Errorf("unexpected text: %q", text)
+	}
+	if !reflect.DeepEqual(args, []any{"open", 10}) {
+		t.Errorf("unexpected args: %v", args)
+	}
+}
+
+func TestBuildCondition_UnsupportedOperator(t *testing.T) {
+	_, _, err := buildCondition(dal.Comparison{Operator: "~", Left: dal.Field("Name"), Right: dal.Constant{Value: "x"}})
+	if !errors.Is(err, dal.ErrNotSupported) {
+		t.Errorf("expected dal.ErrNotSupported, got %v", err)
+	}
+}
+
+func TestValidateIdentifier(t *testing.T) {
+	for _, name := range []string{"ID", "created_at", "_x", "Field2"} {
+		if err := validateIdentifier(name); err != nil {
+			t.Errorf("validateIdentifier(%q) unexpected error: %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "2nd", "a b", "a;b", "a.b", "a'b"} {
+		if err := validateIdentifier(name); err == nil {
+			t.Errorf("validateIdentifier(%q) expected error", name)
+		}
+	}
+}
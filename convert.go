@@ -0,0 +1,142 @@
+package dalgo2sql
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// convertAssign stores a value scanned into an `any` destination into a
+// struct field, following the conversion rules of database/sql's Rows.Scan:
+// sql.Scanner implementations (sql.NullString etc.) scan the value
+// themselves, NULL sets pointers, slices, maps and interfaces to nil, other
+// pointers are allocated, and numbers, strings and []byte are converted
+// between each other.
+//
+// Rows are scanned into `any` first so that a row can be matched to a record
+// (by primary key) and fields can be matched to columns by name before any
+// field is written.
+func convertAssign(dest reflect.Value, src any) error {
+	if dest.CanAddr() {
+		if scanner, ok := dest.Addr().Interface().(sql.Scanner); ok {
+			return scanner.Scan(cloneBytes(src))
+		}
+	}
+	if src == nil {
+		switch dest.Kind() {
+		case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice:
+			dest.Set(reflect.Zero(dest.Type()))
+			return nil
+		default:
+			return fmt.Errorf("converting NULL to %s is unsupported", dest.Kind())
+		}
+	}
+	if dest.Kind() == reflect.Pointer {
+		elem := reflect.New(dest.Type().Elem())
+		if err := convertAssign(elem.Elem(), src); err != nil {
+			return err
+		}
+		dest.Set(elem)
+		return nil
+	}
+	sv := reflect.ValueOf(cloneBytes(src))
+	if sv.Type().AssignableTo(dest.Type()) {
+		dest.Set(sv)
+		return nil
+	}
+	switch dest.Kind() {
+	case reflect.String:
+		switch src.(type) {
+		case string, []byte, time.Time, bool,
+			int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+			dest.SetString(asString(src))
+			return nil
+		}
+	case reflect.Slice:
+		if dest.Type().Elem().Kind() == reflect.Uint8 {
+			switch s := src.(type) {
+			case string:
+				dest.SetBytes([]byte(s))
+				return nil
+			case []byte:
+				dest.SetBytes(append([]byte(nil), s...))
+				return nil
+			}
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		s := asString(src)
+		i64, err := strconv.ParseInt(s, 10, dest.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("converting %T %q to %s: %w", src, s, dest.Kind(), err)
+		}
+		dest.SetInt(i64)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		s := asString(src)
+		u64, err := strconv.ParseUint(s, 10, dest.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("converting %T %q to %s: %w", src, s, dest.Kind(), err)
+		}
+		dest.SetUint(u64)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		s := asString(src)
+		f64, err := strconv.ParseFloat(s, dest.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("converting %T %q to %s: %w", src, s, dest.Kind(), err)
+		}
+		dest.SetFloat(f64)
+		return nil
+	case reflect.Bool:
+		bv, err := driver.Bool.ConvertValue(src)
+		if err != nil {
+			return fmt.Errorf("converting %T to bool: %w", src, err)
+		}
+		dest.SetBool(bv.(bool))
+		return nil
+	default:
+	}
+	if sv.Kind() == dest.Kind() && sv.Type().ConvertibleTo(dest.Type()) {
+		// Named types such as `type Tags []string` or `type Point struct{...}`.
+		dest.Set(sv.Convert(dest.Type()))
+		return nil
+	}
+	return fmt.Errorf("unsupported conversion of %T into %v", src, dest.Type())
+}
+
+func asString(src any) string {
+	switch v := src.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+	rv := reflect.ValueOf(src)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10)
+	case reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'g', -1, 64)
+	case reflect.Float32:
+		return strconv.FormatFloat(rv.Float(), 'g', -1, 32)
+	case reflect.Bool:
+		return strconv.FormatBool(rv.Bool())
+	default:
+		return fmt.Sprintf("%v", src)
+	}
+}
+
+// cloneBytes copies []byte values so that fields never alias driver buffers.
+func cloneBytes(src any) any {
+	if b, ok := src.([]byte); ok && b != nil {
+		return append([]byte(nil), b...)
+	}
+	return src
+}
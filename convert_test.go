@@ -0,0 +1,71 @@
+package dalgo2sql
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConvertAssign(t *testing.T) {
+	type named string
+	moment := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name    string
+		dest    any // pointer to the destination
+		src     any
+		want    any
+		wantErr bool
+	}{
+		{name: "string_from_bytes", dest: new(string), src: []byte("a"), want: "a"},
+		{name: "string_from_int", dest: new(string), src: int64(5), want: "5"},
+		{name: "named_string", dest: new(named), src: "a", want: named("a")},
+		{name: "bytes_from_string", dest: new([]byte), src: "ab", want: []byte("ab")},
+		{name: "int_from_int64", dest: new(int), src: int64(5), want: 5},
+		{name: "int_from_text", dest: new(int32), src: []byte("-7"), want: int32(-7)},
+		{name: "int_overflow", dest: new(int8), src: int64(300), wantErr: true},
+		{name: "uint_from_int64", dest: new(uint16), src: int64(7), want: uint16(7)},
+		{name: "float_from_int64", dest: new(float64), src: int64(2), want: 2.0},
+		{name: "bool_from_int64", dest: new(bool), src: int64(1), want: true},
+		{name: "bool_from_text", dest: new(bool), src: "false", want: false},
+		{name: "time", dest: new(time.Time), src: moment, want: moment},
+		{name: "any", dest: new(any), src: int64(1), want: int64(1)},
+		{name: "pointer", dest: new(*int64), src: int64(1), want: ptr(int64(1))},
+		{name: "pointer_null", dest: ptr(ptr("x")), src: nil, want: (*string)(nil)},
+		{name: "scanner", dest: new(sql.NullInt64), src: int64(3), want: sql.NullInt64{Int64: 3, Valid: true}},
+		{name: "scanner_null", dest: &sql.NullString{String: "x", Valid: true}, src: nil, want: sql.NullString{}},
+		{name: "null_into_string", dest: new(string), src: nil, wantErr: true},
+		{name: "text_into_int", dest: new(int), src: "abc", wantErr: true},
+		{name: "unsupported", dest: new(chan int), src: int64(1), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := reflect.ValueOf(tt.dest).Elem()
+			err := convertAssign(dest, tt.src)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error, got %#v", dest.Interface())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := dest.Interface(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("bytes_are_copied", func(t *testing.T) {
+		src := []byte("abc")
+		var dest []byte
+		if err := convertAssign(reflect.ValueOf(&dest).Elem(), src); err != nil {
+			t.Fatal(err)
+		}
+		src[0] = 'x'
+		if string(dest) != "abc" {
+			t.Errorf("dest aliases the source: %q", dest)
+		}
+	})
+}
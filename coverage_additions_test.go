@@ -135,7 +135,7 @@ func TestRecordsetReader_RecordsetCursorClose(t *testing.T) {
 		sqlmock.NewRows([]string{"name"}).AddRow([]byte("John")).AddRow([]byte("Jane")),
 	)
 
-	rr, err := getRecordsetReader(ctx, q, sqlDB.QueryContext)
+	rr, err := getRecordsetReader(ctx, DbOptions{}, q, sqlDB.QueryContext)
 	if err != nil {
 		t.Fatalf("getRecordsetReader: %v", err)
 	}
@@ -187,7 +187,7 @@ func TestGetRecordsetReader_ExecuteError(t *testing.T) {
 	q := dal.NewTextQuery("SELECT bad FROM nope", nil)
 	mock.ExpectQuery(q.Text()).WillReturnError(errors.New("boom"))
 
-	_, err = getRecordsetReader(ctx, q, sqlDB.QueryContext)
+	_, err = getRecordsetReader(ctx, DbOptions{}, q, sqlDB.QueryContext)
 	if err == nil {
 		t.Fatal("expected error, got nil")
 	}
@@ -210,7 +210,7 @@ func TestRecordsetReader_Next_TypedConversions(t *testing.T) {
 	).AddRow([]byte("hello"), int64(7), int64(3)) // int64 to float64 will exercise the float64 conversion branch
 	mock.ExpectQuery(q.Text()).WillReturnRows(rows)
 
-	rr, err := getRecordsetReader(ctx, q, sqlDB.QueryContext)
+	rr, err := getRecordsetReader(ctx, DbOptions{}, q, sqlDB.QueryContext)
 	if err != nil {
 		t.Fatalf("getRecordsetReader: %v", err)
 	}
@@ -240,7 +240,7 @@ func TestRecordsetReader_Next_NilValue_DefaultsApplied(t *testing.T) {
 	).AddRow(nil)
 	mock.ExpectQuery(q.Text()).WillReturnRows(rows)
 
-	rr, err := getRecordsetReader(ctx, q, sqlDB.QueryContext)
+	rr, err := getRecordsetReader(ctx, DbOptions{}, q, sqlDB.QueryContext)
 	if err != nil {
 		t.Fatalf("getRecordsetReader: %v", err)
 	}
@@ -275,7 +275,7 @@ func TestGetRecordsetReader_TypedScanTypes(t *testing.T) {
 
 	mock.ExpectQuery(q.Text()).WillReturnRows(rows)
 
-	rr, err := getRecordsetReader(ctx, q, sqlDB.QueryContext)
+	rr, err := getRecordsetReader(ctx, DbOptions{}, q, sqlDB.QueryContext)
 	if err != nil {
 		t.Fatalf("getRecordsetReader: %v", err)
 	}
@@ -311,7 +311,7 @@ func TestGetRecordsetReader_NullableTypes(t *testing.T) {
 	).AddRow(time.Now(), "s", int64(1), 1.5, true, time.Now())
 	mock.ExpectQuery(q.Text()).WillReturnRows(rows)
 
-	rr, err := getRecordsetReader(ctx, q, sqlDB.QueryContext)
+	rr, err := getRecordsetReader(ctx, DbOptions{}, q, sqlDB.QueryContext)
 	if err != nil {
 		t.Fatalf("getRecordsetReader: %v", err)
 	}
@@ -339,7 +339,7 @@ func TestGetRecordsetReader_NullableIntSizes(t *testing.T) {
 	).AddRow(int16(1), int32(2), int64(3), []byte("x"))
 	mock.ExpectQuery(q.Text()).WillReturnRows(rows)
 
-	if _, err = getRecordsetReader(ctx, q, sqlDB.QueryContext); err != nil {
+	if _, err = getRecordsetReader(ctx, DbOptions{}, q, sqlDB.QueryContext); err != nil {
 		t.Fatalf("getRecordsetReader: %v", err)
 	}
 }
@@ -361,7 +361,7 @@ func TestGetRecordsetReader_NullInt8(t *testing.T) {
 		sqlmock.NewColumn("v").OfType("INT", sql.NullInt16{}),
 	).AddRow(int16(1))
 	mock.ExpectQuery(q.Text()).WillReturnRows(rows)
-	if _, err = getRecordsetReader(ctx, q, sqlDB.QueryContext); err != nil {
+	if _, err = getRecordsetReader(ctx, DbOptions{}, q, sqlDB.QueryContext); err != nil {
 		t.Fatalf("getRecordsetReader: %v", err)
 	}
 }
@@ -382,7 +382,7 @@ func TestGetRecordsetReader_PointerToByte(t *testing.T) {
 	).AddRow(nil)
 	mock.ExpectQuery(q.Text()).WillReturnRows(rows)
 
-	if _, err = getRecordsetReader(ctx, q, sqlDB.QueryContext); err != nil {
+	if _, err = getRecordsetReader(ctx, DbOptions{}, q, sqlDB.QueryContext); err != nil {
 		t.Fatalf("getRecordsetReader: %v", err)
 	}
 }
@@ -403,7 +403,7 @@ func TestGetRecordsetReader_PointerToInterface(t *testing.T) {
 	).AddRow(nil)
 	mock.ExpectQuery(q.Text()).WillReturnRows(rows)
 
-	if _, err = getRecordsetReader(ctx, q, sqlDB.QueryContext); err != nil {
+	if _, err = getRecordsetReader(ctx, DbOptions{}, q, sqlDB.QueryContext); err != nil {
 		t.Fatalf("getRecordsetReader: %v", err)
 	}
 }
@@ -424,7 +424,7 @@ func TestGetRecordsetReader_UnsupportedPointerType(t *testing.T) {
 	).AddRow(nil)
 	mock.ExpectQuery(q.Text()).WillReturnRows(rows)
 
-	if _, err = getRecordsetReader(ctx, q, sqlDB.QueryContext); err == nil {
+	if _, err = getRecordsetReader(ctx, DbOptions{}, q, sqlDB.QueryContext); err == nil {
 		t.Fatal("expected error for unsupported pointer type")
 	}
 }
@@ -444,7 +444,7 @@ func TestGetRecordsetReader_UnsupportedKind(t *testing.T) {
 	).AddRow(nil)
 	mock.ExpectQuery(q.Text()).WillReturnRows(rows)
 
-	if _, err = getRecordsetReader(ctx, q, sqlDB.QueryContext); err == nil {
+	if _, err = getRecordsetReader(ctx, DbOptions{}, q, sqlDB.QueryContext); err == nil {
 		t.Fatal("expected error for unsupported scan kind")
 	}
 }
@@ -464,7 +464,7 @@ func TestGetRecordsetReader_SliceNonByte(t *testing.T) {
 	).AddRow("anything")
 	mock.ExpectQuery(q.Text()).WillReturnRows(rows)
 
-	if _, err = getRecordsetReader(ctx, q, sqlDB.QueryContext); err != nil {
+	if _, err = getRecordsetReader(ctx, DbOptions{}, q, sqlDB.QueryContext); err != nil {
 		t.Fatalf("getRecordsetReader: %v", err)
 	}
 }
@@ -487,7 +487,7 @@ func TestGetRecordsetReader_UnsupportedStructType(t *testing.T) {
 
 	mock.ExpectQuery(q.Text()).WillReturnRows(rows)
 
-	_, err = getRecordsetReader(ctx, q, sqlDB.QueryContext)
+	_, err = getRecordsetReader(ctx, DbOptions{}, q, sqlDB.QueryContext)
 	if err == nil {
 		t.Fatal("expected unsupported-type error")
 	}
@@ -510,7 +510,7 @@ func TestGetRecordsetReader_MultiColumn(t *testing.T) {
 			AddRow([]byte("x"), []byte("y")).
 			AddRow(nil, []byte("z")), // exercise nil-value handling
 	)
-	rr, err := getRecordsetReader(ctx, q, sqlDB.QueryContext)
+	rr, err := getRecordsetReader(ctx, DbOptions{}, q, sqlDB.QueryContext)
 	if err != nil {
 		t.Fatalf("getRecordsetReader: %v", err)
 	}
@@ -538,7 +538,7 @@ func TestGetReaderBase_ExecuteError(t *testing.T) {
 	ctx := context.Background()
 	mock.ExpectQuery("SELECT 1").WillReturnError(errors.New("nope"))
 
-	_, err = getReaderBase(ctx, dal.NewTextQuery("SELECT 1", nil), sqlDB.QueryContext)
+	_, err = getReaderBase(ctx, DbOptions{}, dal.NewTextQuery("SELECT 1", nil), sqlDB.QueryContext)
 	if err == nil {
 		t.Fatal("expected error, got nil")
 	}
@@ -556,7 +556,7 @@ func TestGetReaderBase_StructuredQuery_Limit(t *testing.T) {
 		SelectIntoRecordset()
 
 	mock.ExpectQuery("LIMIT 10").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
-	rb, err := getReaderBase(ctx, q, sqlDB.QueryContext)
+	rb, err := getReaderBase(ctx, DbOptions{}, q, sqlDB.QueryContext)
 	if err != nil {
 		t.Fatalf("getReaderBase: %v", err)
 	}
@@ -576,7 +576,7 @@ func TestGetRecordsReader_ExecuteError(t *testing.T) {
 	ctx := context.Background()
 	mock.ExpectQuery("SELECT 1").WillReturnError(errors.New("denied"))
 
-	_, err = getRecordsReader(ctx, dal.NewTextQuery("SELECT 1", nil), sqlDB.QueryContext)
+	_, err = getRecordsReader(ctx, DbOptions{}, dal.NewTextQuery("SELECT 1", nil), sqlDB.QueryContext)
 	if err == nil {
 		t.Fatal("expected error, got nil")
 	}
@@ -663,7 +663,7 @@ func TestRowIntoRecord_NilDataPanics(t *testing.T) {
 	// Build a record with explicit nil data and force rowIntoRecord directly.
 	key := record.NewKeyWithID("users", "x")
 	rec := record.NewRecordWithData(key, nil)
-	_ = rowIntoRecord(nil, rec, false)
+	_ = rowIntoRecord(nil, rec, false, columnMapper{})
 }
 
 // --- simpleFieldsToKey: convert-branch (mismatched source kind) ---------
@@ -882,8 +882,6 @@ func TestDeleter_MultiInSingleTable_CustomPK(t *testing.T) {
 		record.NewKeyWithID("users", "u1"),
 		record.NewKeyWithID("users", "u2"),
 	}
-	mock.ExpectExec("DELETE FROM users WHERE uid = ?").WithArgs("u1").WillReturnResult(sqlmock.NewResult(0, 1))
-	mock.ExpectExec("DELETE FROM users WHERE uid = ?").WithArgs("u2").WillReturnResult(sqlmock.NewResult(0, 1))
 	mock.ExpectExec("DELETE FROM users WHERE uid IN (?, ?)").WithArgs("u1", "u2").WillReturnResult(sqlmock.NewResult(0, 2))
 	if err := db.DeleteMulti(ctx, keys); err != nil {
 		t.Errorf("unexpected: %v", err)
@@ -902,8 +900,6 @@ func TestDeleter_MultiInSingleTable_ExecError(t *testing.T) {
 		record.NewKeyWithID("users", "u1"),
 		record.NewKeyWithID("users", "u2"),
 	}
-	mock.ExpectExec("DELETE FROM users WHERE ID = ?").WithArgs("u1").WillReturnResult(sqlmock.NewResult(0, 1))
-	mock.ExpectExec("DELETE FROM users WHERE ID = ?").WithArgs("u2").WillReturnResult(sqlmock.NewResult(0, 1))
 	mock.ExpectExec("DELETE FROM users WHERE ID IN (?, ?)").WithArgs("u1", "u2").WillReturnError(errors.New("boom"))
 	if err := db.DeleteMulti(ctx, keys); err == nil {
 		t.Errorf("expected error from multi-in-single-table exec")
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"time"
 
 	"github.com/dal-go/dalgo/dal"
 	"github.com/dal-go/dalgo/recordset"
@@ -16,6 +17,9 @@ type database struct {
 	recordsReaderProvider
 	id              string
 	db              *sql.DB
+	query           queryExecutor
+	exec            statementExecutor
+	replicas        []replica
 	schema          dal.Schema
 	onlyReadWriteTx bool
 
@@ -24,7 +28,8 @@ type database struct {
 }
 
 func (dtb *database) ExecuteQueryToRecordsetReader(ctx context.Context, query dal.Query, options ...recordset.Option) (dal.RecordsetReader, error) {
-	return getRecordsetReader(ctx, query, dtb.executeQuery, options...)
+	db, _ := dtb.reader(ctx)
+	return getRecordsetReader(withOperation(ctx, OpQuery), dtb.options, query, db.QueryContext, options...)
 }
 
 //func (dtb *database) Connect(ctx context.Context) (dal.Connection, error) {
@@ -51,12 +56,13 @@ func (dtb *database) RunReadonlyTransaction(ctx context.Context, f dal.ROTxWorke
 	} else {
 		return fmt.Errorf("attemt to run readonly transation without readonly option")
 	}
-	dbTx, err := dtb.db.BeginTx(ctx, &sqlTxOptions)
+	db, _ := dtb.reader(ctx)
+	dbTx, err := db.BeginTx(ctx, &sqlTxOptions)
 	if err != nil {
 		if err.Error() == "sql: driver does not support read-only transactions" {
 			dtb.onlyReadWriteTx = true
 			sqlTxOptions.ReadOnly = false
-			dbTx, err = dtb.db.BeginTx(ctx, &sqlTxOptions)
+			dbTx, err = db.BeginTx(ctx, &sqlTxOptions)
 		}
 		if err != nil {
 			return fmt.Errorf("failed to begin transaction: %w", err)
@@ -65,15 +71,19 @@ func (dtb *database) RunReadonlyTransaction(ctx context.Context, f dal.ROTxWorke
 	if dbTx == nil {
 		return fmt.Errorf("sql driver returned nil transaction")
 	}
-	if err = f(ctx, newTransaction(dbTx, dtb.options, dalgoTxOptions)); err != nil {
+	started := time.Now()
+	if err = f(ctx, newTransaction(db, dbTx, dtb.options, dalgoTxOptions)); err != nil {
+		dtb.options.observeTransaction(started, false)
 		if rollbackErr := dbTx.Rollback(); rollbackErr != nil {
 			return dal.NewRollbackError(rollbackErr, err)
 		}
 		return err
 	}
 	if err := dbTx.Commit(); err != nil {
+		dtb.options.observeTransaction(started, false)
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
+	dtb.options.observeTransaction(started, true)
 	return nil
 }
 
@@ -87,20 +97,35 @@ func (dtb *database) RunReadwriteTransaction(ctx context.Context, f dal.RWTxWork
 	if err != nil {
 		return err
 	}
-	if err = f(ctx, newReadwriteTransaction(dbTx, dtb.options, dalgoTxOptions)); err != nil {
+	started := time.Now()
+	tx := newReadwriteTransaction(dtb.db, dbTx, dtb.options, dalgoTxOptions)
+	if identityMapped(ctx) {
+		tx.identities = newIdentityMap(dtb.options)
+	}
+	if bufferedWrites(ctx) {
+		tx = tx.buffered()
+	}
+	if err = f(ctx, tx); err == nil {
+		err = tx.buffer.flush()
+	}
+	if err != nil {
+		dtb.options.observeTransaction(started, false)
 		if rollbackErr := dbTx.Rollback(); rollbackErr != nil {
 			return dal.NewRollbackError(rollbackErr, err)
 		}
 		return err
 	}
 	if err := dbTx.Commit(); err != nil {
+		dtb.options.observeTransaction(started, false)
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
+	dtb.options.observeTransaction(started, true)
 	return nil
 }
 
 func (dtb *database) ExecuteQueryToRecordsReader(ctx context.Context, query dal.Query) (dal.RecordsReader, error) {
-	return getRecordsReader(ctx, query, dtb.db.QueryContext)
+	db, _ := dtb.reader(ctx)
+	return getRecordsReader(withOperation(ctx, OpQuery), dtb.options, query, db.QueryContext)
 }
 
 // NewDatabase creates a new instance of DALgo adapter to SQL database.
@@ -115,13 +140,36 @@ func NewDatabase(db *sql.DB, schema dal.Schema, options DbOptions) dal.DB {
 	if schema == nil {
 		panic("schema is a required parameter, got nil")
 	}
+	for i, r := range options.Replicas {
+		if r == nil {
+			panic(fmt.Sprintf("replica %d is nil", i))
+		}
+	}
+	if options.Metrics != nil {
+		options.Metrics.RegisterPool(options.ID, db.Stats)
+		for i, r := range options.Replicas {
+			options.Metrics.RegisterPool(replicaID(options.ID, i), r.Stats)
+		}
+	}
+	if len(options.Replicas) > 0 && options.ReplicaBalancer == nil {
+		options.ReplicaBalancer = NewRoundRobinBalancer()
+	}
+	options.statements = newStatementCache(options)
+	replicas := make([]replica, len(options.Replicas))
+	for i, r := range options.Replicas {
+		replicas[i] = replica{db: r, query: newQueryExecutor(options.statements, r, nil)}
+	}
 	return dal.NewDB(&database{
 		recordsReaderProvider: recordsReaderProvider{
 			executeQuery: db.QueryContext,
+			options:      options,
 		},
-		id:      options.ID,
-		db:      db,
-		schema:  schema,
-		options: options,
+		id:       options.ID,
+		db:       db,
+		query:    newQueryExecutor(options.statements, db, nil),
+		exec:     newStatementExecutor(options.statements, db, nil),
+		replicas: replicas,
+		schema:   schema,
+		options:  options,
 	})
 }
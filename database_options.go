@@ -1,6 +1,9 @@
 package dalgo2sql
 
 import (
+	"database/sql"
+	"time"
+
 	"github.com/dal-go/record"
 )
 
@@ -14,6 +17,89 @@ type DbOptions struct {
 	// SQLite, MySQL, and most other drivers.  Set to PlaceholderDollar
 	// for PostgreSQL, which requires "$1", "$2", … positional markers.
 	Placeholder PlaceholderDialect
+	// Dialect enables SQL features that are not portable across databases,
+	// such as updates of nested fields stored in JSON columns.
+	// The zero value (DialectGeneric) reports such features as not supported.
+	Dialect Dialect
+	// Codecs customizes how values of custom Go types (or columns of
+	// specific database types) are written and read. Nil means no codecs.
+	Codecs *Codecs
+	// GetMultiParallelism limits how many per-recordset queries GetMulti runs
+	// concurrently when called on the database with records from several
+	// recordsets. Zero uses DefaultGetMultiParallelism, 1 runs them one after
+	// another. GetMulti within a transaction always runs them one after another.
+	GetMultiParallelism int
+	// MaxParameters overrides Dialect.MaxParameters, e.g. for SQLite builds
+	// older than 3.32 that allow only 999 bound parameters per statement.
+	// Zero uses the dialect's limit.
+	MaxParameters int
+	// Hooks, if set, is called before and after every SQL statement
+	// executed by the database and its transactions. See NewSlogHooks.
+	Hooks Hooks
+	// Metrics, if set, receives counters and latencies per operation and
+	// recordset. See NewInMemoryMetrics.
+	Metrics Metrics
+	// SQLCacheSize bounds the number of cached SQL texts of Get, Exists,
+	// Insert, Set, Update and Delete statements, which are built once per
+	// recordset and statement shape. Zero uses DefaultSQLCacheSize,
+	// a negative value disables the cache.
+	SQLCacheSize int
+	// StatementCacheSize, if positive, makes the cached SQL texts be executed
+	// as statements prepared on the *sql.DB, keeping up to that many prepared
+	// statements open. Transactions rebind them with tx.StmtContext.
+	StatementCacheSize int
+	// Replicas are read replicas of the database. Get, GetMulti, Exists and
+	// queries outside transactions, and read-only transactions, run against
+	// a replica picked by ReplicaBalancer; everything else runs against the
+	// primary. See ReadFromPrimary.
+	Replicas []*sql.DB
+	// ReplicaBalancer picks the replica of each read.
+	// Nil uses a NewRoundRobinBalancer.
+	ReplicaBalancer ReplicaBalancer
+	// Tenancy, if set, scopes statements to the tenant carried by the
+	// context, see WithTenant.
+	Tenancy *Tenancy
+	// Clock returns the current time, e.g. for soft deletes.
+	// Nil uses time.Now.
+	Clock func() time.Time
+	// HistoryTable receives the changes of recordsets declared WithHistory.
+	// Empty uses DefaultHistoryTable.
+	HistoryTable string
+	// ChangelogTable receives the changes of recordsets declared
+	// WithChangelog. Empty uses DefaultChangelogTable.
+	ChangelogTable string
+	// IdempotencyTable stores the keys of writes made WithIdempotencyKey.
+	// Empty uses DefaultIdempotencyTable.
+	IdempotencyTable string
+	// IdempotencyTTL is how long idempotency keys are kept.
+	// Zero uses DefaultIdempotencyTTL.
+	IdempotencyTTL time.Duration
+	// OutboxTable receives the events enqueued with Outbox.
+	// Empty uses DefaultOutboxTable.
+	OutboxTable string
+
+	// statements is set by NewDatabase.
+	statements *statementCache
+}
+
+// DefaultGetMultiParallelism is used when DbOptions.GetMultiParallelism is not set.
+const DefaultGetMultiParallelism = 4
+
+// keysPerStatement returns how many keys of paramsPerKey parameters each
+// fit into a single statement without exceeding the parameter limit.
+func (o DbOptions) keysPerStatement(paramsPerKey int) int {
+	limit := o.MaxParameters
+	if limit <= 0 {
+		limit = o.Dialect.MaxParameters()
+	}
+	return max(1, limit/max(1, paramsPerKey))
+}
+
+func (o DbOptions) getMultiParallelism() int {
+	if o.GetMultiParallelism > 0 {
+		return o.GetMultiParallelism
+	}
+	return DefaultGetMultiParallelism
 }
 
 func (o DbOptions) GetRecordsetByKey(key *record.Key) *Recordset {
@@ -4,37 +4,65 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+
+	"github.com/dal-go/dalgo/dal"
 	"github.com/dal-go/record"
-	"strings"
 )
 
 type statementExecutor = func(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
 
 func (dtb *database) Delete(ctx context.Context, key *record.Key) error {
-	return deleteSingle(ctx, dtb.options, key, dtb.db.ExecContext)
+	ctx = withOperation(ctx, OpDelete)
+	return dtb.write(ctx, keysChange(key), func(_ queryExecutor, exec statementExecutor) error {
+		return deleteSingle(ctx, dtb.options, key, exec)
+	})
 }
 
 func (t transaction) Delete(ctx context.Context, key *record.Key) error {
-	return deleteSingle(ctx, t.sqlOptions, key, t.tx.ExecContext)
+	ctx = withOperation(ctx, OpDelete)
+	if t.buffer.accepts(ctx, key) {
+		return t.buffer.add(ctx, bufferedDelete, key, nil, nil)
+	}
+	return t.write(ctx, keysChange(key), func(_ queryExecutor, exec statementExecutor) error {
+		return deleteSingle(ctx, t.sqlOptions, key, exec)
+	})
 }
 
 func (dtb *database) DeleteMulti(ctx context.Context, keys []*record.Key) error {
-	return deleteMulti(ctx, dtb.options, keys, dtb.db.ExecContext)
+	ctx = withOperation(ctx, OpDeleteMulti)
+	return dtb.write(ctx, keysChange(keys...), func(_ queryExecutor, exec statementExecutor) error {
+		return deleteMulti(ctx, dtb.options, keys, exec)
+	})
 }
 
 func deleteSingle(ctx context.Context, options DbOptions, key *record.Key, exec statementExecutor) error {
 	collection := key.Collection()
-	//goland:noinspection SqlNoDataSourceInspection
-	query := fmt.Sprintf("DELETE FROM %v WHERE ", key.Collection())
+	exec = options.traceStatement(collection, exec)
+	pkCol := "ID"
 	if rs, hasOptions := options.Recordsets[collection]; hasOptions && len(rs.PrimaryKey()) == 1 {
-		query += rs.PrimaryKey()[0].Name() + " = " + options.Placeholder.placeholder(1)
-	} else {
-		query += "ID = " + options.Placeholder.placeholder(1)
+		pkCol = rs.PrimaryKey()[0].Name()
 	}
-	_, err := exec(ctx, query, key.ID)
+	scope, err := options.scope(ctx, collection)
 	if err != nil {
 		return err
 	}
+	column := options.softDeleteColumn(ctx, collection)
+	query := options.cachedSQL(
+		func() string { return statementShape("delete", scope.shape(), pkCol, column) },
+		func() string {
+			return options.Placeholder.rewritePlaceholders(deleteStatement(scope, column, pkCol+" = ?"+scope.where()))
+		})
+	keyArgs, err := primaryKeyArgs(newColumnMapper(options, collection), []string{pkCol}, key)
+	if err != nil {
+		return err
+	}
+	result, err := exec(ctx, query, append(append(options.deleteArgs(column), keyArgs...), scope.args...)...)
+	if err != nil {
+		return err
+	}
+	if count, err := result.RowsAffected(); err == nil {
+		options.count(ctx, collection, CounterDeletes, count)
+	}
 	return nil
 }
 
@@ -42,24 +70,16 @@ func deleteMulti(ctx context.Context, options DbOptions, keys []*record.Key, exe
 	var prevTable string
 	var tableKeys []*record.Key
 	deleteByKeys := func(table string, keys []*record.Key) error {
-		if len(keys) == 0 {
-			return nil
-		}
-		if len(keys) == 1 {
-			if err := deleteSingle(ctx, options, keys[0], exec); err != nil {
-				return err
-			}
+		switch len(keys) {
+		case 0:
 			return nil
-		}
-		for _, key := range keys {
-			if err := deleteSingle(ctx, options, key, exec); err != nil {
-				return err
+		case 1:
+			// deleteSingle does not support composite primary keys, deleteMultiInSingleTable does.
+			if len(options.PrimaryKeyFieldNames(keys[0])) <= 1 {
+				return deleteSingle(ctx, options, keys[0], exec)
 			}
 		}
-		if err := deleteMultiInSingleTable(ctx, options, keys, exec); err != nil {
-			return err
-		}
-		return nil // TODO: code above commented out as tests are failing for RAMSQL driver.
+		return deleteMultiInSingleTable(ctx, options, keys, exec)
 	}
 	for i, key := range keys {
 		kind := key.Collection()
@@ -84,29 +104,111 @@ func deleteMulti(ctx context.Context, options DbOptions, keys []*record.Key, exe
 	return nil
 }
 
+// deleteMultiInSingleTable deletes the keys of a single collection with
+// statements matching "pk IN (...)", or every column of a composite primary key.
 func deleteMultiInSingleTable(ctx context.Context, options DbOptions, keys []*record.Key, exec statementExecutor) error {
-	pkCol := "ID"
-
 	collection := keys[0].Collection()
-	if rs, hasOptions := options.Recordsets[collection]; hasOptions && len(rs.primaryKey) == 1 {
-		pkCol = rs.primaryKey[0].Name()
+	exec = options.traceStatement(collection, exec)
+	pk := options.PrimaryKeyFieldNames(keys[0])
+	if len(pk) == 0 {
+		pk = []string{"ID"}
 	}
-
-	query := fmt.Sprintf("DELETE FROM %v WHERE %v IN (", collection, pkCol)
-	args := make([]interface{}, len(keys))
-	q := make([]string, len(keys))
+	mapper := newColumnMapper(options, collection)
+	keyValues := make([][]any, len(keys))
 	for i, key := range keys {
-		args[i] = key.ID
-		q[i] = options.Placeholder.placeholder(i + 1)
+		values, err := primaryKeyArgs(mapper, pk, key)
+		if err != nil {
+			return err
+		}
+		keyValues[i] = values
 	}
-	query += strings.Join(q, ", ") + ")"
-	_, err := exec(ctx, query, args...)
+
+	scope, err := options.scope(ctx, collection)
 	if err != nil {
 		return err
 	}
+
+	column := options.softDeleteColumn(ctx, collection)
+
+	// Keys are split into chunks to stay under the driver's parameter limit.
+	chunkSize := max(1, options.keysPerStatement(len(pk))-len(scope.args)-len(options.deleteArgs(column)))
+	for start := 0; start < len(keyValues); start += chunkSize {
+		where, keyArgs := buildKeysCondition(pk, keyValues[start:min(start+chunkSize, len(keyValues))])
+		if len(pk) == 1 {
+			where += scope.where()
+		} else {
+			where = scope.whereClause(where)
+		}
+		args := append(append(options.deleteArgs(column), keyArgs...), scope.args...)
+		query := options.Placeholder.rewritePlaceholders(deleteStatement(scope, column, where))
+		result, err := exec(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		if count, err := result.RowsAffected(); err == nil {
+			options.count(ctx, collection, CounterDeletes, count)
+		}
+	}
 	return nil
 }
 
 func (t transaction) DeleteMulti(ctx context.Context, keys []*record.Key) error {
-	return deleteMulti(ctx, t.sqlOptions, keys, t.tx.ExecContext)
+	ctx = withOperation(ctx, OpDeleteMulti)
+	if t.buffer.accepts(ctx, keys...) {
+		for _, key := range keys {
+			if err := t.buffer.add(ctx, bufferedDelete, key, nil, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return t.write(ctx, keysChange(keys...), func(_ queryExecutor, exec statementExecutor) error {
+		return deleteMulti(ctx, t.sqlOptions, keys, exec)
+	})
+}
+
+// DeleteWhere deletes every row of the collection that matches the where
+// condition using a single DELETE statement and returns the number of deleted rows.
+// A nil condition is rejected to avoid accidentally emptying a table.
+func (dtb *database) DeleteWhere(ctx context.Context, collection string, where dal.Condition) (n int64, err error) {
+	ctx = withOperation(ctx, OpDeleteWhere)
+	err = dtb.write(ctx, whereChange(collection, where).counting(&n), func(_ queryExecutor, exec statementExecutor) (err error) {
+		n, err = deleteWhere(ctx, dtb.options, exec, collection, where)
+		return err
+	})
+	return n, err
+}
+
+// DeleteWhere is the in-transaction counterpart of database.DeleteWhere.
+func (t transaction) DeleteWhere(ctx context.Context, collection string, where dal.Condition) (n int64, err error) {
+	ctx = withOperation(ctx, OpDeleteWhere)
+	err = t.write(ctx, whereChange(collection, where).counting(&n), func(_ queryExecutor, exec statementExecutor) (err error) {
+		n, err = deleteWhere(ctx, t.sqlOptions, exec, collection, where)
+		return err
+	})
+	return n, err
+}
+
+func deleteWhere(ctx context.Context, options DbOptions, exec statementExecutor, collection string, where dal.Condition) (int64, error) {
+	if where == nil {
+		return 0, fmt.Errorf("delete from %s requires a where condition", collection)
+	}
+	scope, err := options.scope(ctx, collection)
+	if err != nil {
+		return 0, err
+	}
+	condition, args, err := buildCondition(where)
+	if err != nil {
+		return 0, fmt.Errorf("failed to build where condition for %s: %w", collection, err)
+	}
+	column := options.softDeleteColumn(ctx, collection)
+	text := options.Placeholder.rewritePlaceholders(deleteStatement(scope, column, scope.whereClause(condition)))
+	args = append(append(options.deleteArgs(column), args...), scope.args...)
+	result, err := options.traceStatement(collection, exec)(ctx, text, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete records from %s: %w", collection, err)
+	}
+	count, err := result.RowsAffected()
+	options.count(ctx, collection, CounterDeletes, count)
+	return count, err
 }
@@ -3,6 +3,7 @@ package dalgo2sql
 import (
 	"context"
 	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
@@ -71,16 +72,15 @@ func TestDeleter(t *testing.T) {
 			record.NewKeyWithID("users", "u2"),
 		}
 
-		// Currently deleteMulti calls deleteSingle for each key AND then deleteMultiInSingleTable
-		// according to the implementation in deleter.go:55-62
-		mock.ExpectExec("DELETE FROM users WHERE ID = ?").WithArgs("u1").WillReturnResult(sqlmock.NewResult(0, 1))
-		mock.ExpectExec("DELETE FROM users WHERE ID = ?").WithArgs("u2").WillReturnResult(sqlmock.NewResult(0, 1))
 		mock.ExpectExec("DELETE FROM users WHERE ID IN (?, ?)").WithArgs("u1", "u2").WillReturnResult(sqlmock.NewResult(0, 2))
 
 		err = db.DeleteMulti(ctx, keys)
 		if err != nil {
 			t.Errorf("unexpected error: %v", err)
 		}
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
 	})
 
 	t.Run("DeleteMulti_different_tables", func(t *testing.T) {
@@ -138,7 +138,7 @@ func TestDeleter(t *testing.T) {
 			record.NewKeyWithID("users", "u2"),
 		}
 
-		mock.ExpectExec("DELETE FROM users WHERE ID = ?").WithArgs("u1").WillReturnError(errors.New("delete error"))
+		mock.ExpectExec("DELETE FROM users WHERE ID IN (?, ?)").WithArgs("u1", "u2").WillReturnError(errors.New("delete error"))
 
 		err = db.DeleteMulti(ctx, keys)
 		if err == nil {
@@ -192,3 +192,141 @@ func TestDeleter(t *testing.T) {
 		}
 	})
 }
+
+func TestDeleteWhere(t *testing.T) {
+	ctx := context.Background()
+	where := dal.Comparison{Operator: dal.Equal, Left: dal.Field("Status"), Right: dal.Constant{Value: "archived"}}
+
+	t.Run("database", func(t *testing.T) {
+		sqlDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer closeDatabase(t, sqlDB)
+		db := dal.BackendOf(NewDatabase(sqlDB, newSchema(), DbOptions{})).(*database)
+
+		mock.ExpectExec("DELETE FROM orders WHERE Status = ?").
+			WithArgs("archived").
+			WillReturnResult(sqlmock.NewResult(0, 5))
+
+		count, err := db.DeleteWhere(ctx, "orders", where)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if count != 5 {
+			t.Errorf("count = %d, want 5", count)
+		}
+	})
+
+	t.Run("exec_error", func(t *testing.T) {
+		sqlDB, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer closeDatabase(t, sqlDB)
+		db := dal.BackendOf(NewDatabase(sqlDB, newSchema(), DbOptions{})).(*database)
+
+		mock.ExpectExec("DELETE FROM orders").WillReturnError(errors.New("boom"))
+
+		if _, err = db.DeleteWhere(ctx, "orders", where); err == nil {
+			t.Error("expected error")
+		}
+	})
+
+	t.Run("nil_condition", func(t *testing.T) {
+		if _, err := deleteWhere(ctx, DbOptions{}, nil, "orders", nil); err == nil {
+			t.Error("expected error for nil condition")
+		}
+	})
+}
+
+func TestDeleteMultiInSingleTable_Chunks(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeDatabase(t, sqlDB)
+	options := DbOptions{
+		Placeholder:   PlaceholderDollar,
+		MaxParameters: 2,
+		Recordsets: map[string]*Recordset{
+			"users": NewRecordset("users", Table, []dal.FieldRef{dal.Field("uid")}),
+		},
+	}
+	// Placeholders are numbered per statement.
+	mock.ExpectExec("DELETE FROM users WHERE uid IN ($1, $2)").WithArgs("u1", "u2").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("DELETE FROM users WHERE uid IN ($1)").WithArgs("u3").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	keys := []*record.Key{
+		record.NewKeyWithID("users", "u1"),
+		record.NewKeyWithID("users", "u2"),
+		record.NewKeyWithID("users", "u3"),
+	}
+	if err = deleteMultiInSingleTable(context.Background(), options, keys, sqlDB.ExecContext); err != nil {
+		t.Fatalf("deleteMultiInSingleTable: %v", err)
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDeleteMulti_Chunks(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("single_column_key", func(t *testing.T) {
+		sqlDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer closeDatabase(t, sqlDB)
+		db := NewDatabase(sqlDB, newSchema(), DbOptions{MaxParameters: 2})
+		mock.ExpectExec("DELETE FROM users WHERE ID IN (?, ?)").WithArgs("u1", "u2").
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec("DELETE FROM users WHERE ID IN (?, ?)").WithArgs("u3", "u4").
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec("DELETE FROM users WHERE ID IN (?)").WithArgs("u5").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		keys := make([]*record.Key, 5)
+		for i := range keys {
+			keys[i] = record.NewKeyWithID("users", fmt.Sprintf("u%d", i+1))
+		}
+		if err = db.DeleteMulti(ctx, keys); err != nil {
+			t.Fatalf("DeleteMulti: %v", err)
+		}
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("composite_key", func(t *testing.T) {
+		sqlDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer closeDatabase(t, sqlDB)
+		db := NewDatabase(sqlDB, newSchema(), DbOptions{
+			MaxParameters: 4,
+			Recordsets: map[string]*Recordset{
+				"memberships": NewRecordset("memberships", Table, []dal.FieldRef{dal.Field("TeamID"), dal.Field("UserID")}),
+			},
+		})
+		mock.ExpectExec("DELETE FROM memberships WHERE (TeamID = ? AND UserID = ?) OR (TeamID = ? AND UserID = ?)").
+			WithArgs("t1", 1, "t1", 2).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec("DELETE FROM memberships WHERE (TeamID = ? AND UserID = ?)").
+			WithArgs("t2", 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		var keys []*record.Key
+		for _, k := range [][2]any{{"t1", 1}, {"t1", 2}, {"t2", 1}} {
+			keys = append(keys, record.NewKeyWithFields("memberships",
+				record.FieldVal{Name: "TeamID", Value: k[0]}, record.FieldVal{Name: "UserID", Value: k[1]}))
+		}
+		if err = db.DeleteMulti(ctx, keys); err != nil {
+			t.Fatalf("DeleteMulti: %v", err)
+		}
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+}
@@ -0,0 +1,157 @@
+package dalgo2sql
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dal-go/dalgo/dal"
+)
+
+// Dialect identifies the SQL flavour of the underlying database for the
+// features that cannot be expressed in portable SQL, such as JSON functions.
+// The zero value (DialectGeneric) sticks to portable SQL and reports such
+// features as not supported.
+//
+// Dialect is independent of Placeholder: a PostgreSQL database is normally
+// configured with both DialectPostgres and PlaceholderDollar.
+type Dialect int
+
+const (
+	// DialectGeneric emits portable SQL only (default).
+	DialectGeneric Dialect = iota
+	// DialectSQLite targets SQLite (JSON1 functions are built in since 3.38).
+	DialectSQLite
+	// DialectPostgres targets PostgreSQL (JSON values are stored as JSONB).
+	DialectPostgres
+	// DialectMySQL targets MySQL 5.7+ and MariaDB.
+	DialectMySQL
+)
+
+// String returns a human-readable name of the dialect.
+func (d Dialect) String() string {
+	switch d {
+	case DialectSQLite:
+		return "sqlite"
+	case DialectPostgres:
+		return "postgres"
+	case DialectMySQL:
+		return "mysql"
+	default:
+		return "generic"
+	}
+}
+
+// JSONColumnType returns the column type recommended for fields mapped to
+// JSON (see WithJSONColumns), for use in DDL. SQLite and the generic dialect
+// store JSON as TEXT: SQLite's "JSON" type name has NUMERIC affinity.
+func (d Dialect) JSONColumnType() string {
+	switch d {
+	case DialectPostgres:
+		return "JSONB"
+	case DialectMySQL:
+		return "JSON"
+	default:
+		return "TEXT"
+	}
+}
+
+// MaxParameters returns the maximum number of bound parameters a single
+// statement may have. Statements with a parameter per key, such as the ones
+// issued by GetMulti and DeleteMulti, are split to stay under this limit.
+// SQLite allows 32766 parameters since 3.32 (999 before), PostgreSQL and
+// MySQL allow 65535. The generic dialect uses SQLite's historical limit of 999
+// as the lowest common denominator. Use DbOptions.MaxParameters to override it.
+func (d Dialect) MaxParameters() int {
+	switch d {
+	case DialectSQLite:
+		return 32766
+	case DialectPostgres, DialectMySQL:
+		return 65535
+	default:
+		return 999
+	}
+}
+
+// isUniqueViolation reports whether err is a unique or primary key
+// constraint violation reported by SQLite, PostgreSQL, MySQL or SQL Server.
+// Drivers do not share an error type, so the error message is inspected.
+func isUniqueViolation(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	for _, s := range []string{
+		"unique constraint", // SQLite, PostgreSQL
+		"must be unique",    // SQLite before 3.8: "PRIMARY KEY must be unique"
+		"is not unique",     // SQLite before 3.8: "column ID is not unique"
+		"duplicate key",     // PostgreSQL, SQL Server
+		"duplicate entry",   // MySQL, MariaDB
+	} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonSetExpr returns an SQL expression that sets the nested path of a JSON
+// value to the value bound to a single "?" placeholder. The bound value is
+// expected to be JSON text. The target is a column name or an expression
+// returned by a previous jsonSetExpr/jsonRemoveExpr call.
+func (d Dialect) jsonSetExpr(target string, path []string) (string, error) {
+	if err := validateJSONPath(path); err != nil {
+		return "", err
+	}
+	switch d {
+	case DialectSQLite:
+		return fmt.Sprintf("json_set(COALESCE(%s, '{}'), '%s', json(?))", target, jsonPathDollar(path)), nil
+	case DialectMySQL:
+		return fmt.Sprintf("JSON_SET(COALESCE(%s, JSON_OBJECT()), '%s', CAST(? AS JSON))", target, jsonPathDollar(path)), nil
+	case DialectPostgres:
+		return fmt.Sprintf("jsonb_set(COALESCE(%s, '{}'::jsonb), '%s', ?::jsonb, true)", target, jsonPathBraces(path)), nil
+	default:
+		return "", fmt.Errorf("%w: updating nested field path %s requires DbOptions.Dialect to be set",
+			dal.ErrNotSupported, strings.Join(path, "."))
+	}
+}
+
+// jsonRemoveExpr returns an SQL expression that removes the nested path from a JSON value.
+func (d Dialect) jsonRemoveExpr(target string, path []string) (string, error) {
+	if err := validateJSONPath(path); err != nil {
+		return "", err
+	}
+	switch d {
+	case DialectSQLite:
+		return fmt.Sprintf("json_remove(%s, '%s')", target, jsonPathDollar(path)), nil
+	case DialectMySQL:
+		return fmt.Sprintf("JSON_REMOVE(%s, '%s')", target, jsonPathDollar(path)), nil
+	case DialectPostgres:
+		return fmt.Sprintf("(%s) #- '%s'", target, jsonPathBraces(path)), nil
+	default:
+		return "", fmt.Errorf("%w: deleting nested field path %s requires DbOptions.Dialect to be set",
+			dal.ErrNotSupported, strings.Join(path, "."))
+	}
+}
+
+// validateJSONPath ensures path segments can be embedded into an SQL string literal.
+func validateJSONPath(path []string) error {
+	if len(path) == 0 {
+		return fmt.Errorf("JSON path is empty")
+	}
+	for _, p := range path {
+		if err := validateIdentifier(p); err != nil {
+			return fmt.Errorf("invalid JSON path segment: %w", err)
+		}
+	}
+	return nil
+}
+
+// jsonPathDollar formats a path as "$.a.b" (SQLite, MySQL).
+func jsonPathDollar(path []string) string {
+	return "$." + strings.Join(path, ".")
+}
+
+// jsonPathBraces formats a path as "{a,b}" (PostgreSQL).
+func jsonPathBraces(path []string) string {
+	return "{" + strings.Join(path, ",") + "}"
+}
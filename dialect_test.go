@@ -0,0 +1,80 @@
+package dalgo2sql
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDialect_String(t *testing.T) {
+	for d, want := range map[Dialect]string{
+		DialectGeneric:  "generic",
+		DialectSQLite:   "sqlite",
+		DialectPostgres: "postgres",
+		DialectMySQL:    "mysql",
+	} {
+		if got := d.String(); got != want {
+			t.Errorf("Dialect(%d).String() = %q, want %q", d, got, want)
+		}
+	}
+}
+
+func TestDialect_jsonPathValidation(t *testing.T) {
+	if _, err := DialectSQLite.jsonSetExpr("Profile", []string{"a'); DROP TABLE x; --"}); err == nil {
+		t.Error("expected error for unsafe JSON path segment")
+	}
+	if _, err := DialectPostgres.jsonRemoveExpr("Profile", nil); err == nil {
+		t.Error("expected error for empty JSON path")
+	}
+}
+
+func TestDialect_MaxParameters(t *testing.T) {
+	for d, want := range map[Dialect]int{
+		DialectGeneric:  999,
+		DialectSQLite:   32766,
+		DialectPostgres: 65535,
+		DialectMySQL:    65535,
+	} {
+		if got := d.MaxParameters(); got != want {
+			t.Errorf("%v.MaxParameters() = %d, want %d", d, got, want)
+		}
+	}
+}
+
+func TestDbOptions_keysPerStatement(t *testing.T) {
+	tests := []struct {
+		name         string
+		options      DbOptions
+		paramsPerKey int
+		want         int
+	}{
+		{name: "generic", options: DbOptions{}, paramsPerKey: 1, want: 999},
+		{name: "composite_key", options: DbOptions{Dialect: DialectPostgres}, paramsPerKey: 2, want: 32767},
+		{name: "override", options: DbOptions{Dialect: DialectSQLite, MaxParameters: 999}, paramsPerKey: 3, want: 333},
+		{name: "at_least_one", options: DbOptions{MaxParameters: 1}, paramsPerKey: 3, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.options.keysPerStatement(tt.paramsPerKey); got != tt.want {
+				t.Errorf("keysPerStatement(%d) = %d, want %d", tt.paramsPerKey, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsUniqueViolation(t *testing.T) {
+	for msg, want := range map[string]bool{
+		"constraint failed: UNIQUE constraint failed: users.ID (1555)":     true,
+		`pq: duplicate key value violates unique constraint "users_pkey"`:  true,
+		"Error 1062 (23000): Duplicate entry 'u1' for key 'users.PRIMARY'": true,
+		"column ID is not unique":                false,
+		"NOT NULL constraint failed: users.Name": false,
+		"Cannot insert duplicate key row in object 'dbo.users' with unique index 'ix'": true,
+	} {
+		if got := isUniqueViolation(errors.New(msg)); got != want {
+			t.Errorf("isUniqueViolation(%q) = %v, want %v", msg, got, want)
+		}
+	}
+	if isUniqueViolation(nil) {
+		t.Error("isUniqueViolation(nil) = true")
+	}
+}
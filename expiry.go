@@ -0,0 +1,241 @@
+package dalgo2sql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dal-go/dalgo/dal"
+	"github.com/dal-go/record"
+)
+
+// DefaultExpiryColumn is used when WithExpiry is given an empty column.
+const DefaultExpiryColumn = "expires_at"
+
+// ExpiryFilter is the name of the recordset filter that hides expired rows.
+// WithoutFilters(ctx, ExpiryFilter) makes them visible.
+const ExpiryFilter = "expiry"
+
+// OpSweep is the operation statements of ExpirySweeper are reported under.
+const OpSweep Operation = "Sweep"
+
+// WithExpiry declares a column holding the time rows of a recordset expire
+// at, NULL for rows that never expire. Rows that expired by DbOptions.Clock
+// are not found by Get, Exists and queries, nor written by Set, Update and
+// Delete, like rows hidden by a recordset filter named ExpiryFilter. Inserting
+// a record with the key of an expired row replaces the row.
+//
+// Expired rows are deleted by an ExpirySweeper, if one is started.
+func WithExpiry(column string) RecordsetOption {
+	if column == "" {
+		column = DefaultExpiryColumn
+	}
+	return func(rs *Recordset) {
+		rs.expiry = column
+	}
+}
+
+// applyExpiry hides rows of the recordset that expired by now.
+func (v *Recordset) applyExpiry(ctx context.Context, now time.Time, s *statementScope) {
+	if v == nil || v.expiry == "" || filterDisabled(ctx, ExpiryFilter) {
+		return
+	}
+	s.conditions = append(s.conditions, fmt.Sprintf("(%s IS NULL OR %s > ?)", v.expiry, v.expiry))
+	s.args = append(s.args, now)
+}
+
+// deleteExpiredOnInsert deletes an expired row with the key of a record to be
+// inserted, so the insert does not fail on a key no one can see anymore.
+func deleteExpiredOnInsert(ctx context.Context, options DbOptions, record record.Record, exec statementExecutor) error {
+	key := record.Key()
+	collection := getRecordsetName(key)
+	rs := options.Recordsets[collection]
+	if key.ID == nil || rs == nil || rs.expiry == "" {
+		return nil
+	}
+	scope, err := options.scope(WithoutFilters(ctx), collection)
+	if err != nil {
+		return err
+	}
+	pk := options.PrimaryKeyFieldNames(key)
+	if len(pk) == 0 {
+		return fmt.Errorf("primary key is not defined for %s", collection)
+	}
+	args, err := primaryKeyArgs(newColumnMapper(options, collection), pk, key)
+	if err != nil {
+		return err
+	}
+	conditions := make([]string, len(pk))
+	for i, name := range pk {
+		conditions[i] = name + " = ?"
+	}
+	args = append(append(args, options.now()), scope.args...)
+	text := options.cachedSQL(
+		func() string {
+			return statementShape("deleteExpired", scope.shape(), rs.expiry, strings.Join(conditions, ","))
+		},
+		func() string {
+			return options.Placeholder.rewritePlaceholders(fmt.Sprintf("DELETE FROM %s WHERE %s AND %s <= ?%s",
+				scope.table, strings.Join(conditions, " AND "), rs.expiry, scope.where()))
+		})
+	result, err := options.traceStatement(collection, exec)(ctx, text, args...)
+	if err != nil {
+		return fmt.Errorf("failed to delete expired %s: %w", key, err)
+	}
+	if count, err := result.RowsAffected(); err == nil {
+		options.count(ctx, collection, CounterDeletes, count)
+	}
+	return nil
+}
+
+// DefaultExpiryBatchSize is used when ExpirySweeperOptions.BatchSize is not set.
+const DefaultExpiryBatchSize = 500
+
+// ExpirySweeperOptions tune an ExpirySweeper. Zero values use defaults.
+type ExpirySweeperOptions struct {
+	// BatchSize is the maximum number of rows deleted by a statement, which
+	// keeps locks short on large tables.
+	BatchSize int
+	// Interval is the wait between sweeps. Defaults to a minute.
+	Interval time.Duration
+	// OnError is called with errors of sweeps, which are retried at the next interval.
+	OnError func(err error)
+}
+
+// ExpirySweeper deletes expired rows of the recordsets declared WithExpiry
+// in the background. Deleted rows are counted as CounterDeletes of OpSweep
+// and are not recorded in the history or the changelog.
+type ExpirySweeper struct {
+	db      *database
+	options ExpirySweeperOptions
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// StartExpirySweeper starts sweeping expired rows of a database created by
+// NewDatabase right away and then at every interval, until Stop is called
+// or the context is done. The context also scopes the sweeps: in the
+// TenantSchema and TenantTablePrefix modes of Tenancy it must carry the
+// tenant to sweep, while in TenantColumn mode a context without a tenant
+// sweeps the rows of all tenants.
+func StartExpirySweeper(ctx context.Context, db dal.DB, options ExpirySweeperOptions) (*ExpirySweeper, error) {
+	s, err := newExpirySweeper(db, options)
+	if err != nil {
+		return nil, err
+	}
+	ctx, s.cancel = context.WithCancel(ctx)
+	s.done = make(chan struct{})
+	go s.run(ctx)
+	return s, nil
+}
+
+func newExpirySweeper(db dal.DB, options ExpirySweeperOptions) (*ExpirySweeper, error) {
+	dtb, ok := dal.BackendOf(db).(*database)
+	if !ok {
+		return nil, fmt.Errorf("%w: expiry sweeper of %T", dal.ErrNotSupported, dal.BackendOf(db))
+	}
+	if options.BatchSize <= 0 {
+		options.BatchSize = DefaultExpiryBatchSize
+	}
+	if options.Interval <= 0 {
+		options.Interval = time.Minute
+	}
+	return &ExpirySweeper{db: dtb, options: options}, nil
+}
+
+// Stop stops the sweeper and waits for a sweep in progress to be cancelled.
+// It is meant to be called before the *sql.DB of the database is closed.
+func (s *ExpirySweeper) Stop() {
+	s.cancel()
+	<-s.done
+}
+
+func (s *ExpirySweeper) run(ctx context.Context) {
+	defer close(s.done)
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		if _, err := s.Sweep(ctx); err != nil && ctx.Err() == nil && s.options.OnError != nil {
+			s.options.OnError(err)
+		}
+		timer.Reset(s.options.Interval)
+	}
+}
+
+// Sweep deletes the rows that have expired, a batch at a time,
+// and returns the number of deleted rows.
+func (s *ExpirySweeper) Sweep(ctx context.Context) (int64, error) {
+	ctx = withOperation(ctx, OpSweep)
+	options := s.db.options
+	var total int64
+	var errs []error
+	for _, name := range slices.Sorted(maps.Keys(options.Recordsets)) {
+		if rs := options.Recordsets[name]; rs == nil || rs.expiry == "" {
+			continue
+		}
+		for {
+			n, err := s.sweepBatch(ctx, name)
+			total += n
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to sweep expired rows of %s: %w", name, err))
+				break
+			}
+			if n < int64(s.options.BatchSize) {
+				break
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return total, errors.Join(errs...)
+}
+
+func (s *ExpirySweeper) sweepBatch(ctx context.Context, recordset string) (int64, error) {
+	options := s.db.options
+	rs := options.Recordsets[recordset]
+	scope := statementScope{table: recordset}
+	if _, ok := TenantFromContext(ctx); ok || options.Tenancy == nil || options.Tenancy.Mode != TenantColumn {
+		var err error
+		if scope, err = options.scope(WithoutFilters(ctx), recordset); err != nil {
+			return 0, err
+		}
+	}
+	expired := fmt.Sprintf("%s <= ?%s", rs.expiry, scope.where())
+	var text string
+	if options.Dialect == DialectMySQL {
+		// MySQL can neither LIMIT an IN subquery nor select from the table it deletes from.
+		text = fmt.Sprintf("DELETE FROM %s WHERE %s ORDER BY %s LIMIT %d", scope.table, expired, rs.expiry, s.options.BatchSize)
+	} else {
+		pk := rs.PrimaryKeyFieldNames()
+		if len(pk) == 0 {
+			return 0, fmt.Errorf("primary key is not defined for %s", recordset)
+		}
+		columns := strings.Join(pk, ", ")
+		if len(pk) > 1 {
+			columns = "(" + columns + ")"
+		}
+		text = fmt.Sprintf("DELETE FROM %s WHERE %s IN (SELECT %s FROM %s WHERE %s LIMIT %d)",
+			scope.table, columns, strings.Join(pk, ", "), scope.table, expired, s.options.BatchSize)
+	}
+	args := append([]any{options.now()}, scope.args...)
+	result, err := options.traceStatement(recordset, s.db.db.ExecContext)(ctx, options.Placeholder.rewritePlaceholders(text), args...)
+	if err != nil {
+		return 0, err
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	options.count(ctx, recordset, CounterDeletes, count)
+	return count, nil
+}
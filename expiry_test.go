@@ -0,0 +1,165 @@
+package dalgo2sql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dal-go/dalgo/dal"
+	dalrecord "github.com/dal-go/record"
+	"github.com/dal-go/record/update"
+)
+
+func TestExpiry_SQLite(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	metrics := NewInMemoryMetrics()
+	options := DbOptions{
+		Clock:   func() time.Time { return now },
+		Metrics: metrics,
+		Recordsets: map[string]*Recordset{
+			"sessions": NewRecordset("sessions", Table, []dal.FieldRef{dal.Field("ID")}, WithExpiry("")),
+		},
+	}
+	sqlDB := openTestSQLiteDB(t, `CREATE TABLE sessions (ID TEXT PRIMARY KEY, Name TEXT, expires_at TIMESTAMP);`)
+	for id, expiresAt := range map[string]any{"live": now.Add(time.Hour), "expired": now.Add(-time.Hour), "forever": nil} {
+		if _, err := sqlDB.Exec(`INSERT INTO sessions (ID, Name, expires_at) VALUES (?, ?, ?)`, id, id, expiresAt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dalDB := NewDatabase(sqlDB, newSchema(), options)
+	db := dal.BackendOf(dalDB).(*database)
+	ctx := context.Background()
+	key := func(id string) *dalrecord.Key { return dalrecord.NewKeyWithID("sessions", id) }
+	exists := func(t *testing.T, ctx context.Context, id string) bool {
+		t.Helper()
+		exists, err := db.Exists(ctx, key(id))
+		if err != nil {
+			t.Fatalf("Exists: %v", err)
+		}
+		return exists
+	}
+	count := func(t *testing.T) (n int) {
+		t.Helper()
+		if err := sqlDB.QueryRow(`SELECT COUNT(*) FROM sessions`).Scan(&n); err != nil {
+			t.Fatalf("COUNT: %v", err)
+		}
+		return n
+	}
+
+	t.Run("hidden", func(t *testing.T) {
+		for id, want := range map[string]bool{"live": true, "expired": false, "forever": true} {
+			if got := exists(t, ctx, id); got != want {
+				t.Errorf("Exists(%s) = %v, want %v", id, got, want)
+			}
+		}
+		if !exists(t, WithoutFilters(ctx, ExpiryFilter), "expired") {
+			t.Error("expected WithoutFilters to show the expired row")
+		}
+		if err := db.Update(ctx, key("expired"), []update.Update{update.ByFieldName("Name", "x")}); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		var name string
+		if err := sqlDB.QueryRow(`SELECT Name FROM sessions WHERE ID = 'expired'`).Scan(&name); err != nil || name != "expired" {
+			t.Errorf("Name = %q, %v; want the expired row to be left alone", name, err)
+		}
+	})
+
+	t.Run("insert_replaces_expired", func(t *testing.T) {
+		type session struct {
+			Name string
+		}
+		if err := db.Insert(ctx, dalrecord.NewRecordWithData(key("expired"), &session{Name: "again"})); err != nil {
+			t.Fatalf("Insert: %v", err)
+		}
+		if !exists(t, ctx, "expired") {
+			t.Error("expected the inserted row to be found")
+		}
+		if err := db.Insert(ctx, dalrecord.NewRecordWithData(key("live"), &session{Name: "duplicate"})); err == nil {
+			t.Error("expected the insert of a live key to fail")
+		}
+	})
+
+	t.Run("Sweep", func(t *testing.T) {
+		now = now.Add(2 * time.Hour)
+		if _, err := sqlDB.Exec(`INSERT INTO sessions (ID, Name, expires_at) VALUES ('old', 'old', ?)`, now.Add(-time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+		s, err := newExpirySweeper(dalDB, ExpirySweeperOptions{BatchSize: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n, err := s.Sweep(ctx); err != nil || n != 2 {
+			t.Fatalf("Sweep = %d, %v; want the live and old rows to be deleted", n, err)
+		}
+		if n := count(t); n != 2 {
+			t.Errorf("%d rows, want 2", n)
+		}
+		if n := metrics.Snapshot().Count(OpSweep, "sessions", CounterDeletes); n != 2 {
+			t.Errorf("%d sweep deletes, want 2", n)
+		}
+	})
+
+	t.Run("StartExpirySweeper", func(t *testing.T) {
+		now = now.Add(-3 * time.Hour)
+		if _, err := sqlDB.Exec(`INSERT INTO sessions (ID, Name, expires_at) VALUES ('gone', 'gone', ?)`, now.Add(-time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+		s, err := StartExpirySweeper(ctx, dalDB, ExpirySweeperOptions{Interval: time.Millisecond})
+		if err != nil {
+			t.Fatal(err)
+		}
+		deadline := time.Now().Add(5 * time.Second)
+		for count(t) != 2 && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		s.Stop()
+		if n := count(t); n != 2 {
+			t.Errorf("%d rows, want the expired one to be swept", n)
+		}
+	})
+}
+
+func TestExpirySweeper_Statements(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, tt := range []struct {
+		name    string
+		options DbOptions
+		text    string
+	}{
+		{
+			name:    "postgres",
+			options: DbOptions{Dialect: DialectPostgres, Placeholder: PlaceholderDollar},
+			text:    `DELETE FROM tokens WHERE id IN \(SELECT id FROM tokens WHERE valid_until <= \$1 LIMIT 10\)`,
+		},
+		{
+			name:    "mysql",
+			options: DbOptions{Dialect: DialectMySQL},
+			text:    `DELETE FROM tokens WHERE valid_until <= \? ORDER BY valid_until LIMIT 10`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer closeDatabase(t, sqlDB)
+			tt.options.Clock = func() time.Time { return now }
+			tt.options.Recordsets = map[string]*Recordset{
+				"tokens": NewRecordset("tokens", Table, []dal.FieldRef{dal.Field("id")}, WithExpiry("valid_until")),
+			}
+			s, err := newExpirySweeper(NewDatabase(sqlDB, newSchema(), tt.options), ExpirySweeperOptions{BatchSize: 10})
+			if err != nil {
+				t.Fatal(err)
+			}
+			mock.ExpectExec(tt.text).WithArgs(now).WillReturnResult(sqlmock.NewResult(0, 10))
+			mock.ExpectExec(tt.text).WithArgs(now).WillReturnResult(sqlmock.NewResult(0, 3))
+			if n, err := s.Sweep(context.Background()); err != nil || n != 13 {
+				t.Errorf("Sweep = %d, %v; want 13", n, err)
+			}
+			if err = mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package dalgo2sql
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/dal-go/dalgo/dal"
+)
+
+// RecordsetFilter returns a condition that rows of a recordset must match to
+// be read, updated or deleted, e.g. a region taken from the context:
+//
+//	func(ctx context.Context) (dal.Condition, error) {
+//		return dal.WhereField("region", dal.Equal, regionFromContext(ctx)), nil
+//	}
+//
+// It is evaluated every time a statement on the recordset is built.
+// A nil condition does not filter rows.
+type RecordsetFilter func(ctx context.Context) (dal.Condition, error)
+
+// StaticFilter returns a RecordsetFilter with a condition that does not
+// depend on the context, e.g. deleted_at IS NULL:
+//
+//	StaticFilter(dal.Comparison{Operator: dal.Equal, Left: dal.Field("deleted_at"), Right: dal.Constant{Value: nil}})
+func StaticFilter(condition dal.Condition) RecordsetFilter {
+	return func(context.Context) (dal.Condition, error) {
+		return condition, nil
+	}
+}
+
+type namedFilter struct {
+	name   string
+	filter RecordsetFilter
+}
+
+// WithFilter adds a named default predicate to a recordset. Filters are
+// applied to Get, GetMulti, Exists, Set, Update, Delete and their Multi and
+// Where variants, and to structured queries on the recordset. Inserts and
+// text queries are not filtered. See WithoutFilters.
+func WithFilter(name string, filter RecordsetFilter) RecordsetOption {
+	return func(rs *Recordset) {
+		rs.filters = append(rs.filters, namedFilter{name: name, filter: filter})
+	}
+}
+
+type withoutFiltersContextKey struct{}
+
+// withoutFilters holds the names of disabled filters, or nil if all filters are disabled.
+type withoutFilters []string
+
+// WithoutFilters returns a context that disables the named recordset filters,
+// or all of them if no names are given, e.g. for an admin listing of deleted
+// rows. Tenancy is not affected.
+func WithoutFilters(ctx context.Context, names ...string) context.Context {
+	if len(names) > 0 {
+		if disabled, ok := ctx.Value(withoutFiltersContextKey{}).(withoutFilters); ok {
+			if disabled == nil {
+				return ctx
+			}
+			names = append(slices.Clone(disabled), names...)
+		}
+	}
+	return context.WithValue(ctx, withoutFiltersContextKey{}, withoutFilters(names))
+}
+
+func filterDisabled(ctx context.Context, name string) bool {
+	if ctx == nil {
+		return false
+	}
+	disabled, ok := ctx.Value(withoutFiltersContextKey{}).(withoutFilters)
+	return ok && (disabled == nil || slices.Contains(disabled, name))
+}
+
+// applyFilters adds the conditions of the recordset filters enabled in the context to s.
+func (v *Recordset) applyFilters(ctx context.Context, s *statementScope) error {
+	if v == nil {
+		return nil
+	}
+	for _, f := range v.filters {
+		if filterDisabled(ctx, f.name) {
+			continue
+		}
+		condition, err := f.filter(ctx)
+		if err != nil {
+			return fmt.Errorf("filter %s: %w", f.name, err)
+		}
+		if condition == nil {
+			continue
+		}
+		text, args, err := buildCondition(condition)
+		if err != nil {
+			return fmt.Errorf("filter %s: %w", f.name, err)
+		}
+		switch condition.(type) {
+		case dal.GroupCondition, *dal.GroupCondition:
+			text = "(" + text + ")"
+		}
+		s.conditions = append(s.conditions, text)
+		s.args = append(s.args, args...)
+	}
+	return nil
+}
@@ -0,0 +1,250 @@
+package dalgo2sql
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/dal-go/dalgo/dal"
+	dalrecord "github.com/dal-go/record"
+	"github.com/dal-go/record/update"
+)
+
+func TestWithoutFilters(t *testing.T) {
+	ctx := context.Background()
+	if filterDisabled(ctx, "a") {
+		t.Error("expected filters to be enabled by default")
+	}
+	named := WithoutFilters(ctx, "a")
+	if !filterDisabled(named, "a") || filterDisabled(named, "b") {
+		t.Error("expected only filter a to be disabled")
+	}
+	if more := WithoutFilters(named, "b"); !filterDisabled(more, "a") || !filterDisabled(more, "b") {
+		t.Error("expected names to accumulate")
+	}
+	all := WithoutFilters(ctx)
+	if !filterDisabled(all, "a") || !filterDisabled(WithoutFilters(all, "b"), "c") {
+		t.Error("expected all filters to be disabled")
+	}
+}
+
+type regionContextKey struct{}
+
+// regionFilter filters by the region in the context, if any.
+func regionFilter(ctx context.Context) (dal.Condition, error) {
+	region, ok := ctx.Value(regionContextKey{}).(string)
+	if !ok {
+		return nil, nil
+	}
+	return dal.Comparison{Operator: dal.Equal, Left: dal.Field("region"), Right: dal.Constant{Value: region}}, nil
+}
+
+var notDeletedFilter = StaticFilter(dal.Comparison{Operator: dal.Equal, Left: dal.Field("deleted_at"), Right: dal.Constant{Value: nil}})
+
+func TestRecordset_applyFilters(t *testing.T) {
+	eu := context.WithValue(context.Background(), regionContextKey{}, "eu")
+	group := dal.NewQueryBuilder(dal.From(dal.NewRootCollectionRef("items", ""))).
+		WhereField("a", dal.Equal, 1).
+		WhereField("b", dal.Equal, 2).
+		SelectIntoRecordset().Where()
+	failure := errors.New("no region")
+	for _, tt := range []struct {
+		name           string
+		filters        []RecordsetOption
+		ctx            context.Context
+		wantConditions []string
+		wantArgs       []any
+		wantErr        error
+	}{
+		{
+			name:           "static",
+			filters:        []RecordsetOption{WithFilter("not_deleted", notDeletedFilter)},
+			ctx:            context.Background(),
+			wantConditions: []string{"deleted_at IS NULL"},
+		},
+		{
+			name:           "from_context",
+			filters:        []RecordsetOption{WithFilter("not_deleted", notDeletedFilter), WithFilter("region", regionFilter)},
+			ctx:            eu,
+			wantConditions: []string{"deleted_at IS NULL", "region = ?"},
+			wantArgs:       []any{"eu"},
+		},
+		{
+			name:           "nil_condition",
+			filters:        []RecordsetOption{WithFilter("region", regionFilter)},
+			ctx:            context.Background(),
+			wantConditions: nil,
+		},
+		{
+			name:           "group",
+			filters:        []RecordsetOption{WithFilter("group", StaticFilter(group))},
+			ctx:            context.Background(),
+			wantConditions: []string{"((a = ?) AND (b = ?))"},
+			wantArgs:       []any{1, 2},
+		},
+		{
+			name:           "disabled",
+			filters:        []RecordsetOption{WithFilter("not_deleted", notDeletedFilter), WithFilter("region", regionFilter)},
+			ctx:            WithoutFilters(eu, "not_deleted"),
+			wantConditions: []string{"region = ?"},
+			wantArgs:       []any{"eu"},
+		},
+		{
+			name: "error",
+			filters: []RecordsetOption{WithFilter("region", func(context.Context) (dal.Condition, error) {
+				return nil, failure
+			})},
+			ctx:     context.Background(),
+			wantErr: failure,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rs := NewRecordset("items", Table, []dal.FieldRef{dal.Field("ID")}, tt.filters...)
+			var s statementScope
+			err := rs.applyFilters(tt.ctx, &s)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(s.conditions, tt.wantConditions) || !reflect.DeepEqual(s.args, tt.wantArgs) {
+				t.Errorf("got %q %v, want %q %v", s.conditions, s.args, tt.wantConditions, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestRecordsetFilters_SQLite(t *testing.T) {
+	options := DbOptions{
+		Recordsets: map[string]*Recordset{
+			"items": NewRecordset("items", Table, []dal.FieldRef{dal.Field("ID")},
+				WithFilter("not_deleted", notDeletedFilter),
+				WithFilter("region", regionFilter),
+			),
+		},
+	}
+	sqlDB := openTestSQLiteDB(t, `
+		CREATE TABLE items (ID TEXT PRIMARY KEY, region TEXT, deleted_at TIMESTAMP, Name TEXT);
+		INSERT INTO items VALUES ('eu1', 'eu', NULL, 'visible'), ('us1', 'us', NULL, 'other region'), ('eu2', 'eu', '2024-01-01', 'deleted');`)
+	db := dal.BackendOf(NewDatabase(sqlDB, newSchema(), options)).(*database)
+	ctx := context.WithValue(context.Background(), regionContextKey{}, "eu")
+
+	type item struct {
+		Name string
+	}
+	key := func(id string) *dalrecord.Key { return dalrecord.NewKeyWithID("items", id) }
+	get := func(ctx context.Context, id string) error {
+		return db.Get(ctx, dalrecord.NewRecordWithData(key(id), &item{}))
+	}
+	name := func(t *testing.T, id string) string {
+		t.Helper()
+		var name string
+		if err := sqlDB.QueryRow(`SELECT Name FROM items WHERE ID = ?`, id).Scan(&name); err != nil {
+			t.Fatalf("SELECT %s: %v", id, err)
+		}
+		return name
+	}
+	isNotFound := func(err error) bool { return errors.Is(err, dalrecord.ErrRecordNotFound) }
+
+	t.Run("Get", func(t *testing.T) {
+		if err := get(ctx, "eu1"); err != nil {
+			t.Errorf("eu1: %v", err)
+		}
+		for _, id := range []string{"us1", "eu2"} {
+			if err := get(ctx, id); !isNotFound(err) {
+				t.Errorf("%s: expected not found, got %v", id, err)
+			}
+		}
+		if err := get(WithoutFilters(ctx, "region"), "us1"); err != nil {
+			t.Errorf("us1 without region filter: %v", err)
+		}
+		if err := get(WithoutFilters(ctx, "region"), "eu2"); !isNotFound(err) {
+			t.Errorf("eu2 without region filter: expected not found, got %v", err)
+		}
+		if err := get(WithoutFilters(ctx), "eu2"); err != nil {
+			t.Errorf("eu2 without filters: %v", err)
+		}
+	})
+
+	t.Run("GetMulti", func(t *testing.T) {
+		records := []dalrecord.Record{
+			dalrecord.NewRecordWithData(key("eu1"), &item{}),
+			dalrecord.NewRecordWithData(key("us1"), &item{}),
+			dalrecord.NewRecordWithData(key("eu2"), &item{}),
+		}
+		if err := db.GetMulti(ctx, records); err != nil {
+			t.Fatalf("GetMulti: %v", err)
+		}
+		for i, want := range []bool{true, false, false} {
+			if records[i].Exists() != want {
+				t.Errorf("%v: exists = %v, want %v", records[i].Key(), records[i].Exists(), want)
+			}
+		}
+	})
+
+	t.Run("Exists", func(t *testing.T) {
+		for id, want := range map[string]bool{"eu1": true, "us1": false, "eu2": false} {
+			if exists, err := db.Exists(ctx, key(id)); err != nil || exists != want {
+				t.Errorf("%s: %v, %v; want %v", id, exists, err, want)
+			}
+		}
+	})
+
+	t.Run("query", func(t *testing.T) {
+		q := dal.NewQueryBuilder(dal.From(dal.NewRootCollectionRef("items", ""))).SelectIntoRecordset()
+		reader, err := db.ExecuteQueryToRecordsetReader(ctx, q)
+		if err != nil {
+			t.Fatalf("ExecuteQueryToRecordsetReader: %v", err)
+		}
+		defer func() { _ = reader.Close() }()
+		var n int
+		for ; err == nil; n++ {
+			_, _, err = reader.Next()
+		}
+		if !errors.Is(err, dal.ErrNoMoreRecords) {
+			t.Fatalf("Next: %v", err)
+		}
+		if n-1 != 1 {
+			t.Errorf("read %d rows, want 1", n-1)
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		updates := []update.Update{update.ByFieldName("Name", "changed")}
+		if err := db.Update(ctx, key("us1"), updates); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		if got := name(t, "us1"); got != "other region" {
+			t.Errorf("us1 Name = %q, expected a filtered out row not to be updated", got)
+		}
+		everything := dal.Comparison{Operator: dal.GreaterThen, Left: dal.Field("Name"), Right: dal.Constant{Value: ""}}
+		if n, err := db.UpdateWhere(ctx, "items", everything, updates); err != nil || n != 1 {
+			t.Errorf("UpdateWhere = %d, %v; want 1", n, err)
+		}
+		if got := name(t, "eu1"); got != "changed" {
+			t.Errorf("eu1 Name = %q, want changed", got)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		if err := db.Delete(ctx, key("us1")); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		if err := db.DeleteMulti(ctx, []*dalrecord.Key{key("us1"), key("eu2")}); err != nil {
+			t.Fatalf("DeleteMulti: %v", err)
+		}
+		everything := dal.Comparison{Operator: dal.GreaterThen, Left: dal.Field("Name"), Right: dal.Constant{Value: ""}}
+		if n, err := db.DeleteWhere(ctx, "items", everything); err != nil || n != 1 {
+			t.Errorf("DeleteWhere = %d, %v; want 1", n, err)
+		}
+		var n int
+		if err := sqlDB.QueryRow(`SELECT COUNT(*) FROM items`).Scan(&n); err != nil || n != 2 {
+			t.Errorf("%d rows left, %v; want the 2 filtered out rows", n, err)
+		}
+	})
+}
@@ -3,45 +3,63 @@ package dalgo2sql
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"encoding"
 	"errors"
 	"fmt"
+	"math"
 	"reflect"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/dal-go/dalgo/dal"
 	dalrecord "github.com/dal-go/record"
-	"github.com/georgysavva/scany/v2/sqlscan"
 )
 
-type queryExecutor = func(query string, args ...interface{}) (*sql.Rows, error)
+type queryExecutor = func(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
 
 func (dtb *database) Exists(ctx context.Context, key *dalrecord.Key) (exists bool, err error) {
-	return executeExists(ctx, dtb.options, key, dtb.db.Query)
+	_, query := dtb.reader(ctx)
+	return executeExists(withOperation(ctx, OpExists), dtb.options, key, query)
 }
 
 func (t transaction) Exists(ctx context.Context, key *dalrecord.Key) (exists bool, err error) {
-	return executeExists(ctx, t.sqlOptions, key, t.tx.Query)
+	return executeExists(withOperation(ctx, OpExists), t.sqlOptions, key, t.query)
 }
 
 func (dtb *database) Get(ctx context.Context, record dalrecord.Record) error {
-	return getSingle(ctx, dtb.options, record, dtb.db.Query)
+	_, query := dtb.reader(ctx)
+	return getSingle(withOperation(ctx, OpGet), dtb.options, record, query)
 }
 
 func (t transaction) Get(ctx context.Context, record dalrecord.Record) error {
-	return getSingle(ctx, t.sqlOptions, record, t.tx.Query)
+	ctx = withOperation(ctx, OpGet)
+	if loaded, err := t.identities.load(ctx, record); loaded {
+		return err
+	}
+	return getSingle(t.identities.reading(ctx), t.sqlOptions, record, t.query)
 }
 
 func (dtb *database) GetMulti(ctx context.Context, records []dalrecord.Record) error {
-	return getMulti(ctx, dtb.options, records, dtb.db.Query)
+	_, query := dtb.reader(ctx)
+	return getMulti(withOperation(ctx, OpGetMulti), dtb.options, records, query, dtb.options.getMultiParallelism())
 }
 
 func (t transaction) GetMulti(ctx context.Context, records []dalrecord.Record) error {
-	return getMulti(ctx, t.sqlOptions, records, t.tx.Query)
+	ctx = withOperation(ctx, OpGetMulti)
+	records, err := t.identities.loadMulti(ctx, records)
+	if err != nil {
+		return err
+	}
+	// A transaction is bound to a single connection, so recordsets are read one after another.
+	return getMulti(t.identities.reading(ctx), t.sqlOptions, records, t.query, 1)
 }
 
-func executeExists(_ context.Context, options DbOptions, key *dalrecord.Key, exec queryExecutor) (exists bool, err error) {
+func executeExists(ctx context.Context, options DbOptions, key *dalrecord.Key, exec queryExecutor) (exists bool, err error) {
 	rsName := getRecordsetName(key)
-	queryText := fmt.Sprintf("SELECT 1 FROM %s WHERE ", rsName)
+	exec = options.traceQuery(rsName, exec)
 
 	pk := options.PrimaryKeyFieldNames(key)
 	if len(pk) == 0 {
@@ -51,10 +69,23 @@ func executeExists(_ context.Context, options DbOptions, key *dalrecord.Key, exe
 		err = fmt.Errorf("%w: select by composite primary key is not supported yet", dal.ErrNotImplementedYet)
 		return
 	}
-	queryText += pk[0] + " = " + options.Placeholder.placeholder(1)
+	scope, err := options.scope(ctx, rsName)
+	if err != nil {
+		return false, err
+	}
+	queryText := options.cachedSQL(
+		func() string { return statementShape("exists", scope.shape(), pk[0]) },
+		func() string {
+			return options.Placeholder.rewritePlaceholders(
+				fmt.Sprintf("SELECT 1 FROM %s WHERE %s = ?%s", scope.table, pk[0], scope.where()))
+		})
 
+	args, err := primaryKeyArgs(newColumnMapper(options, rsName), pk, key)
+	if err != nil {
+		return false, err
+	}
 	var rows *sql.Rows
-	if rows, err = exec(queryText, key.ID); err != nil {
+	if rows, err = exec(ctx, queryText, append(args, scope.args...)...); err != nil {
 		return
 	}
 	defer func() {
@@ -66,15 +97,15 @@ func executeExists(_ context.Context, options DbOptions, key *dalrecord.Key, exe
 	return true, nil
 }
 
-func getSingle(_ context.Context, options DbOptions, record dalrecord.Record, exec queryExecutor) error {
+func getSingle(ctx context.Context, options DbOptions, record dalrecord.Record, exec queryExecutor) error {
 	key := record.Key()
 	rsName := getRecordsetName(key)
+	exec = options.traceQuery(rsName, exec)
 	fields := getSelectFields(false, options, record)
 	fieldsStr := strings.Join(fields, ", ")
 	if fieldsStr == "" {
 		fieldsStr = "1"
 	}
-	queryText := fmt.Sprintf("SELECT %s FROM %s WHERE ", fieldsStr, rsName)
 
 	pk := options.PrimaryKeyFieldNames(key)
 	if len(pk) == 0 {
@@ -82,9 +113,27 @@ func getSingle(_ context.Context, options DbOptions, record dalrecord.Record, ex
 	} else if len(pk) > 1 {
 		return fmt.Errorf("%w: select by composite primary key is not supported yet", dal.ErrNotImplementedYet)
 	}
-	queryText += pk[0] + " = " + options.Placeholder.placeholder(1)
+	scope, err := options.scope(ctx, rsName)
+	if err != nil {
+		return err
+	}
+	lockClause, err := options.Dialect.rowLockClause(rowLockFromContext(ctx))
+	if err != nil {
+		return err
+	}
+	queryText := options.cachedSQL(
+		func() string { return statementShape("get", scope.shape(), fieldsStr, pk[0], lockClause) },
+		func() string {
+			return options.Placeholder.rewritePlaceholders(fmt.Sprintf("SELECT %s FROM %s WHERE %s = ?%s%s",
+				fieldsStr, scope.table, pk[0], scope.where(), lockClause))
+		})
 
-	rows, err := exec(queryText, key.ID)
+	args, err := primaryKeyArgs(newColumnMapper(options, rsName), pk, key)
+	if err != nil {
+		record.SetError(err)
+		return err
+	}
+	rows, err := exec(ctx, queryText, append(args, scope.args...)...)
 	if err != nil {
 		record.SetError(err)
 		return err
@@ -93,45 +142,105 @@ func getSingle(_ context.Context, options DbOptions, record dalrecord.Record, ex
 		_ = rows.Close()
 	}()
 
+	remember := rememberer(ctx, fieldsStr == "*")
 	if !rows.Next() {
+		options.count(ctx, rsName, CounterMisses, 1)
+		if remember != nil {
+			remember(key, nil, nil, nil)
+		}
 		notFound := dal.NewErrNotFoundByKey(key, nil)
 		record.SetError(notFound)
 		return notFound
 	}
-	if err = rowIntoRecord(rows, record, false); err != nil {
+	if remember != nil {
+		err = scanRememberedRow(rows, record, newColumnMapper(options, rsName), remember)
+	} else {
+		err = rowIntoRecord(rows, record, false, newColumnMapper(options, rsName))
+	}
+	if err != nil {
 		return err
 	}
 	if rows.Next() {
 		return errors.New("expected to get single row but got multiple")
 	}
+	options.count(ctx, rsName, CounterGets, 1)
 	return nil
 }
 
-func getMulti(ctx context.Context, options DbOptions, records []dalrecord.Record, exec queryExecutor) error {
+// getMulti reads records grouped by recordset, running up to parallelism
+// per-recordset queries at a time. When reading a recordset fails, the error
+// is set on each of its records and the errors of all failed recordsets are
+// joined into the returned error.
+func getMulti(ctx context.Context, options DbOptions, records []dalrecord.Record, exec queryExecutor, parallelism int) error {
+	var collections []string
 	byCollection := make(map[string][]dalrecord.Record)
 	for _, r := range records {
 		id := r.Key().Collection()
-		recs := byCollection[id]
+		recs, ok := byCollection[id]
+		if !ok {
+			collections = append(collections, id)
+		}
 		byCollection[id] = append(recs, r)
 	}
-	for _, recs := range byCollection {
-		if len(recs) == 1 {
-			if err := getSingle(ctx, options, recs[0], exec); err != nil {
-				recs[0].SetError(err)
+	errs := make([]error, len(collections))
+	getCollection := func(i int, err error) {
+		recs := byCollection[collections[i]]
+		if err == nil {
+			err = getRecordsetRecords(ctx, options, recs, exec)
+		}
+		if err != nil {
+			for _, r := range recs {
+				r.SetError(err)
 			}
-		} else if err := getMultiFromSingleTable(ctx, options, recs, exec); err != nil {
-			return err
+			errs[i] = fmt.Errorf("failed to get %d record(s) from %s: %w", len(recs), collections[i], err)
 		}
 	}
-	return nil
+	if parallelism <= 1 || len(collections) <= 1 {
+		for i := range collections {
+			getCollection(i, nil)
+		}
+		return errors.Join(errs...)
+	}
+	semaphore := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for i := range collections {
+		select {
+		case <-ctx.Done():
+			getCollection(i, ctx.Err())
+			continue
+		case semaphore <- struct{}{}:
+		}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+			getCollection(i, nil)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
 }
 
-func getMultiFromSingleTable(_ context.Context, options DbOptions, records []dalrecord.Record, exec queryExecutor) error {
+func getRecordsetRecords(ctx context.Context, options DbOptions, records []dalrecord.Record, exec queryExecutor) error {
+	// getSingle does not support composite primary keys, getMultiFromSingleTable does.
+	if len(records) == 1 && len(options.PrimaryKeyFieldNames(records[0].Key())) <= 1 {
+		if err := getSingle(ctx, options, records[0], exec); err != nil {
+			records[0].SetError(err)
+		}
+		return nil
+	}
+	return getMultiFromSingleTable(ctx, options, records, exec)
+}
+
+func getMultiFromSingleTable(ctx context.Context, options DbOptions, records []dalrecord.Record, exec queryExecutor) error {
 	if len(records) == 0 {
 		return nil
 	}
-	records = append(make([]dalrecord.Record, 0, len(records)), records...)
 	collection := records[0].Key().Collection()
+	mapper := newColumnMapper(options, collection)
+	exec = options.traceQuery(collection, exec)
 
 	rs, hasRecordsetDefinition := options.Recordsets[collection]
 	var primaryKey []string
@@ -154,154 +263,286 @@ func getMultiFromSingleTable(_ context.Context, options DbOptions, records []dal
 		r.SetError(nil)
 	}
 
-	// For map data we use SELECT * and identify the PK column after reading columns.
-	// For struct data we enumerate fields explicitly.
-	dataIsMap := isMapData(records[0].Data())
+	// Records are matched to rows by their normalized primary key values, so
+	// the same key requested more than once fills every record that asked for it.
+	pending := make(map[string][]dalrecord.Record, len(records))
+	keyValues := make([][]any, len(records))
+	for i, record := range records {
+		values, err := primaryKeyArgs(mapper, primaryKey, record.Key())
+		if err != nil {
+			return err
+		}
+		keyValues[i] = values
+		k := normalizedKey(values, nil)
+		pending[k] = append(pending[k], record)
+	}
+	// The first key guides conversion of scanned PK values, e.g. when a
+	// driver returns a numeric key as text.
+	keyHints := make([]any, len(primaryKey))
+	for i, v := range keyValues[0] {
+		keyHints[i] = normalizeKeyValue(v)
+	}
+
+	scope, err := options.scope(ctx, collection)
+	if err != nil {
+		return err
+	}
+	lockClause, err := options.Dialect.rowLockClause(rowLockFromContext(ctx))
+	if err != nil {
+		return err
+	}
 
-	var fields []string
-	if dataIsMap {
-		fields = []string{"*"}
-	} else {
-		fields = getSelectFields(true, options, records...)
-	}
-
-	queryText := fmt.Sprintf("SELECT %v FROM %v WHERE ",
-		strings.Join(fields, ", "),
-		records[0].Key().Collection(),
-	)
-	args := make([]interface{}, len(records))
-	if len(records) == 1 /*len(records) == 1*/ {
-		args = []any{}
-		var pkConditions []string
-		n := 1
-		processPrimaryKey(primaryKey, records[0].Key(), func(_ int, name string, v any) {
-			pkConditions = append(pkConditions, name+" = "+options.Placeholder.placeholder(n))
-			n++
-		})
-		queryText += " " + strings.Join(pkConditions, " AND ")
-	} else {
-		if len(primaryKey) > 1 {
-			panic("not yet supported to query multiple records by key from recordsets with composite primary key")
+	// Keys are split into chunks to stay under the driver's parameter limit.
+	selectFields := strings.Join(getMultiSelectFields(primaryKey, records), ", ")
+	remember := rememberer(ctx, selectFields == "*")
+	chunkSize := max(1, options.keysPerStatement(len(primaryKey))-len(scope.args))
+	for start := 0; start < len(keyValues); start += chunkSize {
+		where, args := buildKeysCondition(primaryKey, keyValues[start:min(start+chunkSize, len(keyValues))])
+		args = append(args, scope.args...)
+		queryText := fmt.Sprintf("SELECT %s FROM %s WHERE %s%s", selectFields, scope.table, scope.whereClause(where), lockClause)
+		queryText = options.Placeholder.rewritePlaceholders(queryText)
+		if err := queryRecordsByKeys(ctx, exec, mapper, queryText, args, primaryKey, keyHints, pending, remember); err != nil {
+			return err
 		}
-		queryText += fmt.Sprintf("%s IN (", primaryKey[0]) // TODO(help-wanted): support composite primary keys
-		var argPlaceholders []string
-		for i, record := range records {
-			n := i + 1
-			processPrimaryKey(primaryKey, record.Key(), func(_ int, name string, v any) {
-				argPlaceholders = append(argPlaceholders, options.Placeholder.placeholder(n))
-				args[i] = v
-			})
+	}
+	var misses int
+	for _, recs := range pending {
+		misses += len(recs)
+		for _, record := range recs {
+			record.SetError(dal.NewErrNotFoundByKey(record.Key(), nil))
+		}
+		if remember != nil {
+			remember(recs[0].Key(), nil, nil, nil)
 		}
-		queryText += strings.Join(argPlaceholders, ", ") + ")"
 	}
+	options.count(ctx, collection, CounterGets, int64(len(records)-misses))
+	options.count(ctx, collection, CounterMisses, int64(misses))
+	return nil
+}
 
-	// EXECUTE QUERY
-	rows, err := exec(queryText, args...)
+// queryRecordsByKeys executes a query selecting rows by primary key and
+// assigns each row to the pending records with a matching key.
+// Records that got a row are removed from pending. Rows are passed
+// to remember, if given, with the key of the first record they are assigned to.
+func queryRecordsByKeys(
+	ctx context.Context, exec queryExecutor, mapper columnMapper, queryText string, args []any,
+	primaryKey []string, keyHints []any, pending map[string][]dalrecord.Record,
+	remember func(key *dalrecord.Key, cols, dbTypes []string, values []any),
+) error {
+	rows, err := exec(ctx, queryText, args...)
 	if err != nil {
 		return err
 	}
-
-	if dataIsMap {
-		// For map data: scan each row generically, then match by PK value.
-		pkCol := primaryKey[0]
-		for rows.Next() {
-			cols, colErr := rows.Columns()
-			if colErr != nil {
-				return colErr
-			}
-			cells := make([]interface{}, len(cols))
-			cellPtrs := make([]interface{}, len(cols))
-			for i := range cells {
-				cellPtrs[i] = &cells[i]
-			}
-			if err = rows.Scan(cellPtrs...); err != nil {
+	defer func() {
+		_ = rows.Close()
+	}()
+	cols, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	pkIndexes, err := columnIndexes(cols, primaryKey)
+	if err != nil {
+		return err
+	}
+	dbTypes, err := mapper.columnTypeNames(rows, len(cols))
+	if err != nil {
+		return err
+	}
+	pkValues := make([]any, len(pkIndexes))
+	for rows.Next() {
+		values, err := scanRowValues(rows, len(cols))
+		if err != nil {
+			return err
+		}
+		for i, ci := range pkIndexes {
+			pkValues[i] = values[ci]
+		}
+		k := normalizedKey(pkValues, keyHints)
+		if recs := pending[k]; remember != nil && len(recs) > 0 {
+			remember(recs[0].Key(), cols, dbTypes, values)
+		}
+		for _, record := range pending[k] {
+			if err = assignRowToRecord(mapper, cols, dbTypes, values, record); err != nil {
 				return err
 			}
-			// Find PK column value.
-			var rowIDVal interface{}
-			for i, col := range cols {
-				if col == pkCol {
-					rowIDVal = cells[i]
-					if b, ok := rowIDVal.([]byte); ok {
-						rowIDVal = string(b)
-					}
-					break
-				}
-			}
-			rowID := fmt.Sprintf("%v", rowIDVal)
-			for i, record := range records {
-				if fmt.Sprintf("%v", record.Key().ID) == rowID {
-					records = append(records[:i], records[i+1:]...)
-					// Fill the target map.
-					m := record.Data()
-					mv := reflect.ValueOf(m)
-					if mv.Kind() == reflect.Pointer || mv.Kind() == reflect.Interface {
-						mv = mv.Elem()
-					}
-					for ci, col := range cols {
-						val := cells[ci]
-						if b, ok := val.([]byte); ok {
-							val = string(b)
-						}
-						mv.SetMapIndex(reflect.ValueOf(col), reflect.ValueOf(val))
-					}
-					record.SetError(dalrecord.ErrNoError)
-					break
-				}
-			}
 		}
-	} else {
-		// Struct data path: use the struct-field-aware scan.
-		val := reflect.ValueOf(records[0].Data()).Elem()
-		valType := val.Type()
-		for rows.Next() {
-			var id string
-			cells := make([]interface{}, len(fields))
-			cells[0] = &id
-
-			for i := 0; i < valType.NumField(); i++ {
-				switch valType.Field(i).Type {
-				case reflect.ValueOf("").Type():
-					v := ""
-					cells[i+1] = &v
-				case reflect.ValueOf(1).Type():
-					v := 0
-					cells[i+1] = &v
-				}
-			}
+		delete(pending, k)
+	}
+	if err = rows.Err(); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	return nil
+}
 
-			if err = rows.Scan(cells...); err != nil {
-				return err
+// primaryKeyArgs returns the primary key values of a key as SQL arguments.
+func primaryKeyArgs(mapper columnMapper, primaryKey []string, key *dalrecord.Key) (args []any, err error) {
+	args = make([]any, len(primaryKey))
+	processPrimaryKey(primaryKey, key, func(i int, name string, v any) {
+		if err == nil {
+			args[i], err = mapper.toSQL(name, nil, v)
+		}
+	})
+	return args, err
+}
+
+// buildKeysCondition renders a WHERE condition with "?" placeholders that
+// matches any of the given keys: "pk IN (?, ?)" for a single-column primary
+// key and "(a = ? AND b = ?) OR (a = ? AND b = ?)" for a composite one.
+func buildKeysCondition(primaryKey []string, keys [][]any) (text string, args []any) {
+	if len(primaryKey) == 1 {
+		placeholders := make([]string, len(keys))
+		for i, values := range keys {
+			placeholders[i] = "?"
+			args = append(args, values[0])
+		}
+		return primaryKey[0] + " IN (" + strings.Join(placeholders, ", ") + ")", args
+	}
+	conditions := make([]string, len(keys))
+	for i, values := range keys {
+		parts := make([]string, len(primaryKey))
+		for j, name := range primaryKey {
+			parts[j] = name + " = ?"
+			args = append(args, values[j])
+		}
+		conditions[i] = "(" + strings.Join(parts, " AND ") + ")"
+	}
+	return strings.Join(conditions, " OR "), args
+}
+
+// getMultiSelectFields returns the primary key columns followed by the union
+// of fields of struct records, or "*" if any of the records holds map data.
+func getMultiSelectFields(primaryKey []string, records []dalrecord.Record) []string {
+	fields := append(make([]string, 0, len(primaryKey)), primaryKey...)
+	seen := make(map[string]bool)
+	for _, pk := range primaryKey {
+		seen[strings.ToLower(pk)] = true
+	}
+	for _, record := range records {
+		data := record.Data()
+		if data == nil || isMapData(data) {
+			return []string{"*"}
+		}
+		t := reflect.TypeOf(data)
+		if t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct {
+			continue
+		}
+		for i := 0; i < t.NumField(); i++ {
+			if name := t.Field(i).Name; !seen[strings.ToLower(name)] {
+				seen[strings.ToLower(name)] = true
+				fields = append(fields, name)
 			}
-			for i, record := range records {
-				if record.Key().ID == id {
-					records = append(records[:i], records[i+1:]...)
-					if err = rowIntoRecord(rows, record, true); err != nil {
-						return err
-					}
-					break
-				}
+		}
+	}
+	return fields
+}
+
+// columnIndexes returns positions of the named columns in a result set.
+func columnIndexes(cols []string, names []string) ([]int, error) {
+	indexes := make([]int, len(names))
+	for i, name := range names {
+		indexes[i] = -1
+		for ci, col := range cols {
+			if strings.EqualFold(col, name) {
+				indexes[i] = ci
+				break
 			}
 		}
+		if indexes[i] < 0 {
+			return nil, fmt.Errorf("primary key column %s is missing in the result set", name)
+		}
 	}
-	if err = rows.Err(); errors.Is(err, sql.ErrNoRows) {
-		err = nil
-	} else if err != nil {
+	return indexes, nil
+}
+
+// assignRowToRecord fills record data (a struct pointer or a map) from scanned column values.
+func assignRowToRecord(mapper columnMapper, cols, dbTypes []string, values []any, record dalrecord.Record) error {
+	data := record.Data()
+	var err error
+	if isMapData(data) {
+		err = mapper.assignRowToMap(cols, dbTypes, values, data)
+	} else {
+		err = mapper.assignRowToStruct(cols, dbTypes, values, data)
+	}
+	if err != nil {
+		record.SetError(err)
 		return err
 	}
-	for _, record := range records {
-		record.SetError(dal.NewErrNotFoundByKey(record.Key(), nil))
+	record.SetError(dalrecord.ErrNoError)
+	return nil
+}
+
+// normalizedKey builds a comparable string from primary key values. Values
+// are normalized by normalizeKeyValue; if hints are given, a value of
+// another type is converted to the type of its hint, when possible.
+func normalizedKey(values []any, hints []any) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		nv := normalizeKeyValue(v)
+		if hints != nil {
+			nv = coerceKeyValue(nv, hints[i])
+		}
+		parts[i] = fmt.Sprintf("%T:%v", nv, nv)
 	}
-	return err
+	return strings.Join(parts, "\x1f")
 }
 
-func rowIntoRecord(rows *sql.Rows, record dalrecord.Record, pkIncluded bool) error {
+// normalizeKeyValue maps key values of different Go types that are equal in
+// the database to the same value. Values are first converted the way the
+// driver would receive them as arguments (driver.Valuer, named types), then
+// integral floats become int64 and []byte, time.Time and other text types
+// (fmt.Stringer, encoding.TextMarshaler) become strings.
+func normalizeKeyValue(v any) any {
+	if dv, err := driver.DefaultParameterConverter.ConvertValue(v); err == nil {
+		v = dv
+	}
+	switch x := v.(type) {
+	case nil, string, int64, bool:
+		return x
+	case []byte:
+		return string(x)
+	case float64:
+		if x == math.Trunc(x) && math.Abs(x) < math.MaxInt64 {
+			return int64(x)
+		}
+		return x
+	case time.Time:
+		return x.UTC().Format(time.RFC3339Nano)
+	case fmt.Stringer:
+		return x.String()
+	case encoding.TextMarshaler:
+		if text, err := x.MarshalText(); err == nil {
+			return string(text)
+		}
+	}
+	return v
+}
+
+func coerceKeyValue(v, hint any) any {
+	switch hint.(type) {
+	case int64:
+		if s, ok := v.(string); ok {
+			if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+				return i
+			}
+		}
+	case string:
+		if i, ok := v.(int64); ok {
+			return strconv.FormatInt(i, 10)
+		}
+	}
+	return v
+}
+
+func rowIntoRecord(rows *sql.Rows, record dalrecord.Record, pkIncluded bool, mapper columnMapper) error {
 	record.SetError(nil)
 	data := record.Data()
 	if data == nil {
 		panic("getting records by key requires a record with data")
 	}
-	if err := scanIntoData(rows, data, pkIncluded); err != nil {
+	if err := scanIntoData(rows, data, pkIncluded, mapper); err != nil {
 		record.SetError(err)
 		return err
 	}
@@ -333,14 +574,16 @@ func rowIntoRecord(rows *sql.Rows, record dalrecord.Record, pkIncluded bool) err
 //	return nil
 //}
 
-func scanIntoData(rows *sql.Rows, data interface{}, pkIncluded bool) error {
+func scanIntoData(rows *sql.Rows, data interface{}, pkIncluded bool, mapper columnMapper) error {
 	if isMapData(data) {
-		return scanRowIntoMap(rows, data, pkIncluded)
+		return scanRowIntoMap(rows, data, pkIncluded, mapper)
 	}
-	if pkIncluded {
-		return scanIntoDataWithPrimaryKeyIncluded(rows, data)
+	if t := reflect.TypeOf(data); t != nil && t.Kind() == reflect.Pointer && t.Elem().Kind() == reflect.Struct {
+		// Columns are matched to fields by name, so a PK column selected along
+		// with the fields is simply skipped if the struct has no field for it.
+		return scanRowIntoStruct(rows, data, mapper)
 	}
-	return sqlscan.ScanRow(data, rows)
+	return fmt.Errorf("unsupported record data type %T: expected a pointer to struct or a map", data)
 }
 
 // isMapData reports whether data is a map[string]any or *map[string]any.
@@ -356,60 +599,21 @@ func isMapData(data interface{}) bool {
 }
 
 // scanRowIntoMap scans the current sql.Rows row into a map[string]any (or
-// *map[string]any). PK columns are skipped if pkIncluded is false; when
-// pkIncluded is true they are also included in the result map.
-func scanRowIntoMap(rows *sql.Rows, data interface{}, pkIncluded bool) error {
+// *map[string]any). All columns are included, PK columns too.
+func scanRowIntoMap(rows *sql.Rows, data interface{}, _ bool, mapper columnMapper) error {
 	cols, err := rows.Columns()
 	if err != nil {
 		return err
 	}
-
-	// Build generic scan targets.
-	cells := make([]interface{}, len(cols))
-	cellPtrs := make([]interface{}, len(cols))
-	for i := range cells {
-		cellPtrs[i] = &cells[i]
-	}
-	if err = rows.Scan(cellPtrs...); err != nil {
+	dbTypes, err := mapper.columnTypeNames(rows, len(cols))
+	if err != nil {
 		return err
 	}
-
-	// Resolve the target map (handle *map[string]any or map[string]any).
-	v := reflect.ValueOf(data)
-	if v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
-		// If it's a pointer to a nil map, initialize the map first.
-		if v.Elem().Kind() == reflect.Map && v.Elem().IsNil() {
-			v.Elem().Set(reflect.MakeMap(v.Elem().Type()))
-		}
-		v = v.Elem()
-	}
-
-	for i, col := range cols {
-		// Include all columns in the map (including PK column).
-		// Callers that do not want the PK in the map can delete it afterward.
-		_ = pkIncluded
-		val := cells[i]
-		// database/sql returns []byte for TEXT columns; convert to string for usability.
-		if b, ok := val.([]byte); ok {
-			val = string(b)
-		}
-		if val != nil {
-			v.SetMapIndex(reflect.ValueOf(col), reflect.ValueOf(val))
-		}
-	}
-	return nil
-}
-
-func scanIntoDataWithPrimaryKeyIncluded(rows *sql.Rows, data interface{}) error {
-	var id []byte
-	val := reflect.ValueOf(data).Elem()
-	valType := val.Type()
-	cells := make([]interface{}, valType.NumField()+1)
-	cells[0] = &id
-	for i := 1; i < len(cells); i++ {
-		cells[i] = val.Field(i - 1).Addr().Interface()
+	values, err := scanRowValues(rows, len(cols))
+	if err != nil {
+		return err
 	}
-	return rows.Scan(cells...)
+	return mapper.assignRowToMap(cols, dbTypes, values, data)
 }
 
 //func scanIntoMap(rows *sql.Rows) (row map[string]interface{}, err error) {
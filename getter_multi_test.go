@@ -0,0 +1,510 @@
+package dalgo2sql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dal-go/dalgo/dal"
+	dalrecord "github.com/dal-go/record"
+)
+
+// testUUID mimics popular UUID types: an array with String() and Value().
+type testUUID [16]byte
+
+func (u testUUID) String() string {
+	return hex.EncodeToString(u[:4]) + "-" + hex.EncodeToString(u[4:6]) + "-" +
+		hex.EncodeToString(u[6:8]) + "-" + hex.EncodeToString(u[8:10]) + "-" + hex.EncodeToString(u[10:])
+}
+
+func (u testUUID) Value() (driver.Value, error) {
+	return u.String(), nil
+}
+
+type multiItem struct {
+	Title string
+	Qty   int
+}
+
+func newGetMultiTestDB(t *testing.T, createSQL string, recordsets ...*Recordset) *database {
+	t.Helper()
+	options := DbOptions{Dialect: DialectSQLite, Recordsets: make(map[string]*Recordset)}
+	for _, rs := range recordsets {
+		options.Recordsets[rs.Name()] = rs
+	}
+	return dal.BackendOf(NewDatabase(openTestSQLiteDB(t, createSQL), newSchema(), options)).(*database)
+}
+
+func TestGetMulti_IntegerKeys(t *testing.T) {
+	ctx := context.Background()
+	db := newGetMultiTestDB(t, `CREATE TABLE items (ID INTEGER PRIMARY KEY, Title TEXT, Qty INTEGER)`,
+		NewRecordset("items", Table, []dal.FieldRef{dal.Field("ID")}))
+	for id, title := range map[int]string{1: "one", 2: "two", 10: "ten"} {
+		data := multiItem{Title: title, Qty: id * 100}
+		if err := db.Insert(ctx, dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("items", id), &data)); err != nil {
+			t.Fatalf("Insert(%d): %v", id, err)
+		}
+	}
+
+	// Keys of different integer types are matched to INTEGER values returned as int64.
+	items := make([]multiItem, 4)
+	records := []dalrecord.Record{
+		dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("items", 10), &items[0]),
+		dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("items", int64(1)), &items[1]),
+		dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("items", uint8(2)), &items[2]),
+		dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("items", 3), &items[3]),
+	}
+	if err := db.GetMulti(ctx, records); err != nil {
+		t.Fatalf("GetMulti: %v", err)
+	}
+	want := []multiItem{{"ten", 1000}, {"one", 100}, {"two", 200}, {}}
+	for i, w := range want {
+		if items[i] != w {
+			t.Errorf("items[%d] = %+v, want %+v", i, items[i], w)
+		}
+	}
+	for i, exists := range []bool{true, true, true, false} {
+		if got := records[i].Exists(); got != exists {
+			t.Errorf("records[%d].Exists() = %v, want %v", i, got, exists)
+		}
+	}
+}
+
+func TestGetMulti_UUIDKeys(t *testing.T) {
+	ctx := context.Background()
+	db := newGetMultiTestDB(t, `CREATE TABLE items (ID TEXT PRIMARY KEY, Title TEXT, Qty INTEGER)`,
+		NewRecordset("items", Table, []dal.FieldRef{dal.Field("ID")}))
+	id1 := testUUID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	id2 := testUUID{16, 15, 14, 13, 12, 11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1}
+	for i, id := range []testUUID{id1, id2} {
+		data := multiItem{Title: id.String(), Qty: i}
+		if err := db.Insert(ctx, dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("items", id), &data)); err != nil {
+			t.Fatalf("Insert(%v): %v", id, err)
+		}
+	}
+
+	var got1, got2 multiItem
+	records := []dalrecord.Record{
+		dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("items", id2), &got2),
+		dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("items", id1), &got1),
+	}
+	if err := db.GetMulti(ctx, records); err != nil {
+		t.Fatalf("GetMulti: %v", err)
+	}
+	if got1.Title != id1.String() || got2.Title != id2.String() {
+		t.Errorf("got %q and %q, want %q and %q", got1.Title, got2.Title, id1, id2)
+	}
+}
+
+func TestGetMulti_CompositeKeys(t *testing.T) {
+	ctx := context.Background()
+	db := newGetMultiTestDB(t, `CREATE TABLE memberships (
+			TeamID TEXT,
+			UserID INTEGER,
+			Role   TEXT,
+			PRIMARY KEY (TeamID, UserID)
+		)`,
+		NewRecordset("memberships", Table, []dal.FieldRef{dal.Field("TeamID"), dal.Field("UserID")}))
+	type membership struct {
+		Role string
+	}
+	key := func(team string, user int) *dalrecord.Key {
+		return dalrecord.NewKeyWithFields("memberships",
+			dalrecord.FieldVal{Name: "TeamID", Value: team},
+			dalrecord.FieldVal{Name: "UserID", Value: user},
+		)
+	}
+	for _, m := range []struct {
+		team string
+		user int
+		role string
+	}{{"t1", 1, "owner"}, {"t1", 2, "member"}, {"t2", 1, "guest"}} {
+		if err := db.Insert(ctx, dalrecord.NewRecordWithData(key(m.team, m.user), &membership{Role: m.role})); err != nil {
+			t.Fatalf("Insert(%s, %d): %v", m.team, m.user, err)
+		}
+	}
+
+	t.Run("several", func(t *testing.T) {
+		got := make([]membership, 4)
+		records := []dalrecord.Record{
+			dalrecord.NewRecordWithData(key("t2", 1), &got[0]),
+			dalrecord.NewRecordWithData(key("t1", 2), &got[1]),
+			dalrecord.NewRecordWithData(key("t1", 1), &got[2]),
+			dalrecord.NewRecordWithData(key("t2", 2), &got[3]),
+		}
+		if err := db.GetMulti(ctx, records); err != nil {
+			t.Fatalf("GetMulti: %v", err)
+		}
+		for i, role := range []string{"guest", "member", "owner", ""} {
+			if got[i].Role != role {
+				t.Errorf("got[%d].Role = %q, want %q", i, got[i].Role, role)
+			}
+		}
+		if records[3].Exists() {
+			t.Error("expected (t2, 2) to be not found")
+		}
+	})
+
+	t.Run("single", func(t *testing.T) {
+		var got membership
+		records := []dalrecord.Record{dalrecord.NewRecordWithData(key("t1", 2), &got)}
+		if err := db.GetMulti(ctx, records); err != nil {
+			t.Fatalf("GetMulti: %v", err)
+		}
+		if got.Role != "member" {
+			t.Errorf("Role = %q, want member", got.Role)
+		}
+	})
+}
+
+func TestGetMulti_MixedStructAndMapRecords(t *testing.T) {
+	ctx := context.Background()
+	db := newGetMultiTestDB(t, `CREATE TABLE items (ID INTEGER PRIMARY KEY, Title TEXT, Qty INTEGER)`,
+		NewRecordset("items", Table, []dal.FieldRef{dal.Field("ID")}))
+	for _, id := range []int{1, 2} {
+		data := multiItem{Title: "item", Qty: id}
+		if err := db.Insert(ctx, dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("items", id), &data)); err != nil {
+			t.Fatalf("Insert(%d): %v", id, err)
+		}
+	}
+	var asStruct multiItem
+	asMap := map[string]any{}
+	records := []dalrecord.Record{
+		dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("items", 1), &asStruct),
+		dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("items", 2), asMap),
+	}
+	if err := db.GetMulti(ctx, records); err != nil {
+		t.Fatalf("GetMulti: %v", err)
+	}
+	if asStruct != (multiItem{Title: "item", Qty: 1}) {
+		t.Errorf("struct record = %+v", asStruct)
+	}
+	if want := map[string]any{"ID": int64(2), "Title": "item", "Qty": int64(2)}; !reflect.DeepEqual(asMap, want) {
+		t.Errorf("map record = %#v, want %#v", asMap, want)
+	}
+}
+
+func TestGetMulti_SingleQueryScannedOnce(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeDatabase(t, sqlDB)
+	db := NewDatabase(sqlDB, newSchema(), DbOptions{
+		Recordsets: map[string]*Recordset{
+			"items": NewRecordset("items", Table, []dal.FieldRef{dal.Field("ID")}),
+		},
+	})
+
+	// Columns come in a different order than fields, the key comes back as
+	// text for an integer key, and the same key is requested twice.
+	mock.ExpectQuery(`SELECT ID, Title, Qty FROM items WHERE ID IN \(\?, \?, \?\)`).
+		WithArgs(7, 8, 7).
+		WillReturnRows(sqlmock.NewRows([]string{"Qty", "ID", "Title"}).
+			AddRow(int64(70), []byte("7"), []byte("seven")).
+			AddRow(int64(80), "8", "eight"))
+
+	items := make([]multiItem, 3)
+	records := []dalrecord.Record{
+		dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("items", 7), &items[0]),
+		dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("items", 8), &items[1]),
+		dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("items", 7), &items[2]),
+	}
+	if err = db.GetMulti(context.Background(), records); err != nil {
+		t.Fatalf("GetMulti: %v", err)
+	}
+	want := []multiItem{{"seven", 70}, {"eight", 80}, {"seven", 70}}
+	if !reflect.DeepEqual(items, want) {
+		t.Errorf("items = %+v, want %+v", items, want)
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGetMulti_CompositeKeySQL(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeDatabase(t, sqlDB)
+	db := NewDatabase(sqlDB, newSchema(), DbOptions{
+		Placeholder: PlaceholderDollar,
+		Recordsets: map[string]*Recordset{
+			"memberships": NewRecordset("memberships", Table, []dal.FieldRef{dal.Field("TeamID"), dal.Field("UserID")}),
+		},
+	})
+	mock.ExpectQuery("SELECT TeamID, UserID, Role FROM memberships WHERE (TeamID = $1 AND UserID = $2) OR (TeamID = $3 AND UserID = $4)").
+		WithArgs("t1", 1, "t2", 2).
+		WillReturnRows(sqlmock.NewRows([]string{"TeamID", "UserID", "Role"}).AddRow("t2", int64(2), "guest"))
+
+	type membership struct {
+		Role string
+	}
+	var m1, m2 membership
+	records := []dalrecord.Record{
+		dalrecord.NewRecordWithData(dalrecord.NewKeyWithFields("memberships",
+			dalrecord.FieldVal{Name: "TeamID", Value: "t1"}, dalrecord.FieldVal{Name: "UserID", Value: 1}), &m1),
+		dalrecord.NewRecordWithData(dalrecord.NewKeyWithFields("memberships",
+			dalrecord.FieldVal{Name: "UserID", Value: 2}, dalrecord.FieldVal{Name: "TeamID", Value: "t2"}), &m2),
+	}
+	if err = db.GetMulti(context.Background(), records); err != nil {
+		t.Fatalf("GetMulti: %v", err)
+	}
+	if m1.Role != "" || m2.Role != "guest" {
+		t.Errorf("roles = %q, %q; want \"\", \"guest\"", m1.Role, m2.Role)
+	}
+	if records[0].Exists() || !records[1].Exists() {
+		t.Error("expected only the second membership to exist")
+	}
+}
+
+func TestNormalizeKeyValue(t *testing.T) {
+	id := testUUID{1}
+	tests := []struct {
+		name  string
+		value any
+		want  any
+	}{
+		{name: "nil", value: nil, want: nil},
+		{name: "int", value: 5, want: int64(5)},
+		{name: "uint8", value: uint8(5), want: int64(5)},
+		{name: "integral_float", value: 5.0, want: int64(5)},
+		{name: "fraction", value: 5.5, want: 5.5},
+		{name: "bytes", value: []byte("abc"), want: "abc"},
+		{name: "named_string", value: testNamedString("abc"), want: "abc"},
+		{name: "valuer", value: id, want: id.String()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeKeyValue(tt.value); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("normalizeKeyValue(%#v) = %#v, want %#v", tt.value, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("text_coerced_to_integer_hint", func(t *testing.T) {
+		if a, b := normalizedKey([]any{7}, nil), normalizedKey([]any{"7"}, []any{int64(0)}); a != b {
+			t.Errorf("%q != %q", a, b)
+		}
+	})
+	t.Run("integer_coerced_to_text_hint", func(t *testing.T) {
+		if a, b := normalizedKey([]any{"7"}, nil), normalizedKey([]any{int64(7)}, []any{""}); a != b {
+			t.Errorf("%q != %q", a, b)
+		}
+	})
+	t.Run("types_are_kept_without_hints", func(t *testing.T) {
+		if a, b := normalizedKey([]any{"7"}, nil), normalizedKey([]any{7}, nil); a == b {
+			t.Error("expected text and integer keys to differ")
+		}
+	})
+}
+
+type testNamedString string
+
+func TestBuildKeysCondition(t *testing.T) {
+	text, args := buildKeysCondition([]string{"ID"}, [][]any{{1}, {2}})
+	if text != "ID IN (?, ?)" || !reflect.DeepEqual(args, []any{1, 2}) {
+		t.Errorf("single: %q %v", text, args)
+	}
+	text, args = buildKeysCondition([]string{"A", "B"}, [][]any{{1, "x"}, {2, "y"}})
+	if text != "(A = ? AND B = ?) OR (A = ? AND B = ?)" || !reflect.DeepEqual(args, []any{1, "x", 2, "y"}) {
+		t.Errorf("composite: %q %v", text, args)
+	}
+}
+
+func TestGetMulti_ParallelAcrossRecordsets(t *testing.T) {
+	ctx := context.Background()
+	tables := []string{"t1", "t2", "t3", "t4"}
+	var ddl []string
+	options := DbOptions{Recordsets: make(map[string]*Recordset)}
+	for _, name := range append(tables, "missing") {
+		if name != "missing" {
+			ddl = append(ddl, fmt.Sprintf("CREATE TABLE %[1]s (ID TEXT PRIMARY KEY, Title TEXT, Qty INTEGER);"+
+				"INSERT INTO %[1]s VALUES ('a', '%[1]s-a', 1), ('b', '%[1]s-b', 2);", name))
+		}
+		options.Recordsets[name] = NewRecordset(name, Table, []dal.FieldRef{dal.Field("ID")})
+	}
+	sqlDB := openTestSQLiteDB(t, strings.Join(ddl, "\n"))
+
+	// trackingExec records the maximum number of queries executed at the same time.
+	trackingExec := func(inFlight, maxInFlight *int32) queryExecutor {
+		return func(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+			n := atomic.AddInt32(inFlight, 1)
+			defer atomic.AddInt32(inFlight, -1)
+			for {
+				if m := atomic.LoadInt32(maxInFlight); n <= m || atomic.CompareAndSwapInt32(maxInFlight, m, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			return sqlDB.QueryContext(ctx, query, args...)
+		}
+	}
+	newRecords := func(collections ...string) (records []dalrecord.Record, items []*multiItem) {
+		for _, collection := range collections {
+			for _, id := range []string{"a", "b"} {
+				item := new(multiItem)
+				items = append(items, item)
+				records = append(records, dalrecord.NewRecordWithData(dalrecord.NewKeyWithID(collection, id), item))
+			}
+		}
+		return
+	}
+
+	for _, tt := range []struct {
+		parallelism int
+		wantMax     int32
+	}{
+		{parallelism: 1, wantMax: 1},
+		{parallelism: 2, wantMax: 2},
+		{parallelism: 8, wantMax: 4},
+	} {
+		t.Run(fmt.Sprintf("parallelism_%d", tt.parallelism), func(t *testing.T) {
+			var inFlight, maxInFlight int32
+			records, items := newRecords(tables...)
+			if err := getMulti(ctx, options, records, trackingExec(&inFlight, &maxInFlight), tt.parallelism); err != nil {
+				t.Fatalf("getMulti: %v", err)
+			}
+			if maxInFlight > tt.wantMax || (tt.wantMax > 1 && maxInFlight < 2) {
+				t.Errorf("max concurrent queries = %d, want %d", maxInFlight, tt.wantMax)
+			}
+			for i, r := range records {
+				want := multiItem{Title: r.Key().Collection() + "-" + r.Key().ID.(string), Qty: i%2 + 1}
+				if *items[i] != want {
+					t.Errorf("%s = %+v, want %+v", r.Key(), *items[i], want)
+				}
+			}
+		})
+	}
+
+	t.Run("errors_per_record", func(t *testing.T) {
+		var inFlight, maxInFlight int32
+		records, items := newRecords("t1", "missing", "t2")
+		err := getMulti(ctx, options, records, trackingExec(&inFlight, &maxInFlight), 3)
+		if err == nil || !strings.Contains(err.Error(), "from missing") {
+			t.Fatalf("expected error for the missing table, got %v", err)
+		}
+		for i, r := range records {
+			if failed := r.Key().Collection() == "missing"; failed != (r.Error() != nil) {
+				t.Errorf("%s: unexpected record error %v", r.Key(), r.Error())
+			} else if !failed && items[i].Title == "" {
+				t.Errorf("%s: record was not loaded", r.Key())
+			} else if failed && !errors.Is(err, r.Error()) {
+				t.Errorf("%s: record error %v is not part of the returned error", r.Key(), r.Error())
+			}
+		}
+	})
+
+	t.Run("canceled_context", func(t *testing.T) {
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+		var inFlight, maxInFlight int32
+		records, _ := newRecords(tables...)
+		// Collections not started before the context was canceled fail with its error.
+		if err := getMulti(canceled, options, records, trackingExec(&inFlight, &maxInFlight), 2); err != nil && !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+	})
+}
+
+func TestDbOptions_getMultiParallelism(t *testing.T) {
+	if got := (DbOptions{}).getMultiParallelism(); got != DefaultGetMultiParallelism {
+		t.Errorf("default = %d, want %d", got, DefaultGetMultiParallelism)
+	}
+	if got := (DbOptions{GetMultiParallelism: 1}).getMultiParallelism(); got != 1 {
+		t.Errorf("explicit = %d, want 1", got)
+	}
+}
+
+func TestGetMulti_ChunksKeys(t *testing.T) {
+	t.Run("statements", func(t *testing.T) {
+		sqlDB, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer closeDatabase(t, sqlDB)
+		db := NewDatabase(sqlDB, newSchema(), DbOptions{
+			MaxParameters: 2,
+			Recordsets: map[string]*Recordset{
+				"items": NewRecordset("items", Table, []dal.FieldRef{dal.Field("ID")}),
+			},
+		})
+		mock.ExpectQuery(`SELECT ID, Title, Qty FROM items WHERE ID IN \(\?, \?\)`).WithArgs(1, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"ID", "Title", "Qty"}).AddRow(int64(2), "two", int64(20)))
+		mock.ExpectQuery(`SELECT ID, Title, Qty FROM items WHERE ID IN \(\?\)`).WithArgs(3).
+			WillReturnRows(sqlmock.NewRows([]string{"ID", "Title", "Qty"}).AddRow(int64(3), "three", int64(30)))
+		items := make([]multiItem, 3)
+		records := make([]dalrecord.Record, len(items))
+		for i := range items {
+			records[i] = dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("items", i+1), &items[i])
+		}
+		if err = db.GetMulti(context.Background(), records); err != nil {
+			t.Fatalf("GetMulti: %v", err)
+		}
+		if want := []multiItem{{}, {"two", 20}, {"three", 30}}; !reflect.DeepEqual(items, want) {
+			t.Errorf("items = %+v, want %+v", items, want)
+		}
+		if records[0].Exists() || !records[1].Exists() || !records[2].Exists() {
+			t.Error("expected only the first record to be missing")
+		}
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("beyond_sqlite_limit", func(t *testing.T) {
+		const n = 33000 // more than SQLite's 32766 parameters per statement
+		db := newGetMultiTestDB(t, `CREATE TABLE items (ID INTEGER PRIMARY KEY, Title TEXT, Qty INTEGER);
+			INSERT INTO items (ID, Title, Qty)
+			WITH RECURSIVE seq(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM seq WHERE i < 33000)
+			SELECT i, 'item', i FROM seq;`,
+			NewRecordset("items", Table, []dal.FieldRef{dal.Field("ID")}))
+		items := make([]multiItem, n+1)
+		records := make([]dalrecord.Record, len(items))
+		for i := range items {
+			records[i] = dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("items", i+1), &items[i])
+		}
+		if err := db.GetMulti(context.Background(), records); err != nil {
+			t.Fatalf("GetMulti: %v", err)
+		}
+		for i := 0; i < n; i++ {
+			if items[i].Qty != i+1 {
+				t.Fatalf("items[%d].Qty = %d, want %d", i, items[i].Qty, i+1)
+			}
+		}
+		if records[n].Exists() {
+			t.Errorf("record %d should not exist", n+1)
+		}
+	})
+
+	t.Run("composite_keys", func(t *testing.T) {
+		db := newGetMultiTestDB(t, `CREATE TABLE memberships (TeamID TEXT, UserID INTEGER, Role TEXT, PRIMARY KEY (TeamID, UserID));
+			INSERT INTO memberships VALUES ('t1', 1, 'a'), ('t1', 2, 'b'), ('t2', 1, 'c');`,
+			NewRecordset("memberships", Table, []dal.FieldRef{dal.Field("TeamID"), dal.Field("UserID")}))
+		db.options.MaxParameters = 3 // a single composite key per statement
+		type membership struct{ Role string }
+		keys := [][2]any{{"t1", 1}, {"t1", 2}, {"t2", 1}}
+		got := make([]membership, len(keys))
+		records := make([]dalrecord.Record, len(keys))
+		for i, k := range keys {
+			records[i] = dalrecord.NewRecordWithData(dalrecord.NewKeyWithFields("memberships",
+				dalrecord.FieldVal{Name: "TeamID", Value: k[0]}, dalrecord.FieldVal{Name: "UserID", Value: k[1]}), &got[i])
+		}
+		if err := db.GetMulti(context.Background(), records); err != nil {
+			t.Fatalf("GetMulti: %v", err)
+		}
+		if want := []membership{{"a"}, {"b"}, {"c"}}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %+v, want %+v", got, want)
+		}
+	})
+}
@@ -273,7 +273,7 @@ func TestGetter_ScanIntoData_Errors(t *testing.T) {
 		mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"col1"}).AddRow(1))
 		rows, _ := db.Query("SELECT")
 		rows.Next()
-		err := scanIntoData(rows, 123, false) // int is not supported (needs pointer to struct or map)
+		err := scanIntoData(rows, 123, false, columnMapper{}) // int is not supported (needs pointer to struct or map)
 		if err == nil {
 			t.Errorf("expected error for unsupported data type")
 		}
@@ -292,7 +292,7 @@ func TestGetReaderBase_StructuredQuery(t *testing.T) {
 
 	mock.ExpectQuery("SELECT id FROM users").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 
-	rb, err := getReaderBase(ctx, q, func(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	rb, err := getReaderBase(ctx, DbOptions{}, q, func(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
 		return sqlDB.QueryContext(ctx, query, args...)
 	})
 	if err != nil {
@@ -10,7 +10,6 @@ require (
 	github.com/DATA-DOG/go-sqlmock v1.5.2
 	github.com/dal-go/dalgo v0.64.8
 	github.com/dal-go/record v0.1.2
-	github.com/georgysavva/scany/v2 v2.1.4
 	modernc.org/sqlite v1.57.0
 )
 
@@ -0,0 +1,451 @@
+package dalgo2sql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dal-go/dalgo/dal"
+	"github.com/dal-go/record"
+	"github.com/dal-go/record/update"
+)
+
+// DefaultHistoryTable is used when DbOptions.HistoryTable is not set.
+const DefaultHistoryTable = "dalgo_history"
+
+// WithHistory records every change of a recordset's rows by Insert, Set,
+// Update, Delete and their Multi and Where variants, Restore and Purge in
+// DbOptions.HistoryTable, in the transaction of the change. Writes outside
+// a transaction run in one of their own.
+//
+// Each changed row gets an entry with the recordset, the key, the operation,
+// the images of the row before and after the change as JSON objects of its
+// columns, the identity of the context (see WithIdentity) and the time of
+// DbOptions.Clock. Images are read by primary key, which costs a query per
+// changed row and image. A row not visible to the context, e.g. deleted or
+// filtered out, has no image. The table is expected to look like:
+//
+//	CREATE TABLE dalgo_history (
+//		id INTEGER PRIMARY KEY AUTOINCREMENT, -- BIGSERIAL, BIGINT AUTO_INCREMENT
+//		recordset TEXT NOT NULL,
+//		record_key TEXT NOT NULL,
+//		operation TEXT NOT NULL,
+//		before_image TEXT,
+//		after_image TEXT,
+//		actor TEXT,
+//		changed_at TIMESTAMP NOT NULL
+//	)
+//
+// The history table is scoped by Tenancy like the recordsets,
+// unless it is listed in Tenancy.Shared.
+func WithHistory() RecordsetOption {
+	return func(rs *Recordset) {
+		rs.history = true
+	}
+}
+
+// HistoryEntry is a change of a row recorded by WithHistory.
+type HistoryEntry struct {
+	ID        int64
+	Recordset string
+	Key       string
+	Operation Operation
+	// Before and After are the columns of the row, nil if there was no row.
+	Before, After map[string]any
+	Actor         string
+	ChangedAt     time.Time
+}
+
+// HistoryReader is implemented by the database (see dal.BackendOf) and the
+// transactions of this adapter.
+type HistoryReader interface {
+	// History lists the recorded changes of a record from the oldest.
+	History(ctx context.Context, key *record.Key) ([]HistoryEntry, error)
+}
+
+var _ HistoryReader = (*database)(nil)
+var _ HistoryReader = (*transaction)(nil)
+
+func (dtb *database) History(ctx context.Context, key *record.Key) ([]HistoryEntry, error) {
+	return readHistory(ctx, dtb.options, dtb.query, key)
+}
+
+func (t transaction) History(ctx context.Context, key *record.Key) ([]HistoryEntry, error) {
+	return readHistory(ctx, t.sqlOptions, t.query, key)
+}
+
+func (o DbOptions) historyTable() string {
+	if o.HistoryTable == "" {
+		return DefaultHistoryTable
+	}
+	return o.HistoryTable
+}
+
+// recordsHistory reports whether changes of any of the recordsets are recorded.
+func (o DbOptions) recordsHistory(recordsets []string) bool {
+	return slices.ContainsFunc(recordsets, func(name string) bool {
+		rs := o.Recordsets[name]
+		return rs != nil && rs.history
+	})
+}
+
+// tracksChanges reports whether changes of any of the recordsets are
+// recorded in the history or the changelog.
+func (o DbOptions) tracksChanges(recordsets []string) bool {
+	return o.recordsHistory(recordsets) || o.logsChanges(recordsets)
+}
+
+// change describes the rows changed by a write, for the history and the changelog.
+type change struct {
+	recordsets []string
+	// inserts have no before images and their keys may only be known
+	// after the write, e.g. if generated.
+	inserts bool
+	keys    func(ctx context.Context, options DbOptions, query queryExecutor) ([]*record.Key, error)
+	// records and affected receive the outcome of a write replayed for an
+	// idempotency key, see WithIdempotencyKey.
+	records  []record.Record
+	affected *int64
+	// where changes write the rows matching a condition, so their keys are only known by a query.
+	where bool
+	// condition and updates identify the write for idempotency keys, see idempotencyRequest.
+	condition dal.Condition
+	updates   []update.Update
+}
+
+// counting makes a write replayed for an idempotency key report its number of affected rows to n.
+func (c change) counting(n *int64) change {
+	c.affected = n
+	return c
+}
+
+// updating sets the updates applied by the change.
+func (c change) updating(updates []update.Update) change {
+	c.updates = updates
+	return c
+}
+
+func keysChange(keys ...*record.Key) change {
+	c := change{keys: func(context.Context, DbOptions, queryExecutor) ([]*record.Key, error) {
+		return keys, nil
+	}}
+	for _, key := range keys {
+		c.recordsets = append(c.recordsets, key.Collection())
+	}
+	return c
+}
+
+func recordsChange(inserts bool, records ...record.Record) change {
+	c := change{inserts: inserts, records: records, keys: func(context.Context, DbOptions, queryExecutor) ([]*record.Key, error) {
+		keys := make([]*record.Key, len(records))
+		for i, r := range records {
+			keys[i] = r.Key()
+		}
+		return keys, nil
+	}}
+	for _, r := range records {
+		c.recordsets = append(c.recordsets, r.Key().Collection())
+	}
+	return c
+}
+
+// whereChange selects the keys of the rows of a collection that match a condition.
+func whereChange(collection string, where dal.Condition) change {
+	return change{recordsets: []string{collection}, where: true, condition: where, keys: func(ctx context.Context, options DbOptions, query queryExecutor) ([]*record.Key, error) {
+		if where == nil {
+			// Let the write report the missing condition.
+			return nil, nil
+		}
+		scope, err := options.scope(ctx, collection)
+		if err != nil {
+			return nil, err
+		}
+		pk := options.Recordsets[collection].PrimaryKeyFieldNames()
+		if len(pk) == 0 {
+			return nil, fmt.Errorf("primary key is not defined for %s", collection)
+		}
+		condition, args, err := buildCondition(where)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build where condition for %s: %w", collection, err)
+		}
+		text := options.Placeholder.rewritePlaceholders(fmt.Sprintf("SELECT %s FROM %s WHERE %s",
+			strings.Join(pk, ", "), scope.table, scope.whereClause(condition)))
+		rows, err := options.traceQuery(collection, query)(ctx, text, append(args, scope.args...)...)
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = rows.Close() }()
+		var keys []*record.Key
+		for rows.Next() {
+			values := make([]any, len(pk))
+			pointers := make([]any, len(pk))
+			for i := range values {
+				pointers[i] = &values[i]
+			}
+			if err = rows.Scan(pointers...); err != nil {
+				return nil, err
+			}
+			for i, v := range values {
+				if b, ok := v.([]byte); ok {
+					values[i] = string(b)
+				}
+			}
+			var id any = values
+			if len(pk) == 1 {
+				id = values[0]
+			}
+			keys = append(keys, record.NewKeyWithID(collection, id))
+		}
+		return keys, rows.Err()
+	}}
+}
+
+// write executes f outside a transaction, unless the change is recorded
+// in the history or the changelog, or is made with an idempotency key,
+// which are then written in the transaction of the change.
+func (dtb *database) write(ctx context.Context, c change, f func(query queryExecutor, exec statementExecutor) error) error {
+	if _, idempotent := IdempotencyKeyFromContext(ctx); !idempotent && !dtb.options.tracksChanges(c.recordsets) {
+		return f(dtb.query, dtb.exec)
+	}
+	return dtb.RunReadwriteTransaction(ctx, func(ctx context.Context, tx dal.ReadwriteTransaction) error {
+		return tx.(transaction).write(ctx, c, f)
+	})
+}
+
+// write executes f and records the change in the history and the changelog,
+// unless it is replayed for an idempotency key.
+func (t transaction) write(ctx context.Context, c change, f func(query queryExecutor, exec statementExecutor) error) error {
+	defer t.identities.forget(ctx, c)
+	key, idempotent := IdempotencyKeyFromContext(ctx)
+	if !idempotent {
+		return t.track(ctx, c, f)
+	}
+	request, err := idempotencyRequest(ctx, t.sqlOptions, c)
+	if err != nil {
+		return err
+	}
+	if replayed, err := replayIdempotent(ctx, t.sqlOptions, t.query, key, request, c); err != nil || replayed {
+		return err
+	}
+	if err := t.track(ctx, c, f); err != nil {
+		return err
+	}
+	return storeIdempotent(ctx, t.sqlOptions, t.exec, key, request, c)
+}
+
+// track executes f and records the change in the history and the changelog.
+func (t transaction) track(ctx context.Context, c change, f func(query queryExecutor, exec statementExecutor) error) error {
+	options := t.sqlOptions
+	if !options.tracksChanges(c.recordsets) {
+		return f(t.query, t.exec)
+	}
+	trackedKeys := func() ([]*record.Key, error) {
+		keys, err := c.keys(ctx, options, t.query)
+		return slices.DeleteFunc(slices.Clone(keys), func(key *record.Key) bool {
+			return !options.tracksChanges([]string{key.Collection()})
+		}), err
+	}
+	var keys []*record.Key
+	var before [][]byte
+	if !c.inserts {
+		var err error
+		if keys, err = trackedKeys(); err != nil {
+			return fmt.Errorf("failed to select changed rows: %w", err)
+		}
+		before = make([][]byte, len(keys))
+		for i, key := range keys {
+			if !options.recordsHistory([]string{key.Collection()}) {
+				continue // the changelog only needs after images
+			}
+			if before[i], err = rowImage(ctx, options, t.query, key); err != nil {
+				return err
+			}
+		}
+	}
+	if err := f(t.query, t.exec); err != nil {
+		return err
+	}
+	if c.inserts {
+		var err error
+		if keys, err = trackedKeys(); err != nil {
+			return err
+		}
+		before = make([][]byte, len(keys))
+	}
+	for i, key := range keys {
+		after, err := rowImage(ctx, options, t.query, key)
+		if err != nil {
+			return err
+		}
+		recordsets := []string{key.Collection()}
+		if options.recordsHistory(recordsets) {
+			if err = insertHistory(ctx, options, t.exec, key, before[i], after); err != nil {
+				return err
+			}
+		}
+		if options.logsChanges(recordsets) {
+			if err = insertChange(ctx, options, t.exec, key, after); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// rowImage returns the columns of the row with a key as a JSON object, or nil if there is no such row.
+func rowImage(ctx context.Context, options DbOptions, query queryExecutor, key *record.Key) ([]byte, error) {
+	collection := key.Collection()
+	scope, err := options.scope(ctx, collection)
+	if err != nil {
+		return nil, err
+	}
+	pk := options.PrimaryKeyFieldNames(key)
+	if len(pk) == 0 {
+		return nil, fmt.Errorf("primary key is not defined for %s", collection)
+	}
+	args, err := primaryKeyArgs(newColumnMapper(options, collection), pk, key)
+	if err != nil {
+		return nil, err
+	}
+	conditions := make([]string, len(pk))
+	for i, name := range pk {
+		conditions[i] = name + " = ?"
+	}
+	text := options.cachedSQL(
+		func() string { return statementShape("image", scope.shape(), strings.Join(conditions, ",")) },
+		func() string {
+			return options.Placeholder.rewritePlaceholders(fmt.Sprintf("SELECT * FROM %s WHERE %s%s",
+				scope.table, strings.Join(conditions, " AND "), scope.where()))
+		})
+	rows, err := options.traceQuery(collection, query)(ctx, text, append(args, scope.args...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image of %v: %w", key, err)
+	}
+	defer func() { _ = rows.Close() }()
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]any, len(columns))
+	pointers := make([]any, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	if err = rows.Scan(pointers...); err != nil {
+		return nil, fmt.Errorf("failed to read image of %v: %w", key, err)
+	}
+	image := make(map[string]any, len(columns))
+	for i, column := range columns {
+		if b, ok := values[i].([]byte); ok {
+			values[i] = string(b)
+		}
+		image[column] = values[i]
+	}
+	return json.Marshal(image)
+}
+
+// historyKey renders the ID of a key as stored in the history table: its
+// primary key values converted like SQL arguments, in the order of the
+// primary key columns and as "[a b]" for composite keys. Keys of the same
+// row built from Go values, record.FieldVal slices or scanned columns
+// render the same.
+func historyKey(options DbOptions, key *record.Key) (string, error) {
+	pk := options.PrimaryKeyFieldNames(key)
+	if len(pk) == 0 {
+		return fmt.Sprint(normalizeKeyValue(key.ID)), nil
+	}
+	values, err := primaryKeyArgs(newColumnMapper(options, key.Collection()), pk, key)
+	if err != nil {
+		return "", err
+	}
+	for i, v := range values {
+		values[i] = normalizeKeyValue(v)
+	}
+	if len(values) == 1 {
+		return fmt.Sprint(values[0]), nil
+	}
+	return fmt.Sprint(values), nil
+}
+
+func insertHistory(ctx context.Context, options DbOptions, exec statementExecutor, key *record.Key, before, after []byte) error {
+	table := options.historyTable()
+	scope, err := options.scope(ctx, table)
+	if err != nil {
+		return err
+	}
+	columns := append([]string{"recordset", "record_key", "operation", "before_image", "after_image", "actor", "changed_at"}, scope.columns...)
+	image := func(b []byte) any {
+		if b == nil {
+			return nil
+		}
+		return string(b)
+	}
+	var actor any
+	if identity, ok := IdentityFromContext(ctx); ok {
+		actor = identity
+	}
+	recordKey, err := historyKey(options, key)
+	if err != nil {
+		return err
+	}
+	args := append([]any{key.Collection(), recordKey, string(operationFromContext(ctx)), image(before), image(after), actor, options.now()},
+		scope.values...)
+	text := options.cachedSQL(
+		func() string { return statementShape("history", scope.table, strings.Join(columns, ",")) },
+		func() string {
+			return options.Placeholder.rewritePlaceholders(fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+				scope.table, strings.Join(columns, ", "), strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")))
+		})
+	if _, err = options.traceStatement(table, exec)(ctx, text, args...); err != nil {
+		return fmt.Errorf("failed to record history of %v: %w", key, err)
+	}
+	return nil
+}
+
+func readHistory(ctx context.Context, options DbOptions, query queryExecutor, key *record.Key) ([]HistoryEntry, error) {
+	table := options.historyTable()
+	scope, err := options.scope(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+	text := options.Placeholder.rewritePlaceholders(fmt.Sprintf(
+		"SELECT id, recordset, record_key, operation, before_image, after_image, actor, changed_at FROM %s"+
+			" WHERE recordset = ? AND record_key = ?%s ORDER BY id", scope.table, scope.where()))
+	recordKey, err := historyKey(options, key)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := options.traceQuery(table, query)(ctx, text, append([]any{key.Collection(), recordKey}, scope.args...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history of %v: %w", key, err)
+	}
+	defer func() { _ = rows.Close() }()
+	var entries []HistoryEntry
+	for rows.Next() {
+		var entry HistoryEntry
+		var before, after, actor sql.NullString
+		if err = rows.Scan(&entry.ID, &entry.Recordset, &entry.Key, &entry.Operation, &before, &after, &actor, &entry.ChangedAt); err != nil {
+			return nil, fmt.Errorf("failed to read history of %v: %w", key, err)
+		}
+		entry.Actor = actor.String
+		for _, image := range []struct {
+			text sql.NullString
+			to   *map[string]any
+		}{{before, &entry.Before}, {after, &entry.After}} {
+			if image.text.Valid {
+				if err = json.Unmarshal([]byte(image.text.String), image.to); err != nil {
+					return nil, fmt.Errorf("failed to decode history image of %v: %w", key, err)
+				}
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,206 @@
+package dalgo2sql
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dal-go/dalgo/dal"
+	dalrecord "github.com/dal-go/record"
+	"github.com/dal-go/record/update"
+)
+
+func TestHistory_SQLite(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	options := DbOptions{
+		Clock:        func() time.Time { return now },
+		HistoryTable: "changes",
+		Recordsets: map[string]*Recordset{
+			"items": NewRecordset("items", Table, []dal.FieldRef{dal.Field("ID")}, WithHistory()),
+			"logs":  NewRecordset("logs", Table, []dal.FieldRef{dal.Field("ID")}),
+		},
+	}
+	sqlDB := openTestSQLiteDB(t, `
+		CREATE TABLE items (ID TEXT PRIMARY KEY, Name TEXT);
+		CREATE TABLE logs (ID TEXT PRIMARY KEY, Name TEXT);
+		CREATE TABLE changes (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			recordset TEXT NOT NULL,
+			record_key TEXT NOT NULL,
+			operation TEXT NOT NULL,
+			before_image TEXT,
+			after_image TEXT,
+			actor TEXT,
+			changed_at TIMESTAMP NOT NULL
+		);`)
+	db := dal.BackendOf(NewDatabase(sqlDB, newSchema(), options)).(*database)
+	ctx := WithIdentity(context.Background(), "alice")
+
+	type item struct {
+		Name string
+	}
+	key := func(id string) *dalrecord.Key { return dalrecord.NewKeyWithID("items", id) }
+	name := func(name string) map[string]any { return map[string]any{"ID": "i1", "Name": name} }
+	count := func(t *testing.T) (n int) {
+		t.Helper()
+		if err := sqlDB.QueryRow(`SELECT COUNT(*) FROM changes`).Scan(&n); err != nil {
+			t.Fatalf("COUNT: %v", err)
+		}
+		return n
+	}
+
+	if err := db.Insert(ctx, dalrecord.NewRecordWithData(key("i1"), &item{Name: "one"})); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	if err := db.Set(ctx, dalrecord.NewRecordWithData(key("i1"), &item{Name: "two"})); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := db.Update(ctx, key("i1"), []update.Update{update.ByFieldName("Name", "three")}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	everything := dal.Comparison{Operator: dal.GreaterThen, Left: dal.Field("Name"), Right: dal.Constant{Value: ""}}
+	if n, err := db.UpdateWhere(ctx, "items", everything, []update.Update{update.ByFieldName("Name", "four")}); err != nil || n != 1 {
+		t.Fatalf("UpdateWhere = %d, %v; want 1", n, err)
+	}
+	if err := db.Delete(context.Background(), key("i1")); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	entries, err := db.History(ctx, key("i1"))
+	if err != nil {
+		t.Fatalf("History: %v", err)
+	}
+	want := []struct {
+		op            Operation
+		before, after map[string]any
+		actor         string
+	}{
+		{OpInsert, nil, name("one"), "alice"},
+		{OpSet, name("one"), name("two"), "alice"},
+		{OpUpdate, name("two"), name("three"), "alice"},
+		{OpUpdateWhere, name("three"), name("four"), "alice"},
+		{OpDelete, name("four"), nil, ""},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("got %d entries, want %d: %+v", len(entries), len(want), entries)
+	}
+	for i, w := range want {
+		e := entries[i]
+		if e.Operation != w.op || e.Recordset != "items" || e.Key != "i1" || e.Actor != w.actor {
+			t.Errorf("entry #%d = %s %s/%s by %q, want %s items/i1 by %q", i, e.Operation, e.Recordset, e.Key, e.Actor, w.op, w.actor)
+		}
+		if !reflect.DeepEqual(e.Before, w.before) || !reflect.DeepEqual(e.After, w.after) {
+			t.Errorf("entry #%d images = %v -> %v, want %v -> %v", i, e.Before, e.After, w.before, w.after)
+		}
+		if !e.ChangedAt.Equal(now) {
+			t.Errorf("entry #%d changed at %v, want %v", i, e.ChangedAt, now)
+		}
+		if i > 0 && e.ID <= entries[i-1].ID {
+			t.Errorf("entry #%d is out of order", i)
+		}
+	}
+
+	t.Run("rolled_back", func(t *testing.T) {
+		before := count(t)
+		if err := db.Insert(ctx, dalrecord.NewRecordWithData(key("i2"), &item{Name: "x"})); err != nil {
+			t.Fatalf("Insert: %v", err)
+		}
+		if err := db.Insert(ctx, dalrecord.NewRecordWithData(key("i2"), &item{Name: "duplicate"})); err == nil {
+			t.Fatal("expected a duplicate insert to fail")
+		}
+		if n := count(t); n != before+1 {
+			t.Errorf("%d entries, want %d: a failed write must not be recorded", n, before+1)
+		}
+	})
+
+	t.Run("transaction", func(t *testing.T) {
+		before := count(t)
+		err := db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx dal.ReadwriteTransaction) error {
+			if err := tx.Set(ctx, dalrecord.NewRecordWithData(key("i3"), &item{Name: "tx"})); err != nil {
+				return err
+			}
+			entries, err := tx.(HistoryReader).History(ctx, key("i3"))
+			if err != nil || len(entries) != 1 {
+				t.Errorf("History in transaction = %d entries, %v; want 1", len(entries), err)
+			}
+			return context.Canceled
+		})
+		if err == nil {
+			t.Fatal("expected the transaction to fail")
+		}
+		if n := count(t); n != before {
+			t.Errorf("%d entries, want %d after rollback", n, before)
+		}
+	})
+
+	t.Run("not_recorded", func(t *testing.T) {
+		before := count(t)
+		if err := db.Insert(ctx, dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("logs", "l1"), &item{Name: "log"})); err != nil {
+			t.Fatalf("Insert: %v", err)
+		}
+		if n := count(t); n != before {
+			t.Errorf("%d entries, want %d for a recordset without history", n, before)
+		}
+	})
+}
+
+func TestHistory_CompositeKey_SQLite(t *testing.T) {
+	options := DbOptions{
+		Recordsets: map[string]*Recordset{
+			"memberships": NewRecordset("memberships", Table, []dal.FieldRef{dal.Field("TeamID"), dal.Field("UserID")}, WithHistory()),
+		},
+	}
+	sqlDB := openTestSQLiteDB(t, `
+		CREATE TABLE memberships (TeamID TEXT, UserID INTEGER, Role TEXT, PRIMARY KEY (TeamID, UserID));
+		CREATE TABLE dalgo_history (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			recordset TEXT NOT NULL,
+			record_key TEXT NOT NULL,
+			operation TEXT NOT NULL,
+			before_image TEXT,
+			after_image TEXT,
+			actor TEXT,
+			changed_at TIMESTAMP NOT NULL
+		);`)
+	db := dal.BackendOf(NewDatabase(sqlDB, newSchema(), options)).(*database)
+	ctx := context.Background()
+
+	type membership struct {
+		Role string
+	}
+	// Fields out of the order of the primary key columns.
+	key := dalrecord.NewKeyWithFields("memberships",
+		dalrecord.FieldVal{Name: "UserID", Value: 1}, dalrecord.FieldVal{Name: "TeamID", Value: "t1"})
+
+	if err := db.Insert(ctx, dalrecord.NewRecordWithData(key, &membership{Role: "member"})); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	members := dal.Comparison{Operator: dal.Equal, Left: dal.Field("Role"), Right: dal.Constant{Value: "member"}}
+	if n, err := db.UpdateWhere(ctx, "memberships", members, []update.Update{update.ByFieldName("Role", "admin")}); err != nil || n != 1 {
+		t.Fatalf("UpdateWhere = %d, %v; want 1", n, err)
+	}
+	// Update does not support composite keys, DeleteMulti writes by key instead.
+	if err := db.DeleteMulti(ctx, []*dalrecord.Key{key}); err != nil {
+		t.Fatalf("DeleteMulti: %v", err)
+	}
+
+	inOrder := dalrecord.NewKeyWithFields("memberships",
+		dalrecord.FieldVal{Name: "TeamID", Value: "t1"}, dalrecord.FieldVal{Name: "UserID", Value: int64(1)})
+	for _, k := range []*dalrecord.Key{key, inOrder} {
+		entries, err := db.History(ctx, k)
+		if err != nil {
+			t.Fatalf("History(%v): %v", k.ID, err)
+		}
+		var ops []Operation
+		for _, e := range entries {
+			ops = append(ops, e.Operation)
+			if e.Key != "[t1 1]" {
+				t.Errorf("entry %s has key %q, want %q", e.Operation, e.Key, "[t1 1]")
+			}
+		}
+		if want := []Operation{OpInsert, OpUpdateWhere, OpDeleteMulti}; !reflect.DeepEqual(ops, want) {
+			t.Errorf("History(%v) = %v, want %v", k.ID, ops, want)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package dalgo2sql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/dal-go/dalgo/dal"
+)
+
+// Operation identifies the DALgo method that executes a statement.
+type Operation string
+
+const (
+	OpGet         Operation = "Get"
+	OpGetMulti    Operation = "GetMulti"
+	OpExists      Operation = "Exists"
+	OpInsert      Operation = "Insert"
+	OpInsertMulti Operation = "InsertMulti"
+	OpSet         Operation = "Set"
+	OpSetMulti    Operation = "SetMulti"
+	OpUpdate      Operation = "Update"
+	OpUpdateMulti Operation = "UpdateMulti"
+	OpUpdateWhere Operation = "UpdateWhere"
+	OpDelete      Operation = "Delete"
+	OpDeleteMulti Operation = "DeleteMulti"
+	OpDeleteWhere Operation = "DeleteWhere"
+	OpQuery       Operation = "Query"
+	OpRestore     Operation = "Restore"
+	OpPurge       Operation = "Purge"
+)
+
+// Statement describes an SQL statement reported to Hooks.
+type Statement struct {
+	Operation Operation
+	// Recordset is the table the statement reads or writes, if known.
+	Recordset string
+	SQL       string
+	// Args are the bound arguments. Hooks must not modify them.
+	Args []any
+	// ArgCount is len(Args), for hooks that should not look at argument values.
+	ArgCount int
+}
+
+// StatementResult describes the outcome of a statement reported to Hooks.
+type StatementResult struct {
+	// Duration is the time the driver took to execute the statement. For
+	// queries it does not include reading the returned rows.
+	Duration time.Duration
+	// RowsAffected is the number of rows changed by a statement,
+	// or -1 for queries and when the driver does not report it.
+	RowsAffected int64
+	Err          error
+}
+
+// Hooks observes every SQL statement executed by the database and its
+// transactions, e.g. for logging (see NewSlogHooks) or tracing.
+// Implementations must be safe for concurrent use.
+type Hooks interface {
+	// BeforeStatement is called before a statement is executed. The returned
+	// context is passed to AfterStatement and, where the driver call accepts
+	// a context, to the driver - e.g. to carry a tracing span.
+	BeforeStatement(ctx context.Context, stmt Statement) context.Context
+	// AfterStatement is called once the statement has been executed.
+	AfterStatement(ctx context.Context, stmt Statement, result StatementResult)
+}
+
+type operationContextKey struct{}
+
+// withOperation records the DALgo method in the context for Hooks. The
+// outermost method wins, so the existence check done by Set is reported as
+// part of Set rather than as Exists.
+func withOperation(ctx context.Context, op Operation) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if _, ok := ctx.Value(operationContextKey{}).(Operation); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, operationContextKey{}, op)
+}
+
+func operationFromContext(ctx context.Context) Operation {
+	if ctx == nil {
+		return ""
+	}
+	op, _ := ctx.Value(operationContextKey{}).(Operation)
+	return op
+}
+
+// runStatement reports a statement to options.Hooks around its execution
+// and its latency to options.Metrics.
+// execute returns the number of affected rows or -1.
+func (o DbOptions) runStatement(
+	ctx context.Context, recordset, query string, args []any,
+	execute func(ctx context.Context) (int64, error),
+) error {
+	if o.Hooks == nil && o.Metrics == nil {
+		_, err := execute(ctx)
+		return err
+	}
+	stmt := Statement{
+		Operation: operationFromContext(ctx),
+		Recordset: recordset,
+		SQL:       query,
+		Args:      args,
+		ArgCount:  len(args),
+	}
+	if o.Hooks != nil {
+		ctx = o.Hooks.BeforeStatement(ctx, stmt)
+	}
+	started := time.Now()
+	rowsAffected, err := execute(ctx)
+	result := StatementResult{
+		Duration:     time.Since(started),
+		RowsAffected: rowsAffected,
+		Err:          err,
+	}
+	if o.Metrics != nil {
+		o.Metrics.ObserveLatency(stmt.Operation, recordset, result.Duration)
+		if err != nil {
+			o.Metrics.AddCount(stmt.Operation, recordset, CounterErrors, 1)
+		}
+	}
+	if o.Hooks != nil {
+		o.Hooks.AfterStatement(ctx, stmt, result)
+	}
+	return err
+}
+
+// traceQuery wraps exec to report the queries it executes to options.Hooks.
+func (o DbOptions) traceQuery(recordset string, exec queryExecutor) queryExecutor {
+	if o.Hooks == nil && o.Metrics == nil {
+		return exec
+	}
+	return func(ctx context.Context, query string, args ...interface{}) (rows *sql.Rows, err error) {
+		err = o.runStatement(ctx, recordset, query, args, func(ctx context.Context) (int64, error) {
+			rows, err = exec(ctx, query, args...)
+			return -1, err
+		})
+		return
+	}
+}
+
+// traceStatement wraps exec to report the statements it executes to options.Hooks.
+func (o DbOptions) traceStatement(recordset string, exec statementExecutor) statementExecutor {
+	if o.Hooks == nil && o.Metrics == nil {
+		return exec
+	}
+	return func(ctx context.Context, query string, args ...interface{}) (result sql.Result, err error) {
+		err = o.runStatement(ctx, recordset, query, args, func(ctx context.Context) (int64, error) {
+			if result, err = exec(ctx, query, args...); err != nil {
+				return -1, err
+			}
+			rowsAffected, rowsErr := result.RowsAffected()
+			if rowsErr != nil {
+				return -1, nil
+			}
+			return rowsAffected, nil
+		})
+		return
+	}
+}
+
+// queryRecordsetName returns the recordset a structured query selects from.
+func queryRecordsetName(query dal.Query) string {
+	q, ok := query.(dal.StructuredQuery)
+	if !ok {
+		return ""
+	}
+	from := q.From()
+	if from == nil {
+		return ""
+	}
+	base := from.Base()
+	if base == nil {
+		return ""
+	}
+	return base.Name()
+}
@@ -0,0 +1,117 @@
+package dalgo2sql
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// ArgRedactor returns the value to log for the i-th argument of a statement.
+type ArgRedactor func(stmt Statement, i int, arg any) any
+
+// RedactStringArgs is an ArgRedactor that hides string and []byte arguments,
+// which are the ones most likely to hold personal data or secrets, and logs
+// other arguments as is.
+func RedactStringArgs(_ Statement, _ int, arg any) any {
+	switch arg.(type) {
+	case string, []byte:
+		return "[REDACTED]"
+	default:
+		return arg
+	}
+}
+
+// SlogOption customizes the Hooks created by NewSlogHooks.
+type SlogOption func(h *slogHooks)
+
+// WithStatementLogLevel sets the level statements are logged at (slog.LevelDebug by default).
+// Failed statements are logged at slog.LevelError and slow ones at slog.LevelWarn.
+func WithStatementLogLevel(level slog.Level) SlogOption {
+	return func(h *slogHooks) {
+		h.level = level
+	}
+}
+
+// WithSlowStatementThreshold makes statements that take at least d to
+// execute be logged at slog.LevelWarn with a "slow" attribute.
+func WithSlowStatementThreshold(d time.Duration) SlogOption {
+	return func(h *slogHooks) {
+		h.slowThreshold = d
+	}
+}
+
+// WithLoggedArgs makes statement arguments be logged, passed through redact
+// unless it is nil. By default only the number of arguments is logged.
+func WithLoggedArgs(redact ArgRedactor) SlogOption {
+	return func(h *slogHooks) {
+		h.logArgs = true
+		h.redact = redact
+	}
+}
+
+// NewSlogHooks returns Hooks that log every executed statement to logger
+// with its operation, recordset, SQL, argument count, duration,
+// number of affected rows and error.
+func NewSlogHooks(logger *slog.Logger, options ...SlogOption) Hooks {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	h := &slogHooks{logger: logger, level: slog.LevelDebug}
+	for _, o := range options {
+		o(h)
+	}
+	return h
+}
+
+type slogHooks struct {
+	logger        *slog.Logger
+	level         slog.Level
+	slowThreshold time.Duration
+	logArgs       bool
+	redact        ArgRedactor
+}
+
+func (h *slogHooks) BeforeStatement(ctx context.Context, _ Statement) context.Context {
+	return ctx
+}
+
+func (h *slogHooks) AfterStatement(ctx context.Context, stmt Statement, result StatementResult) {
+	level := h.level
+	slow := h.slowThreshold > 0 && result.Duration >= h.slowThreshold
+	switch {
+	case result.Err != nil:
+		level = slog.LevelError
+	case slow:
+		level = max(level, slog.LevelWarn)
+	}
+	if !h.logger.Enabled(ctx, level) {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("operation", string(stmt.Operation)),
+		slog.String("recordset", stmt.Recordset),
+		slog.String("sql", stmt.SQL),
+		slog.Int("args_count", stmt.ArgCount),
+		slog.Duration("duration", result.Duration),
+	}
+	if result.RowsAffected >= 0 {
+		attrs = append(attrs, slog.Int64("rows_affected", result.RowsAffected))
+	}
+	if h.logArgs {
+		args := make([]any, len(stmt.Args))
+		for i, arg := range stmt.Args {
+			if h.redact != nil {
+				arg = h.redact(stmt, i, arg)
+			}
+			args[i] = arg
+		}
+		attrs = append(attrs, slog.Any("args", args))
+	}
+	if slow {
+		attrs = append(attrs, slog.Bool("slow", true))
+	}
+	if result.Err != nil {
+		attrs = append(attrs, slog.Any("error", result.Err))
+	}
+	h.logger.LogAttrs(ctx, level, "sql statement", attrs...)
+}
@@ -0,0 +1,107 @@
+package dalgo2sql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSlogHooks(t *testing.T) {
+	ctx := context.Background()
+	stmt := Statement{
+		Operation: OpInsert,
+		Recordset: "users",
+		SQL:       "INSERT INTO users (ID, Name, Age) VALUES (?, ?, ?)",
+		Args:      []any{"u1", "John", 42},
+		ArgCount:  3,
+	}
+	log := func(t *testing.T, result StatementResult, options ...SlogOption) map[string]any {
+		t.Helper()
+		var buf bytes.Buffer
+		logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo}))
+		hooks := NewSlogHooks(logger, options...)
+		hooks.AfterStatement(hooks.BeforeStatement(ctx, stmt), stmt, result)
+		if buf.Len() == 0 {
+			return nil
+		}
+		var entry map[string]any
+		if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+			t.Fatalf("invalid log entry %q: %v", buf.String(), err)
+		}
+		return entry
+	}
+	ok := StatementResult{Duration: time.Millisecond, RowsAffected: 1}
+
+	t.Run("below_logger_level", func(t *testing.T) {
+		if entry := log(t, ok); entry != nil {
+			t.Errorf("expected debug entry to be filtered out, got %v", entry)
+		}
+	})
+
+	t.Run("fields", func(t *testing.T) {
+		entry := log(t, ok, WithStatementLogLevel(slog.LevelInfo))
+		want := map[string]any{
+			"level":         "INFO",
+			"msg":           "sql statement",
+			"operation":     "Insert",
+			"recordset":     "users",
+			"sql":           stmt.SQL,
+			"args_count":    3.0,
+			"duration":      float64(time.Millisecond),
+			"rows_affected": 1.0,
+		}
+		delete(entry, "time")
+		if !reflect.DeepEqual(entry, want) {
+			t.Errorf("got %v, want %v", entry, want)
+		}
+	})
+
+	t.Run("args", func(t *testing.T) {
+		entry := log(t, ok, WithStatementLogLevel(slog.LevelInfo), WithLoggedArgs(nil))
+		if want := []any{"u1", "John", 42.0}; !reflect.DeepEqual(entry["args"], want) {
+			t.Errorf("args = %v, want %v", entry["args"], want)
+		}
+	})
+
+	t.Run("redacted_args", func(t *testing.T) {
+		entry := log(t, ok, WithStatementLogLevel(slog.LevelInfo), WithLoggedArgs(RedactStringArgs))
+		if want := []any{"[REDACTED]", "[REDACTED]", 42.0}; !reflect.DeepEqual(entry["args"], want) {
+			t.Errorf("args = %v, want %v", entry["args"], want)
+		}
+		if strings.Contains(entry["sql"].(string), "John") {
+			t.Error("argument leaked into SQL")
+		}
+	})
+
+	t.Run("slow", func(t *testing.T) {
+		entry := log(t, ok, WithSlowStatementThreshold(time.Millisecond))
+		if entry["level"] != "WARN" || entry["slow"] != true {
+			t.Errorf("expected a slow WARN entry, got %v", entry)
+		}
+		if entry = log(t, ok, WithSlowStatementThreshold(time.Second)); entry != nil {
+			t.Errorf("expected fast statement to be logged at debug level, got %v", entry)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		entry := log(t, StatementResult{Duration: time.Millisecond, RowsAffected: -1, Err: errors.New("boom")})
+		if entry["level"] != "ERROR" || entry["error"] != "boom" {
+			t.Errorf("expected an ERROR entry, got %v", entry)
+		}
+		if _, ok := entry["rows_affected"]; ok {
+			t.Error("rows_affected should be omitted when unknown")
+		}
+	})
+
+	t.Run("nil_logger_uses_default", func(t *testing.T) {
+		if h := NewSlogHooks(nil).(*slogHooks); h.logger != slog.Default() {
+			t.Error("expected slog.Default()")
+		}
+	})
+}
@@ -0,0 +1,222 @@
+package dalgo2sql
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dal-go/dalgo/dal"
+	dalrecord "github.com/dal-go/record"
+	"github.com/dal-go/record/update"
+)
+
+type hookCall struct {
+	stmt   Statement
+	result StatementResult
+	traced bool // the context returned by BeforeStatement reached AfterStatement
+}
+
+type recordingHooks struct {
+	mu    sync.Mutex
+	calls []hookCall
+}
+
+type traceContextKey struct{}
+
+func (h *recordingHooks) BeforeStatement(ctx context.Context, _ Statement) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, true)
+}
+
+func (h *recordingHooks) AfterStatement(ctx context.Context, stmt Statement, result StatementResult) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	traced, _ := ctx.Value(traceContextKey{}).(bool)
+	h.calls = append(h.calls, hookCall{stmt: stmt, result: result, traced: traced})
+}
+
+func (h *recordingHooks) reset() []hookCall {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	calls := h.calls
+	h.calls = nil
+	return calls
+}
+
+func TestHooks_SQLite(t *testing.T) {
+	ctx := context.Background()
+	hooks := new(recordingHooks)
+	db := dal.BackendOf(NewDatabase(openTestSQLiteDB(t, `CREATE TABLE users (ID TEXT PRIMARY KEY, Name TEXT)`), newSchema(), DbOptions{
+		Hooks: hooks,
+		Recordsets: map[string]*Recordset{
+			"users": NewRecordset("users", Table, []dal.FieldRef{dal.Field("ID")}),
+		},
+	})).(*database)
+	type user struct {
+		Name string
+	}
+	key := dalrecord.NewKeyWithID("users", "u1")
+	where := dal.Comparison{Operator: dal.Equal, Left: dal.Field("Name"), Right: dal.Constant{Value: "nobody"}}
+
+	type wantCall struct {
+		op           Operation
+		recordset    string
+		sqlPrefix    string
+		argCount     int
+		rowsAffected int64
+		failed       bool
+	}
+	tests := []struct {
+		name string
+		run  func() error
+		want []wantCall
+	}{
+		{
+			name: "Insert",
+			run: func() error {
+				return db.Insert(ctx, dalrecord.NewRecordWithData(key, &user{Name: "John"}))
+			},
+			want: []wantCall{{OpInsert, "users", "INSERT INTO users", 2, 1, false}},
+		},
+		{
+			name: "Get",
+			run:  func() error { return db.Get(ctx, dalrecord.NewRecordWithData(key, &user{})) },
+			want: []wantCall{{OpGet, "users", "SELECT Name FROM users", 1, -1, false}},
+		},
+		{
+			name: "Set_reports_the_existence_check_as_part_of_Set",
+			run:  func() error { return db.Set(ctx, dalrecord.NewRecordWithData(key, &user{Name: "Jo"})) },
+			want: []wantCall{
+				{OpSet, "users", "SELECT ID FROM users", 1, -1, false},
+				{OpSet, "users", "UPDATE users SET", 2, 1, false},
+			},
+		},
+		{
+			name: "Exists",
+			run: func() error {
+				_, err := db.Exists(ctx, key)
+				return err
+			},
+			want: []wantCall{{OpExists, "users", "SELECT 1 FROM users", 1, -1, false}},
+		},
+		{
+			name: "GetMulti",
+			run: func() error {
+				return db.GetMulti(ctx, []dalrecord.Record{
+					dalrecord.NewRecordWithData(key, &user{}),
+					dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("users", "u2"), &user{}),
+				})
+			},
+			want: []wantCall{{OpGetMulti, "users", "SELECT ID, Name FROM users", 2, -1, false}},
+		},
+		{
+			name: "Update",
+			run: func() error {
+				return db.Update(ctx, key, []update.Update{update.ByFieldName("Name", "Joe")})
+			},
+			want: []wantCall{{OpUpdate, "users", "UPDATE users SET", 2, 1, false}},
+		},
+		{
+			name: "DeleteWhere",
+			run: func() error {
+				_, err := db.DeleteWhere(ctx, "users", where)
+				return err
+			},
+			want: []wantCall{{OpDeleteWhere, "users", "DELETE FROM users WHERE", 1, 0, false}},
+		},
+		{
+			name: "Query",
+			run: func() error {
+				reader, err := db.ExecuteQueryToRecordsReader(ctx, dal.NewTextQuery("SELECT Name FROM users", nil))
+				if err == nil {
+					err = reader.Close()
+				}
+				return err
+			},
+			want: []wantCall{{OpQuery, "", "SELECT Name FROM users", 0, -1, false}},
+		},
+		{
+			name: "Delete",
+			run:  func() error { return db.Delete(ctx, key) },
+			want: []wantCall{{OpDelete, "users", "DELETE FROM users WHERE", 1, 1, false}},
+		},
+		{
+			name: "failed_statement",
+			run: func() error {
+				_, _ = db.DeleteWhere(ctx, "no_such_table", where)
+				return nil
+			},
+			want: []wantCall{{OpDeleteWhere, "no_such_table", "DELETE FROM no_such_table", 1, -1, true}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hooks.reset()
+			if err := tt.run(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			calls := hooks.reset()
+			if len(calls) != len(tt.want) {
+				t.Fatalf("expected %d statements, got %d: %+v", len(tt.want), len(calls), calls)
+			}
+			for i, w := range tt.want {
+				c := calls[i]
+				if c.stmt.Operation != w.op || c.stmt.Recordset != w.recordset {
+					t.Errorf("#%d: operation/recordset = %s/%s, want %s/%s", i, c.stmt.Operation, c.stmt.Recordset, w.op, w.recordset)
+				}
+				if !strings.HasPrefix(c.stmt.SQL, w.sqlPrefix) {
+					t.Errorf("#%d: SQL = %q, want prefix %q", i, c.stmt.SQL, w.sqlPrefix)
+				}
+				if c.stmt.ArgCount != w.argCount || len(c.stmt.Args) != w.argCount {
+					t.Errorf("#%d: ArgCount = %d (%d args), want %d", i, c.stmt.ArgCount, len(c.stmt.Args), w.argCount)
+				}
+				if c.result.RowsAffected != w.rowsAffected {
+					t.Errorf("#%d: RowsAffected = %d, want %d", i, c.result.RowsAffected, w.rowsAffected)
+				}
+				if (c.result.Err != nil) != w.failed {
+					t.Errorf("#%d: Err = %v, want failed=%v", i, c.result.Err, w.failed)
+				}
+				if c.result.Duration < 0 {
+					t.Errorf("#%d: Duration = %v, want >= 0", i, c.result.Duration)
+				}
+				if !c.traced {
+					t.Errorf("#%d: context returned by BeforeStatement was not passed to AfterStatement", i)
+				}
+			}
+		})
+	}
+
+	t.Run("transaction", func(t *testing.T) {
+		hooks.reset()
+		err := db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx dal.ReadwriteTransaction) error {
+			return tx.Insert(ctx, dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("users", "u3"), &user{Name: "Tx"}))
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if calls := hooks.reset(); len(calls) != 1 || calls[0].stmt.Operation != OpInsert || calls[0].result.RowsAffected != 1 {
+			t.Errorf("unexpected calls: %+v", calls)
+		}
+	})
+}
+
+func TestWithOperation(t *testing.T) {
+	ctx := context.Background()
+	if op := operationFromContext(ctx); op != "" {
+		t.Errorf("expected no operation, got %q", op)
+	}
+	ctx = withOperation(ctx, OpSet)
+	if op := operationFromContext(withOperation(ctx, OpExists)); op != OpSet {
+		t.Errorf("expected the outermost operation %q, got %q", OpSet, op)
+	}
+}
+
+func TestQueryRecordsetName(t *testing.T) {
+	if name := queryRecordsetName(dal.NewTextQuery("SELECT 1", nil)); name != "" {
+		t.Errorf("text query: got %q", name)
+	}
+	q := dal.NewQueryBuilder(dal.From(dal.NewRootCollectionRef("users", ""))).SelectIntoRecordset()
+	if name := queryRecordsetName(q); name != "users" {
+		t.Errorf("structured query: got %q, want users", name)
+	}
+}
@@ -0,0 +1,255 @@
+package dalgo2sql
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DefaultIdempotencyTable is used when DbOptions.IdempotencyTable is not set.
+const DefaultIdempotencyTable = "dalgo_idempotency"
+
+// DefaultIdempotencyTTL is used when DbOptions.IdempotencyTTL is not set.
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// ErrIdempotencyKeyReused is returned by writes made with an idempotency key
+// that has already been used by a different write.
+var ErrIdempotencyKeyReused = errors.New("idempotency key is used by another write")
+
+type idempotencyKeyContextKey struct{}
+
+// WithIdempotencyKey returns a context that makes the writes of this adapter
+// made with it idempotent: the key is stored with the outcome of the
+// write in DbOptions.IdempotencyTable, in the transaction of the write, and
+// a write repeated with the key returns the stored outcome without being
+// executed again, until the key expires after DbOptions.IdempotencyTTL.
+// Reusing the key for a write of other keys, record data, updates or where
+// condition fails with ErrIdempotencyKeyReused.
+//
+// The outcome is the number of rows affected by UpdateWhere and DeleteWhere
+// and the keys of inserted records, so a repeated Insert gets the ID that was
+// generated by the first one. Writes that fail are rolled back with their
+// key and are executed again when repeated. Use a context per write: every
+// write made with the context uses the key. The table is scoped by Tenancy
+// like the recordsets, unless it is listed in Tenancy.Shared, and is expected
+// to look like:
+//
+//	CREATE TABLE dalgo_idempotency (
+//		idempotency_key TEXT PRIMARY KEY, -- (tenant_id, idempotency_key) with TenantColumn
+//		request TEXT NOT NULL,
+//		outcome TEXT NOT NULL,
+//		created_at TIMESTAMP NOT NULL,
+//		expires_at TIMESTAMP NOT NULL
+//	)
+//
+// Expired keys are replaced when reused. Declaring the table as a recordset
+// WithExpiry("") lets an ExpirySweeper delete them.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyContextKey{}, key)
+}
+
+// IdempotencyKeyFromContext returns the key set by WithIdempotencyKey.
+func IdempotencyKeyFromContext(ctx context.Context) (key string, ok bool) {
+	if ctx == nil {
+		return "", false
+	}
+	key, ok = ctx.Value(idempotencyKeyContextKey{}).(string)
+	return key, ok && key != ""
+}
+
+func (o DbOptions) idempotencyTable() string {
+	if o.IdempotencyTable == "" {
+		return DefaultIdempotencyTable
+	}
+	return o.IdempotencyTable
+}
+
+func (o DbOptions) idempotencyTTL() time.Duration {
+	if o.IdempotencyTTL <= 0 {
+		return DefaultIdempotencyTTL
+	}
+	return o.IdempotencyTTL
+}
+
+// idempotencyOutcome is stored as JSON with an idempotency key.
+type idempotencyOutcome struct {
+	Keys     []any  `json:"keys,omitempty"`
+	Affected *int64 `json:"affected,omitempty"`
+}
+
+// idempotencyRequest identifies the write a key is used by: its operation
+// and recordsets, followed by a hash of its keys, records, updates and where
+// condition. It is taken before the write, as inserts may generate the IDs
+// of their keys.
+func idempotencyRequest(ctx context.Context, options DbOptions, c change) (string, error) {
+	recordsets := slices.Compact(slices.Sorted(slices.Values(c.recordsets)))
+	request := string(operationFromContext(ctx)) + " " + strings.Join(recordsets, ",")
+	var parts []idempotencyPart
+	if c.condition != nil {
+		condition, args, err := buildCondition(c.condition)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, idempotencyPart{Kind: "where", Text: condition, Args: args})
+	} else {
+		keys, err := c.keys(ctx, options, nil)
+		if err != nil {
+			return "", err
+		}
+		for _, key := range keys {
+			args := []any{key.ID}
+			if pk := options.PrimaryKeyFieldNames(key); key.ID != nil && len(pk) > 0 {
+				if args, err = primaryKeyArgs(newColumnMapper(options, key.Collection()), pk, key); err != nil {
+					return "", err
+				}
+			}
+			parts = append(parts, idempotencyPart{Kind: "key", Recordset: key.Collection(), Args: args})
+		}
+	}
+	for _, r := range c.records {
+		columns, _, args, err := scopedRecordValues(insertOperation, options, statementScope{table: getRecordsetName(r.Key())}, r)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, idempotencyPart{Kind: "record", Recordset: r.Key().Collection(), Text: strings.Join(columns, ","), Args: args})
+	}
+	if len(c.updates) > 0 {
+		for _, recordset := range recordsets {
+			text, args, err := buildSetClause(options, recordset, c.updates)
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, idempotencyPart{Kind: "update", Recordset: recordset, Text: text, Args: args})
+		}
+	}
+	for _, part := range parts {
+		for i, arg := range part.Args {
+			part.Args[i] = canonicalArg(arg)
+		}
+	}
+	b, err := json.Marshal(parts)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode the write for its idempotency key: %w", err)
+	}
+	hash := sha256.Sum256(b)
+	return request + " " + hex.EncodeToString(hash[:]), nil
+}
+
+// idempotencyPart is an element of the canonical form of a write that
+// idempotencyRequest hashes: a key, record, update or where condition
+// with its SQL arguments.
+type idempotencyPart struct {
+	Kind      string `json:"kind"`
+	Recordset string `json:"recordset,omitempty"`
+	Text      string `json:"text,omitempty"`
+	Args      []any  `json:"args,omitempty"`
+}
+
+// canonicalArg converts an SQL argument the way the driver receives it,
+// so equal values encode the same: times lose their monotonic clock
+// reading and location.
+func canonicalArg(v any) any {
+	if dv, err := driver.DefaultParameterConverter.ConvertValue(v); err == nil {
+		v = dv
+	}
+	if t, ok := v.(time.Time); ok {
+		return t.Round(0).UTC()
+	}
+	return v
+}
+
+// replayIdempotent applies the stored outcome of a write made with the key
+// to the change and reports whether there was one.
+func replayIdempotent(ctx context.Context, options DbOptions, query queryExecutor, key, request string, c change) (bool, error) {
+	table := options.idempotencyTable()
+	scope, err := options.scope(ctx, table)
+	if err != nil {
+		return false, err
+	}
+	text := options.cachedSQL(
+		func() string { return statementShape("idempotencyGet", scope.shape()) },
+		func() string {
+			return options.Placeholder.rewritePlaceholders(fmt.Sprintf(
+				"SELECT request, outcome FROM %s WHERE idempotency_key = ? AND expires_at > ?%s", scope.table, scope.where()))
+		})
+	rows, err := options.traceQuery(table, query)(ctx, text, append([]any{key, options.now()}, scope.args...)...)
+	if err != nil {
+		return false, fmt.Errorf("failed to read idempotency key %q: %w", key, err)
+	}
+	defer func() { _ = rows.Close() }()
+	if !rows.Next() {
+		return false, rows.Err()
+	}
+	var used, stored string
+	if err = rows.Scan(&used, &stored); err != nil {
+		return false, fmt.Errorf("failed to read idempotency key %q: %w", key, err)
+	}
+	if used != request {
+		return false, fmt.Errorf("%w: %q is used by %s, not %s", ErrIdempotencyKeyReused, key, used, request)
+	}
+	var outcome idempotencyOutcome
+	if err = json.Unmarshal([]byte(stored), &outcome); err != nil {
+		return false, fmt.Errorf("failed to decode outcome of idempotency key %q: %w", key, err)
+	}
+	if c.affected != nil && outcome.Affected != nil {
+		*c.affected = *outcome.Affected
+	}
+	for i, r := range c.records {
+		if i < len(outcome.Keys) && r.Key().ID == nil {
+			r.Key().ID = outcome.Keys[i]
+		}
+	}
+	return true, nil
+}
+
+// storeIdempotent stores the key with the outcome of the change, replacing an expired use of the key.
+func storeIdempotent(ctx context.Context, options DbOptions, exec statementExecutor, key, request string, c change) error {
+	outcome := idempotencyOutcome{Affected: c.affected}
+	if c.inserts {
+		for _, r := range c.records {
+			outcome.Keys = append(outcome.Keys, r.Key().ID)
+		}
+	}
+	stored, err := json.Marshal(outcome)
+	if err != nil {
+		return fmt.Errorf("failed to encode outcome of idempotency key %q: %w", key, err)
+	}
+	table := options.idempotencyTable()
+	scope, err := options.scope(WithoutFilters(ctx), table)
+	if err != nil {
+		return err
+	}
+	now := options.now()
+	exec = options.traceStatement(table, exec)
+	text := options.cachedSQL(
+		func() string { return statementShape("idempotencyExpire", scope.shape()) },
+		func() string {
+			return options.Placeholder.rewritePlaceholders(fmt.Sprintf(
+				"DELETE FROM %s WHERE idempotency_key = ? AND expires_at <= ?%s", scope.table, scope.where()))
+		})
+	if _, err = exec(ctx, text, append([]any{key, now}, scope.args...)...); err != nil {
+		return fmt.Errorf("failed to replace expired idempotency key %q: %w", key, err)
+	}
+	columns := append([]string{"idempotency_key", "request", "outcome", "created_at", "expires_at"}, scope.columns...)
+	args := append([]any{key, request, string(stored), now, now.Add(options.idempotencyTTL())}, scope.values...)
+	text = options.cachedSQL(
+		func() string { return statementShape("idempotencyPut", scope.table, strings.Join(columns, ",")) },
+		func() string {
+			return options.Placeholder.rewritePlaceholders(fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+				scope.table, strings.Join(columns, ", "), strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")))
+		})
+	if _, err = exec(ctx, text, args...); err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("%w: %q is used by a concurrent write", ErrIdempotencyKeyReused, key)
+		}
+		return fmt.Errorf("failed to store idempotency key %q: %w", key, err)
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package dalgo2sql
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dal-go/dalgo/dal"
+	dalrecord "github.com/dal-go/record"
+	"github.com/dal-go/record/update"
+)
+
+func TestIdempotency_SQLite(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	options := DbOptions{
+		Clock:          func() time.Time { return now },
+		IdempotencyTTL: time.Hour,
+		Recordsets: map[string]*Recordset{
+			"items": NewRecordset("items", Table, []dal.FieldRef{dal.Field("ID")}),
+		},
+	}
+	sqlDB := openTestSQLiteDB(t, `
+		CREATE TABLE items (ID TEXT PRIMARY KEY, Name TEXT, Hits INTEGER NOT NULL DEFAULT 0, SeenAt TIMESTAMP);
+		CREATE TABLE dalgo_idempotency (
+			idempotency_key TEXT PRIMARY KEY,
+			request TEXT NOT NULL,
+			outcome TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			expires_at TIMESTAMP NOT NULL
+		);`)
+	db := dal.BackendOf(NewDatabase(sqlDB, newSchema(), options)).(*database)
+	ctx := context.Background()
+
+	type item struct {
+		Name string
+	}
+	count := func(t *testing.T) (n int) {
+		t.Helper()
+		if err := sqlDB.QueryRow(`SELECT COUNT(*) FROM items`).Scan(&n); err != nil {
+			t.Fatalf("COUNT: %v", err)
+		}
+		return n
+	}
+	hits := func(t *testing.T, id string) (n int) {
+		t.Helper()
+		if err := sqlDB.QueryRow(`SELECT Hits FROM items WHERE ID = ?`, id).Scan(&n); err != nil {
+			t.Fatalf("SELECT: %v", err)
+		}
+		return n
+	}
+
+	t.Run("Insert", func(t *testing.T) {
+		ctx := WithIdempotencyKey(ctx, "create-1")
+		var ids []any
+		for range 2 {
+			r := dalrecord.NewRecordWithData(dalrecord.NewIncompleteKey("items", reflect.String, nil), &item{Name: "one"})
+			if err := db.Insert(ctx, r, dal.WithRandomStringKey(10, 5)); err != nil {
+				t.Fatalf("Insert: %v", err)
+			}
+			ids = append(ids, r.Key().ID)
+		}
+		if n := count(t); n != 1 {
+			t.Errorf("%d rows, want the repeated insert not to be executed", n)
+		}
+		if ids[0] == nil || ids[0] != ids[1] {
+			t.Errorf("IDs %v, want the repeated insert to get the generated ID", ids)
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		if err := db.Insert(ctx, dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("items", "i1"), &item{Name: "i1"})); err != nil {
+			t.Fatalf("Insert: %v", err)
+		}
+		key := dalrecord.NewKeyWithID("items", "i1")
+		hit := []update.Update{update.ByFieldName("Hits", Increment(1))}
+		for range 2 {
+			if err := db.Update(WithIdempotencyKey(ctx, "hit-1"), key, hit); err != nil {
+				t.Fatalf("Update: %v", err)
+			}
+		}
+		if n := hits(t, "i1"); n != 1 {
+			t.Errorf("Hits = %d, want the repeated update not to be executed", n)
+		}
+		if err := db.Update(WithIdempotencyKey(ctx, "hit-2"), key, hit); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		if n := hits(t, "i1"); n != 2 {
+			t.Errorf("Hits = %d, want an update with another key to be executed", n)
+		}
+		if err := db.Delete(WithIdempotencyKey(ctx, "hit-1"), key); !errors.Is(err, ErrIdempotencyKeyReused) {
+			t.Errorf("Delete with the key of an update = %v, want ErrIdempotencyKeyReused", err)
+		}
+	})
+
+	t.Run("UpdateWhere", func(t *testing.T) {
+		ctx := WithIdempotencyKey(ctx, "rename-all")
+		everything := dal.Comparison{Operator: dal.GreaterThen, Left: dal.Field("Name"), Right: dal.Constant{Value: ""}}
+		for i := range 2 {
+			n, err := db.UpdateWhere(ctx, "items", everything, []update.Update{update.ByFieldName("Hits", Increment(10))})
+			if err != nil || n != 2 {
+				t.Errorf("UpdateWhere #%d = %d, %v; want the 2 rows of the first call", i+1, n, err)
+			}
+		}
+		if n := hits(t, "i1"); n != 12 {
+			t.Errorf("Hits = %d, want 12", n)
+		}
+	})
+
+	t.Run("reused_for_another_write", func(t *testing.T) {
+		ctx := WithIdempotencyKey(ctx, "rename-i1")
+		rename := func(name string) []update.Update {
+			return []update.Update{update.ByFieldName("Name", name)}
+		}
+		if err := db.Update(ctx, dalrecord.NewKeyWithID("items", "i1"), rename("renamed")); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		if err := db.Update(ctx, dalrecord.NewKeyWithID("items", "i2"), rename("renamed")); !errors.Is(err, ErrIdempotencyKeyReused) {
+			t.Errorf("Update of another record = %v, want ErrIdempotencyKeyReused", err)
+		}
+		if err := db.Update(ctx, dalrecord.NewKeyWithID("items", "i1"), rename("other")); !errors.Is(err, ErrIdempotencyKeyReused) {
+			t.Errorf("Update with other values = %v, want ErrIdempotencyKeyReused", err)
+		}
+		ctx = WithIdempotencyKey(ctx, "create-i3")
+		if err := db.Insert(ctx, dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("items", "i3"), &item{Name: "i3"})); err != nil {
+			t.Fatalf("Insert: %v", err)
+		}
+		if err := db.Insert(ctx, dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("items", "i4"), &item{Name: "i4"})); !errors.Is(err, ErrIdempotencyKeyReused) {
+			t.Errorf("Insert of another record = %v, want ErrIdempotencyKeyReused", err)
+		}
+		ctx = WithIdempotencyKey(ctx, "rename-all")
+		nothing := dal.Comparison{Operator: dal.Equal, Left: dal.Field("Name"), Right: dal.Constant{Value: ""}}
+		if _, err := db.UpdateWhere(ctx, "items", nothing, []update.Update{update.ByFieldName("Hits", Increment(10))}); !errors.Is(err, ErrIdempotencyKeyReused) {
+			t.Errorf("UpdateWhere of another condition = %v, want ErrIdempotencyKeyReused", err)
+		}
+		if err := db.Delete(context.Background(), dalrecord.NewKeyWithID("items", "i3")); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+	})
+
+	t.Run("canonical_request", func(t *testing.T) {
+		ctx := WithIdempotencyKey(ctx, "seen-i1")
+		key := dalrecord.NewKeyWithID("items", "i1")
+		seenAt := time.Now() // with a monotonic clock reading
+		name, sameName := "seen", "seen"
+		for _, u := range [][]update.Update{
+			{update.ByFieldName("SeenAt", seenAt), update.ByFieldName("Name", &name)},
+			{update.ByFieldName("SeenAt", seenAt.Round(0).In(time.FixedZone("X", 3600))), update.ByFieldName("Name", &sameName)},
+		} {
+			if err := db.Update(ctx, key, u); err != nil {
+				t.Errorf("Update with the same values = %v, want it replayed", err)
+			}
+		}
+		ctx = WithIdempotencyKey(ctx, "create-i5")
+		if err := db.Insert(ctx, dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("items", "i5"), &item{Name: "i5"})); err != nil {
+			t.Fatalf("Insert: %v", err)
+		}
+		if err := db.Insert(ctx, dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("items", "i5"), &item{Name: "other"})); !errors.Is(err, ErrIdempotencyKeyReused) {
+			t.Errorf("Insert of other data = %v, want ErrIdempotencyKeyReused", err)
+		}
+		if err := db.Delete(context.Background(), dalrecord.NewKeyWithID("items", "i5")); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+	})
+
+	t.Run("failed_write", func(t *testing.T) {
+		ctx := WithIdempotencyKey(ctx, "duplicate")
+		r := dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("items", "i1"), &item{Name: "duplicate"})
+		if err := db.Insert(ctx, r); err == nil {
+			t.Fatal("expected the insert of an existing key to fail")
+		}
+		if err := db.Delete(context.Background(), dalrecord.NewKeyWithID("items", "i1")); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		if err := db.Insert(ctx, r); err != nil {
+			t.Fatalf("Insert repeated after a failure: %v", err)
+		}
+		if n := hits(t, "i1"); n != 0 {
+			t.Errorf("Hits = %d, want the repeated insert to be executed", n)
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		key := dalrecord.NewKeyWithID("items", "i1")
+		hit := []update.Update{update.ByFieldName("Hits", Increment(1))}
+		ctx := WithIdempotencyKey(ctx, "hit-later")
+		if err := db.Update(ctx, key, hit); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		now = now.Add(time.Hour)
+		if err := db.Update(ctx, key, hit); err != nil {
+			t.Fatalf("Update with an expired key: %v", err)
+		}
+		if n := hits(t, "i1"); n != 2 {
+			t.Errorf("Hits = %d, want the update to be executed again once its key expired", n)
+		}
+	})
+
+	t.Run("transaction", func(t *testing.T) {
+		key := dalrecord.NewKeyWithID("items", "i1")
+		hit := []update.Update{update.ByFieldName("Hits", Increment(1))}
+		ctx := WithIdempotencyKey(ctx, "hit-in-tx")
+		_ = db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx dal.ReadwriteTransaction) error {
+			if err := tx.Update(ctx, key, hit); err != nil {
+				return err
+			}
+			return context.Canceled
+		})
+		err := db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx dal.ReadwriteTransaction) error {
+			return tx.Update(ctx, key, hit)
+		})
+		if err != nil {
+			t.Fatalf("RunReadwriteTransaction: %v", err)
+		}
+		if n := hits(t, "i1"); n != 3 {
+			t.Errorf("Hits = %d, want the key of a rolled back transaction to be forgotten", n)
+		}
+	})
+}
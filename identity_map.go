@@ -0,0 +1,232 @@
+package dalgo2sql
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/dal-go/dalgo/dal"
+	"github.com/dal-go/record"
+)
+
+type identityMapContextKey struct{}
+
+// WithIdentityMap returns a context that makes RunReadwriteTransaction keep
+// an identity map of the records its transaction reads by key: Get and
+// GetMulti of a key read before return the row read the first time, or
+// report the record as not found again, without querying the database.
+// Each read decodes the row into the data of its record, so records never
+// share data and changing one does not change the rows returned later.
+//
+// Writes of the transaction forget the rows of the keys they write, and
+// UpdateWhere and DeleteWhere the rows of their recordset, so the next read
+// queries the database. Reads are served from the identity map only if it
+// holds every column they select and the row was read with the row lock
+// they ask for, or with LockForUpdate. Reads WithoutFilters always query
+// the database and are not remembered.
+func WithIdentityMap(ctx context.Context) context.Context {
+	return context.WithValue(ctx, identityMapContextKey{}, true)
+}
+
+func identityMapped(ctx context.Context) bool {
+	mapped, _ := ctx.Value(identityMapContextKey{}).(bool)
+	return mapped
+}
+
+// rowKey identifies the row of a key in the tenant of the context.
+func rowKey(ctx context.Context, key *record.Key) string {
+	tenantID, _ := TenantFromContext(ctx)
+	return tenantID + "\x00" + key.String()
+}
+
+// identityRow is a row read by key, or its absence if cols is nil.
+type identityRow struct {
+	collection string
+	// all tells whether the row was read with SELECT *, otherwise it
+	// holds the columns of the struct it was read for.
+	all     bool
+	lock    LockStrength
+	cols    []string
+	dbTypes []string
+	values  []any
+}
+
+// identityMap holds the rows read by key in a transaction.
+type identityMap struct {
+	options DbOptions
+	mu      sync.Mutex
+	rows    map[string]*identityRow
+}
+
+func newIdentityMap(options DbOptions) *identityMap {
+	return &identityMap{options: options, rows: make(map[string]*identityRow)}
+}
+
+type identityReadsContextKey struct{}
+
+// reading returns a context that makes Get and GetMulti remember the rows
+// they read in the identity map, unless the reads can't be served by it.
+func (m *identityMap) reading(ctx context.Context) context.Context {
+	if m == nil || !m.serves(ctx) {
+		return ctx
+	}
+	return context.WithValue(ctx, identityReadsContextKey{}, m)
+}
+
+// serves reports whether reads made with the context can be served by the identity map.
+func (m *identityMap) serves(ctx context.Context) bool {
+	return m != nil && ctx.Value(withoutFiltersContextKey{}) == nil
+}
+
+// rememberer returns the function Get and GetMulti pass the rows they read
+// with the context to, or nil if the context is not reading into an identity map.
+func rememberer(ctx context.Context, all bool) func(key *record.Key, cols, dbTypes []string, values []any) {
+	m, _ := ctx.Value(identityReadsContextKey{}).(*identityMap)
+	if m == nil {
+		return nil
+	}
+	lock := rowLockFromContext(ctx)
+	return func(key *record.Key, cols, dbTypes []string, values []any) {
+		if cols == nil && lock.Wait == LockSkipLocked {
+			return // the row may exist but be locked
+		}
+		row := &identityRow{collection: getRecordsetName(key), all: all, lock: lock.Strength, cols: cols, dbTypes: dbTypes, values: cloneRowValues(values)}
+		id := rowKey(ctx, key)
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if old := m.rows[id]; old != nil && (row.lock == LockNone || old.lock == LockForUpdate) {
+			row.lock = old.lock // rows stay locked until the end of the transaction
+		}
+		m.rows[id] = row
+	}
+}
+
+// load reads the record from the identity map and reports whether it could.
+func (m *identityMap) load(ctx context.Context, r record.Record) (bool, error) {
+	if !m.serves(ctx) {
+		return false, nil
+	}
+	key := r.Key()
+	m.mu.Lock()
+	row := m.rows[rowKey(ctx, key)]
+	m.mu.Unlock()
+	if row == nil || !row.serves(rowLockFromContext(ctx), r) {
+		return false, nil
+	}
+	collection := getRecordsetName(key)
+	if row.cols == nil {
+		m.options.count(ctx, collection, CounterMisses, 1)
+		err := dal.NewErrNotFoundByKey(key, nil)
+		r.SetError(err)
+		return true, err
+	}
+	r.SetError(nil)
+	if err := assignRowToRecord(newColumnMapper(m.options, collection), row.cols, row.dbTypes, cloneRowValues(row.values), r); err != nil {
+		return true, err
+	}
+	m.options.count(ctx, collection, CounterGets, 1)
+	return true, nil
+}
+
+// loadMulti reads the records from the identity map and returns the records it could not read.
+func (m *identityMap) loadMulti(ctx context.Context, records []record.Record) (unread []record.Record, err error) {
+	if !m.serves(ctx) {
+		return records, nil
+	}
+	for _, r := range records {
+		loaded, err := m.load(ctx, r)
+		if !loaded {
+			unread = append(unread, r)
+		} else if err != nil && !record.IsNotFound(err) {
+			return nil, err
+		}
+	}
+	return unread, nil
+}
+
+// serves reports whether the row can be read for the record with the lock.
+func (row *identityRow) serves(lock RowLock, r record.Record) bool {
+	if lock.Strength != LockNone && row.lock != LockForUpdate && row.lock != lock.Strength {
+		return false
+	}
+	if row.cols == nil || row.all {
+		return true
+	}
+	data := r.Data()
+	if data == nil || isMapData(data) {
+		return false
+	}
+	for _, field := range getSelectFields(false, DbOptions{}, r) {
+		if !slices.ContainsFunc(row.cols, func(col string) bool { return strings.EqualFold(col, field) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// forget removes the rows written by the change from the identity map.
+func (m *identityMap) forget(ctx context.Context, c change) {
+	if m == nil {
+		return
+	}
+	if !c.where {
+		keys, _ := c.keys(ctx, m.options, nil)
+		m.forgetKeys(ctx, keys...)
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, row := range m.rows {
+		if slices.Contains(c.recordsets, row.collection) {
+			delete(m.rows, id)
+		}
+	}
+}
+
+func (m *identityMap) forgetKeys(ctx context.Context, keys ...*record.Key) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, key := range keys {
+		delete(m.rows, rowKey(ctx, key))
+	}
+}
+
+// scanRememberedRow scans the current row into the record and passes it to remember.
+func scanRememberedRow(rows *sql.Rows, r record.Record, mapper columnMapper, remember func(key *record.Key, cols, dbTypes []string, values []any)) error {
+	r.SetError(nil)
+	cols, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	dbTypes, err := mapper.columnTypeNames(rows, len(cols))
+	if err != nil {
+		return err
+	}
+	values, err := scanRowValues(rows, len(cols))
+	if err != nil {
+		return err
+	}
+	remember(r.Key(), cols, dbTypes, values)
+	return assignRowToRecord(mapper, cols, dbTypes, values, r)
+}
+
+// cloneRowValues copies scanned values, including the bytes of []byte values
+// that would otherwise be shared by the records the row is assigned to.
+func cloneRowValues(values []any) []any {
+	if values == nil {
+		return nil
+	}
+	cloned := make([]any, len(values))
+	for i, v := range values {
+		if b, ok := v.([]byte); ok {
+			v = slices.Clone(b)
+		}
+		cloned[i] = v
+	}
+	return cloned
+}
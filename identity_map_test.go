@@ -0,0 +1,210 @@
+package dalgo2sql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dal-go/dalgo/dal"
+	dalrecord "github.com/dal-go/record"
+	"github.com/dal-go/record/update"
+)
+
+func TestIdentityMap_queries(t *testing.T) {
+	type item struct {
+		Name string
+	}
+	get := func(ctx context.Context, tx dal.ReadwriteTransaction, id string) (item, error) {
+		var data item
+		err := tx.Get(ctx, dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("items", id), &data))
+		return data, err
+	}
+	ctx := WithIdentityMap(context.Background())
+
+	t.Run("repeated_gets", func(t *testing.T) {
+		sqlDB, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer closeDatabase(t, sqlDB)
+		db := NewDatabase(sqlDB, newSchema(), DbOptions{})
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT Name FROM items WHERE ID = \?`).WithArgs("a").
+			WillReturnRows(sqlmock.NewRows([]string{"Name"}).AddRow("A"))
+		mock.ExpectQuery(`SELECT Name FROM items WHERE ID = \?`).WithArgs("missing").
+			WillReturnRows(sqlmock.NewRows([]string{"Name"}))
+		mock.ExpectQuery(`SELECT ID, Name FROM items WHERE ID IN \(\?, \?\)`).WithArgs("b", "c").
+			WillReturnRows(sqlmock.NewRows([]string{"ID", "Name"}).AddRow("b", "B"))
+		mock.ExpectCommit()
+		err = db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx dal.ReadwriteTransaction) error {
+			for range 2 {
+				got, err := get(ctx, tx, "a")
+				if err != nil {
+					return err
+				}
+				if got.Name != "A" {
+					t.Errorf("Name = %q, want A", got.Name)
+				}
+				if _, err = get(ctx, tx, "missing"); !dalrecord.IsNotFound(err) {
+					t.Errorf("Get of a missing record = %v, want not found", err)
+				}
+			}
+			var a, b, c item
+			records := []dalrecord.Record{
+				dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("items", "a"), &a),
+				dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("items", "b"), &b),
+				dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("items", "c"), &c),
+			}
+			for range 2 {
+				if err := tx.GetMulti(ctx, records); err != nil {
+					return err
+				}
+			}
+			if a.Name != "A" || b.Name != "B" || records[2].Exists() {
+				t.Errorf("GetMulti = %+v, %+v and c exists=%v; want A, B and c missing", a, b, records[2].Exists())
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("RunReadwriteTransaction: %v", err)
+		}
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("writes_forget_rows", func(t *testing.T) {
+		sqlDB, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer closeDatabase(t, sqlDB)
+		db := NewDatabase(sqlDB, newSchema(), DbOptions{})
+		mock.ExpectBegin()
+		for _, id := range []string{"a", "b"} {
+			mock.ExpectQuery(`SELECT Name FROM items WHERE ID = \?`).WithArgs(id).
+				WillReturnRows(sqlmock.NewRows([]string{"Name"}).AddRow(id))
+		}
+		mock.ExpectExec(`UPDATE items SET\s+Name = \?\s+WHERE ID = \?`).WithArgs("A2", "a").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(`SELECT Name FROM items WHERE ID = \?`).WithArgs("a").
+			WillReturnRows(sqlmock.NewRows([]string{"Name"}).AddRow("A2"))
+		mock.ExpectExec(`DELETE FROM items WHERE Name = \?`).WithArgs("x").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(`SELECT Name FROM items WHERE ID = \?`).WithArgs("b").
+			WillReturnRows(sqlmock.NewRows([]string{"Name"}).AddRow("b"))
+		mock.ExpectCommit()
+		err = db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx dal.ReadwriteTransaction) error {
+			for _, id := range []string{"a", "b", "b"} {
+				if _, err := get(ctx, tx, id); err != nil {
+					return err
+				}
+			}
+			if err := tx.Update(ctx, dalrecord.NewKeyWithID("items", "a"), []update.Update{update.ByFieldName("Name", "A2")}); err != nil {
+				return err
+			}
+			if got, err := get(ctx, tx, "a"); err != nil || got.Name != "A2" {
+				t.Errorf("Get after Update = %+v, %v; want the updated row", got, err)
+			}
+			if _, err := get(ctx, tx, "b"); err != nil {
+				return err
+			}
+			where := dal.Comparison{Operator: dal.Equal, Left: dal.Field("Name"), Right: dal.Constant{Value: "x"}}
+			if _, err := tx.(SetMutator).DeleteWhere(ctx, "items", where); err != nil {
+				return err
+			}
+			_, err := get(ctx, tx, "b")
+			return err
+		})
+		if err != nil {
+			t.Fatalf("RunReadwriteTransaction: %v", err)
+		}
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("not_mapped_by_default", func(t *testing.T) {
+		sqlDB, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer closeDatabase(t, sqlDB)
+		db := NewDatabase(sqlDB, newSchema(), DbOptions{})
+		mock.ExpectBegin()
+		for range 2 {
+			mock.ExpectQuery(`SELECT Name FROM items WHERE ID = \?`).WithArgs("a").
+				WillReturnRows(sqlmock.NewRows([]string{"Name"}).AddRow("A"))
+		}
+		mock.ExpectCommit()
+		err = db.RunReadwriteTransaction(context.Background(), func(ctx context.Context, tx dal.ReadwriteTransaction) error {
+			for range 2 {
+				if _, err := get(ctx, tx, "a"); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("RunReadwriteTransaction: %v", err)
+		}
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestIdentityMap_SQLite(t *testing.T) {
+	type item struct {
+		Name string
+		Tags []byte
+	}
+	type name struct {
+		Name string
+	}
+	sqlDB := openTestSQLiteDB(t, `
+		CREATE TABLE items (ID TEXT PRIMARY KEY, Name TEXT, Tags BLOB);
+		INSERT INTO items VALUES ('i1', 'one', X'0102');`)
+	db := NewDatabase(sqlDB, newSchema(), DbOptions{})
+	key := dalrecord.NewKeyWithID("items", "i1")
+
+	err := db.RunReadwriteTransaction(WithIdentityMap(context.Background()), func(ctx context.Context, tx dal.ReadwriteTransaction) error {
+		var first item
+		if err := tx.Get(ctx, dalrecord.NewRecordWithData(key, &first)); err != nil {
+			return err
+		}
+		first.Name, first.Tags[0] = "changed", 9
+
+		var second item
+		if err := tx.Get(ctx, dalrecord.NewRecordWithData(key, &second)); err != nil {
+			return err
+		}
+		if second.Name != "one" || second.Tags[0] != 1 {
+			t.Errorf("got %+v, want a copy not changed by changes of the first record", second)
+		}
+
+		var partial name
+		if err := tx.Get(ctx, dalrecord.NewRecordWithData(key, &partial)); err != nil || partial.Name != "one" {
+			t.Errorf("Get of fewer columns = %+v, %v", partial, err)
+		}
+		m := map[string]any{}
+		if err := tx.Get(ctx, dalrecord.NewRecordWithData(key, m)); err != nil || m["Name"] != "one" {
+			t.Errorf("Get into a map = %v, %v; want every column read", m, err)
+		}
+
+		if err := tx.Set(ctx, dalrecord.NewRecordWithData(key, &item{Name: "set", Tags: []byte{3}})); err != nil {
+			return err
+		}
+		var afterSet item
+		if err := tx.Get(ctx, dalrecord.NewRecordWithData(key, &afterSet)); err != nil {
+			return err
+		}
+		if afterSet.Name != "set" {
+			t.Errorf("got %+v after Set, want the written row", afterSet)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("RunReadwriteTransaction: %v", err)
+	}
+}
@@ -12,11 +12,20 @@ import (
 const maxIDGenerationAttempts = 10
 
 func (dtb *database) Insert(ctx context.Context, record dalrecord.Record, opts ...dal.InsertOption) error {
-	return insertSingle(ctx, dtb.options, record, dtb.db.ExecContext, dtb.db.Query, opts...)
+	ctx = withOperation(ctx, OpInsert)
+	return dtb.write(ctx, recordsChange(true, record), func(query queryExecutor, exec statementExecutor) error {
+		return insertSingle(ctx, dtb.options, record, exec, query, opts...)
+	})
 }
 
 func (t transaction) Insert(ctx context.Context, record dalrecord.Record, opts ...dal.InsertOption) error {
-	return insertSingle(ctx, t.sqlOptions, record, t.tx.ExecContext, t.tx.Query, opts...)
+	ctx = withOperation(ctx, OpInsert)
+	if len(opts) == 0 && t.buffer.accepts(ctx, record.Key()) {
+		return t.buffer.add(ctx, bufferedInsert, record.Key(), record, nil)
+	}
+	return t.write(ctx, recordsChange(true, record), func(query queryExecutor, exec statementExecutor) error {
+		return insertSingle(ctx, t.sqlOptions, record, exec, query, opts...)
+	})
 }
 
 // insertSingle inserts a single record honoring dal.InsertOptions:
@@ -42,6 +51,7 @@ func insertSingle(ctx context.Context, options DbOptions, record dalrecord.Recor
 				if !exists {
 					return dal.NewErrNotFoundByKey(key, nil)
 				}
+				options.count(ctx, key.Collection(), CounterRetries, 1)
 				return nil
 			},
 			func(r dalrecord.Record) error {
@@ -53,19 +63,52 @@ func insertSingle(ctx context.Context, options DbOptions, record dalrecord.Recor
 }
 
 func execInsert(ctx context.Context, options DbOptions, record dalrecord.Record, exec statementExecutor) error {
-	q := buildSingleRecordQuery(insertOperation, options, record)
-	if _, err := exec(ctx, q.text, q.args...); err != nil {
+	collection := record.Key().Collection()
+	scope, err := options.scope(ctx, getRecordsetName(record.Key()))
+	if err != nil {
+		return err
+	}
+	if err = deleteExpiredOnInsert(ctx, options, record, exec); err != nil {
 		return err
 	}
+	if restored, err := restoreOnInsert(ctx, options, record, exec); err != nil || restored {
+		if restored && err == nil {
+			options.count(ctx, collection, CounterInserts, 1)
+		}
+		return err
+	}
+	q, err := buildScopedRecordQuery(insertOperation, options, scope, record)
+	if err != nil {
+		return err
+	}
+	if _, err = options.traceStatement(collection, exec)(ctx, q.text, q.args...); err != nil {
+		if isUniqueViolation(err) {
+			options.count(ctx, collection, CounterConflicts, 1)
+		}
+		return err
+	}
+	options.count(ctx, collection, CounterInserts, 1)
+	setRecordVersion(record, options.versionColumn(getRecordsetName(record.Key())), 1)
 	return nil
 }
 
 // InsertMulti inserts multiple records in a single transaction at once. TODO: Implement batched multi-insertOperation
 func (t transaction) InsertMulti(ctx context.Context, records []dalrecord.Record, opts ...dal.InsertOption) error {
-	for _, record := range records {
-		if err := insertSingle(ctx, t.sqlOptions, record, t.tx.ExecContext, t.tx.Query, opts...); err != nil {
-			return err
+	ctx = withOperation(ctx, OpInsertMulti)
+	if len(opts) == 0 && t.buffer.accepts(ctx, recordKeys(records)...) {
+		for _, record := range records {
+			if err := t.buffer.add(ctx, bufferedInsert, record.Key(), record, nil); err != nil {
+				return err
+			}
 		}
+		return nil
 	}
-	return nil
+	return t.write(ctx, recordsChange(true, records...), func(query queryExecutor, exec statementExecutor) error {
+		for _, record := range records {
+			if err := insertSingle(ctx, t.sqlOptions, record, exec, query, opts...); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
@@ -0,0 +1,102 @@
+package dalgo2sql
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dal-go/dalgo/dal"
+)
+
+// LockStrength is the kind of row lock taken by Get and GetMulti.
+type LockStrength int
+
+const (
+	// LockNone reads rows without locking them (default).
+	LockNone LockStrength = iota
+	// LockForUpdate locks the rows read against concurrent updates and locks
+	// (SELECT ... FOR UPDATE). Use it for read-modify-write patterns.
+	LockForUpdate
+	// LockForShare prevents concurrent updates of the rows read while allowing
+	// other transactions to share the lock (SELECT ... FOR SHARE).
+	LockForShare
+)
+
+// LockWait tells what to do when a row is already locked by another transaction.
+type LockWait int
+
+const (
+	// LockWaitDefault waits for the lock to be released (default).
+	LockWaitDefault LockWait = iota
+	// LockNoWait fails immediately if a row is locked (NOWAIT).
+	LockNoWait
+	// LockSkipLocked skips locked rows (SKIP LOCKED): Get reports them as not found.
+	LockSkipLocked
+)
+
+// RowLock describes the row lock taken by Get and GetMulti.
+type RowLock struct {
+	Strength LockStrength
+	Wait     LockWait
+}
+
+type rowLockContextKey struct{}
+
+// WithRowLock returns a context that makes Get and GetMulti lock the rows
+// they read until the end of the transaction. Pass it to a single call to
+// lock the rows of that call only, or to RunReadwriteTransaction to make it
+// the default for every read of the transaction.
+//
+// Locks are held until the transaction ends, so outside a transaction they
+// are released as soon as the row has been read. Row locking requires
+// DbOptions.Dialect to be DialectPostgres or DialectMySQL (8.0+); other
+// dialects report it as not supported. SQLite has no row locks: it locks the
+// whole database for writing instead, which a read-write transaction
+// acquires on its first write.
+func WithRowLock(ctx context.Context, lock RowLock) context.Context {
+	return context.WithValue(ctx, rowLockContextKey{}, lock)
+}
+
+// ForUpdate is a shortcut for WithRowLock(ctx, RowLock{Strength: LockForUpdate}).
+func ForUpdate(ctx context.Context) context.Context {
+	return WithRowLock(ctx, RowLock{Strength: LockForUpdate})
+}
+
+func rowLockFromContext(ctx context.Context) RowLock {
+	if ctx == nil {
+		return RowLock{}
+	}
+	lock, _ := ctx.Value(rowLockContextKey{}).(RowLock)
+	return lock
+}
+
+// rowLockClause returns the clause appended to a SELECT statement to lock the
+// rows it reads, prefixed with a space, or an empty string for LockNone.
+func (d Dialect) rowLockClause(lock RowLock) (string, error) {
+	if lock.Strength == LockNone {
+		return "", nil
+	}
+	switch d {
+	case DialectPostgres, DialectMySQL:
+	default:
+		return "", fmt.Errorf("%w: row locking with %s dialect", dal.ErrNotSupported, d)
+	}
+	var clause string
+	switch lock.Strength {
+	case LockForUpdate:
+		clause = " FOR UPDATE"
+	case LockForShare:
+		clause = " FOR SHARE"
+	default:
+		return "", fmt.Errorf("unknown lock strength: %d", lock.Strength)
+	}
+	switch lock.Wait {
+	case LockWaitDefault:
+	case LockNoWait:
+		clause += " NOWAIT"
+	case LockSkipLocked:
+		clause += " SKIP LOCKED"
+	default:
+		return "", fmt.Errorf("unknown lock wait policy: %d", lock.Wait)
+	}
+	return clause, nil
+}
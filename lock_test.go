@@ -0,0 +1,154 @@
+package dalgo2sql
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dal-go/dalgo/dal"
+	dalrecord "github.com/dal-go/record"
+)
+
+func TestDialect_rowLockClause(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect Dialect
+		lock    RowLock
+		want    string
+		wantErr error
+	}{
+		{name: "none", dialect: DialectSQLite, lock: RowLock{}, want: ""},
+		{name: "for_update", dialect: DialectPostgres, lock: RowLock{Strength: LockForUpdate}, want: " FOR UPDATE"},
+		{name: "for_share", dialect: DialectMySQL, lock: RowLock{Strength: LockForShare}, want: " FOR SHARE"},
+		{name: "nowait", dialect: DialectPostgres, lock: RowLock{Strength: LockForUpdate, Wait: LockNoWait}, want: " FOR UPDATE NOWAIT"},
+		{name: "skip_locked", dialect: DialectMySQL, lock: RowLock{Strength: LockForShare, Wait: LockSkipLocked}, want: " FOR SHARE SKIP LOCKED"},
+		{name: "sqlite", dialect: DialectSQLite, lock: RowLock{Strength: LockForUpdate}, wantErr: dal.ErrNotSupported},
+		{name: "generic", dialect: DialectGeneric, lock: RowLock{Strength: LockForShare}, wantErr: dal.ErrNotSupported},
+		{name: "unknown_strength", dialect: DialectPostgres, lock: RowLock{Strength: 9}, wantErr: errAny},
+		{name: "unknown_wait", dialect: DialectPostgres, lock: RowLock{Strength: LockForUpdate, Wait: 9}, wantErr: errAny},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.dialect.rowLockClause(tt.lock)
+			switch {
+			case tt.wantErr == nil && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tt.wantErr == errAny && err == nil, tt.wantErr != nil && tt.wantErr != errAny && !errors.Is(err, tt.wantErr):
+				t.Fatalf("expected %v, got %v", tt.wantErr, err)
+			}
+			if got != tt.want {
+				t.Errorf("rowLockClause() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// errAny is used in tests that expect an error without checking its type.
+var errAny = errors.New("any error")
+
+func TestRowLockFromContext(t *testing.T) {
+	ctx := context.Background()
+	if lock := rowLockFromContext(ctx); lock != (RowLock{}) {
+		t.Errorf("expected no lock, got %+v", lock)
+	}
+	if lock := rowLockFromContext(ForUpdate(ctx)); lock != (RowLock{Strength: LockForUpdate}) {
+		t.Errorf("ForUpdate: got %+v", lock)
+	}
+	want := RowLock{Strength: LockForShare, Wait: LockNoWait}
+	if lock := rowLockFromContext(WithRowLock(ForUpdate(ctx), want)); lock != want {
+		t.Errorf("WithRowLock: got %+v, want %+v", lock, want)
+	}
+}
+
+func TestGet_RowLock(t *testing.T) {
+	type user struct {
+		Name string
+	}
+	newDB := func(t *testing.T, dialect Dialect) (dal.DB, sqlmock.Sqlmock) {
+		sqlDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { closeDatabase(t, sqlDB) })
+		return NewDatabase(sqlDB, newSchema(), DbOptions{
+			Dialect:     dialect,
+			Placeholder: PlaceholderDollar,
+			Recordsets: map[string]*Recordset{
+				"users": NewRecordset("users", Table, []dal.FieldRef{dal.Field("id")}),
+			},
+		}), mock
+	}
+
+	t.Run("Get_for_update", func(t *testing.T) {
+		db, mock := newDB(t, DialectPostgres)
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT Name FROM users WHERE id = $1 FOR UPDATE").WithArgs("u1").
+			WillReturnRows(sqlmock.NewRows([]string{"Name"}).AddRow("John"))
+		mock.ExpectCommit()
+		err := db.RunReadwriteTransaction(context.Background(), func(ctx context.Context, tx dal.ReadwriteTransaction) error {
+			var u user
+			r := dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("users", "u1"), &u)
+			if err := tx.Get(ForUpdate(ctx), r); err != nil {
+				return err
+			}
+			if u.Name != "John" {
+				t.Errorf("Name = %q, want John", u.Name)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("transaction_default", func(t *testing.T) {
+		db, mock := newDB(t, DialectMySQL)
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT id, Name FROM users WHERE id IN ($1, $2) FOR SHARE SKIP LOCKED").WithArgs("u1", "u2").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "Name"}).AddRow("u2", "Jane"))
+		mock.ExpectCommit()
+		ctx := WithRowLock(context.Background(), RowLock{Strength: LockForShare, Wait: LockSkipLocked})
+		err := db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx dal.ReadwriteTransaction) error {
+			records := []dalrecord.Record{
+				dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("users", "u1"), &user{}),
+				dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("users", "u2"), &user{}),
+			}
+			if err := tx.GetMulti(ctx, records); err != nil {
+				return err
+			}
+			if records[0].Exists() || !records[1].Exists() {
+				t.Error("expected the skipped row to be reported as not found")
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("sqlite_not_supported", func(t *testing.T) {
+		db, _ := newDB(t, DialectSQLite)
+		ctx := ForUpdate(context.Background())
+		err := db.Get(ctx, dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("users", "u1"), &user{}))
+		if !errors.Is(err, dal.ErrNotSupported) {
+			t.Errorf("Get: expected ErrNotSupported, got %v", err)
+		}
+		records := []dalrecord.Record{
+			dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("users", "u1"), &user{}),
+			dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("users", "u2"), &user{}),
+		}
+		if err = db.GetMulti(ctx, records); !errors.Is(err, dal.ErrNotSupported) {
+			t.Errorf("GetMulti: expected ErrNotSupported, got %v", err)
+		}
+		if !errors.Is(records[0].Error(), dal.ErrNotSupported) {
+			t.Errorf("expected the record error to be ErrNotSupported, got %v", records[0].Error())
+		}
+	})
+}
@@ -0,0 +1,256 @@
+package dalgo2sql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"slices"
+	"sync"
+	"time"
+)
+
+// OpTransaction is the operation metrics of transactions are reported under.
+const OpTransaction Operation = "Transaction"
+
+// Counter names a counter reported to Metrics.
+type Counter string
+
+const (
+	// CounterGets counts records found by Get and GetMulti.
+	CounterGets Counter = "gets"
+	// CounterMisses counts records not found by Get and GetMulti.
+	CounterMisses Counter = "misses"
+	// CounterInserts counts inserted records, including records inserted by Set.
+	CounterInserts Counter = "inserts"
+	// CounterUpdates counts updated rows, including records updated by Set.
+	CounterUpdates Counter = "updates"
+	// CounterDeletes counts deleted rows.
+	CounterDeletes Counter = "deletes"
+	// CounterConflicts counts writes rejected because of a unique key conflict.
+	CounterConflicts Counter = "conflicts"
+	// CounterRetries counts inserts retried with a new ID because the
+	// generated one was already taken.
+	CounterRetries Counter = "retries"
+	// CounterErrors counts failed statements.
+	CounterErrors Counter = "errors"
+	// CounterCommits counts committed transactions.
+	CounterCommits Counter = "commits"
+	// CounterRollbacks counts rolled back transactions.
+	CounterRollbacks Counter = "rollbacks"
+)
+
+// Metrics receives counters and latencies per operation and recordset from
+// all CRUD, query and transaction paths. See NewInMemoryMetrics.
+// Implementations must be safe for concurrent use.
+type Metrics interface {
+	// AddCount adds delta to a counter of an operation on a recordset.
+	// The recordset is empty for transactions and text queries.
+	AddCount(op Operation, recordset string, counter Counter, delta int64)
+	// ObserveLatency records the time a statement executed for an operation
+	// on a recordset took, or the duration of a transaction for OpTransaction.
+	ObserveLatency(op Operation, recordset string, d time.Duration)
+	// RegisterPool is called by NewDatabase with a function returning the
+	// statistics of the database connection pool (sql.DB.Stats).
+	RegisterPool(databaseID string, stats func() sql.DBStats)
+}
+
+// count adds delta to a counter of the operation recorded in the context.
+func (o DbOptions) count(ctx context.Context, recordset string, counter Counter, delta int64) {
+	if o.Metrics != nil && delta != 0 {
+		o.Metrics.AddCount(operationFromContext(ctx), recordset, counter, delta)
+	}
+}
+
+// observeTransaction reports the outcome and duration of a transaction.
+func (o DbOptions) observeTransaction(started time.Time, committed bool) {
+	if o.Metrics == nil {
+		return
+	}
+	counter := CounterRollbacks
+	if committed {
+		counter = CounterCommits
+	}
+	o.Metrics.AddCount(OpTransaction, "", counter, 1)
+	o.Metrics.ObserveLatency(OpTransaction, "", time.Since(started))
+}
+
+// DefaultLatencyBuckets are the upper bounds of the latency histogram
+// buckets used by NewInMemoryMetrics when none are given.
+var DefaultLatencyBuckets = []time.Duration{
+	100 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+// MetricsKey identifies the metrics of an operation on a recordset.
+type MetricsKey struct {
+	Operation Operation
+	Recordset string
+}
+
+// String returns the key as "Operation/recordset".
+func (k MetricsKey) String() string {
+	return string(k.Operation) + "/" + k.Recordset
+}
+
+// LatencySnapshot is a latency histogram.
+type LatencySnapshot struct {
+	Count int64         `json:"count"`
+	Sum   time.Duration `json:"sum"`
+	Max   time.Duration `json:"max"`
+	// Buckets holds the number of observations not greater than the bound of
+	// the bucket with the same index, and not counted in a previous bucket.
+	// The last element counts observations greater than all bounds.
+	Buckets []int64         `json:"buckets"`
+	Bounds  []time.Duration `json:"bounds"`
+}
+
+// MetricsSnapshot is a point-in-time copy of the metrics collected by InMemoryMetrics.
+type MetricsSnapshot struct {
+	Counters  map[MetricsKey]map[Counter]int64
+	Latencies map[MetricsKey]LatencySnapshot
+	// Pools holds the connection pool statistics by database ID.
+	Pools map[string]sql.DBStats
+}
+
+// Count returns the value of a counter, or zero if it was never incremented.
+func (s MetricsSnapshot) Count(op Operation, recordset string, counter Counter) int64 {
+	return s.Counters[MetricsKey{Operation: op, Recordset: recordset}][counter]
+}
+
+// MarshalJSON encodes the snapshot with "Operation/recordset" keys.
+func (s MetricsSnapshot) MarshalJSON() ([]byte, error) {
+	counters := make(map[string]map[Counter]int64, len(s.Counters))
+	for k, v := range s.Counters {
+		counters[k.String()] = v
+	}
+	latencies := make(map[string]LatencySnapshot, len(s.Latencies))
+	for k, v := range s.Latencies {
+		latencies[k.String()] = v
+	}
+	return json.Marshal(struct {
+		Counters  map[string]map[Counter]int64 `json:"counters"`
+		Latencies map[string]LatencySnapshot   `json:"latencies"`
+		Pools     map[string]sql.DBStats       `json:"pools"`
+	}{counters, latencies, s.Pools})
+}
+
+// InMemoryMetrics is a Metrics implementation that keeps counters and
+// latency histograms in memory. It implements expvar.Var, so it can be
+// published with expvar.Publish.
+type InMemoryMetrics struct {
+	bounds    []time.Duration
+	mu        sync.Mutex
+	counters  map[MetricsKey]map[Counter]int64
+	latencies map[MetricsKey]*LatencySnapshot
+	pools     map[string]func() sql.DBStats
+}
+
+var _ Metrics = (*InMemoryMetrics)(nil)
+
+// NewInMemoryMetrics creates an InMemoryMetrics with latency histograms of the
+// given bucket upper bounds, or DefaultLatencyBuckets if none are given.
+func NewInMemoryMetrics(buckets ...time.Duration) *InMemoryMetrics {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	bounds := slices.Clone(buckets)
+	slices.Sort(bounds)
+	return &InMemoryMetrics{
+		bounds:    bounds,
+		counters:  make(map[MetricsKey]map[Counter]int64),
+		latencies: make(map[MetricsKey]*LatencySnapshot),
+		pools:     make(map[string]func() sql.DBStats),
+	}
+}
+
+func (m *InMemoryMetrics) AddCount(op Operation, recordset string, counter Counter, delta int64) {
+	key := MetricsKey{Operation: op, Recordset: recordset}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	counters := m.counters[key]
+	if counters == nil {
+		counters = make(map[Counter]int64)
+		m.counters[key] = counters
+	}
+	counters[counter] += delta
+}
+
+func (m *InMemoryMetrics) ObserveLatency(op Operation, recordset string, d time.Duration) {
+	key := MetricsKey{Operation: op, Recordset: recordset}
+	i, _ := slices.BinarySearch(m.bounds, d)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h := m.latencies[key]
+	if h == nil {
+		h = &LatencySnapshot{Buckets: make([]int64, len(m.bounds)+1), Bounds: m.bounds}
+		m.latencies[key] = h
+	}
+	h.Count++
+	h.Sum += d
+	h.Max = max(h.Max, d)
+	h.Buckets[i]++
+}
+
+func (m *InMemoryMetrics) RegisterPool(databaseID string, stats func() sql.DBStats) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pools[databaseID] = stats
+}
+
+// Snapshot returns a copy of the collected metrics and the current
+// statistics of the registered connection pools.
+func (m *InMemoryMetrics) Snapshot() MetricsSnapshot {
+	m.mu.Lock()
+	snapshot := MetricsSnapshot{
+		Counters:  make(map[MetricsKey]map[Counter]int64, len(m.counters)),
+		Latencies: make(map[MetricsKey]LatencySnapshot, len(m.latencies)),
+		Pools:     make(map[string]sql.DBStats, len(m.pools)),
+	}
+	for k, v := range m.counters {
+		counters := make(map[Counter]int64, len(v))
+		for c, n := range v {
+			counters[c] = n
+		}
+		snapshot.Counters[k] = counters
+	}
+	for k, v := range m.latencies {
+		h := *v
+		h.Buckets = slices.Clone(v.Buckets)
+		snapshot.Latencies[k] = h
+	}
+	pools := make(map[string]func() sql.DBStats, len(m.pools))
+	for id, stats := range m.pools {
+		pools[id] = stats
+	}
+	m.mu.Unlock()
+	// Pool stats are read without holding the lock as sql.DB.Stats takes its own.
+	for id, stats := range pools {
+		snapshot.Pools[id] = stats()
+	}
+	return snapshot
+}
+
+// Reset clears the collected counters and latencies. Registered pools are kept.
+func (m *InMemoryMetrics) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counters = make(map[MetricsKey]map[Counter]int64)
+	m.latencies = make(map[MetricsKey]*LatencySnapshot)
+}
+
+// String returns the snapshot as JSON, implementing expvar.Var.
+func (m *InMemoryMetrics) String() string {
+	b, err := json.Marshal(m.Snapshot())
+	if err != nil {
+		return "{}"
+	}
+	return string(b)
+}
@@ -0,0 +1,181 @@
+package dalgo2sql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dal-go/dalgo/dal"
+	dalrecord "github.com/dal-go/record"
+	"github.com/dal-go/record/update"
+)
+
+func TestInMemoryMetrics(t *testing.T) {
+	m := NewInMemoryMetrics(time.Millisecond, 10*time.Millisecond)
+	m.AddCount(OpGet, "users", CounterGets, 2)
+	m.AddCount(OpGet, "users", CounterGets, 1)
+	m.AddCount(OpGet, "users", CounterMisses, 1)
+	for _, d := range []time.Duration{time.Microsecond, time.Millisecond, 5 * time.Millisecond, time.Second} {
+		m.ObserveLatency(OpGet, "users", d)
+	}
+	m.RegisterPool("main", func() sql.DBStats { return sql.DBStats{OpenConnections: 3} })
+
+	snapshot := m.Snapshot()
+	if got := snapshot.Count(OpGet, "users", CounterGets); got != 3 {
+		t.Errorf("gets = %d, want 3", got)
+	}
+	if got := snapshot.Count(OpGet, "users", CounterMisses); got != 1 {
+		t.Errorf("misses = %d, want 1", got)
+	}
+	if got := snapshot.Count(OpInsert, "users", CounterInserts); got != 0 {
+		t.Errorf("inserts = %d, want 0", got)
+	}
+	want := LatencySnapshot{
+		Count:   4,
+		Sum:     time.Microsecond + time.Millisecond + 5*time.Millisecond + time.Second,
+		Max:     time.Second,
+		Buckets: []int64{2, 1, 1},
+		Bounds:  []time.Duration{time.Millisecond, 10 * time.Millisecond},
+	}
+	if got := snapshot.Latencies[MetricsKey{OpGet, "users"}]; !reflect.DeepEqual(got, want) {
+		t.Errorf("latency = %+v, want %+v", got, want)
+	}
+	if got := snapshot.Pools["main"].OpenConnections; got != 3 {
+		t.Errorf("pool OpenConnections = %d, want 3", got)
+	}
+
+	t.Run("snapshot_is_a_copy", func(t *testing.T) {
+		m.AddCount(OpGet, "users", CounterGets, 10)
+		m.ObserveLatency(OpGet, "users", time.Microsecond)
+		if got := snapshot.Count(OpGet, "users", CounterGets); got != 3 {
+			t.Errorf("snapshot changed: gets = %d", got)
+		}
+		if got := snapshot.Latencies[MetricsKey{OpGet, "users"}].Buckets[0]; got != 2 {
+			t.Errorf("snapshot changed: bucket = %d", got)
+		}
+	})
+
+	t.Run("String_is_JSON", func(t *testing.T) {
+		var decoded struct {
+			Counters map[string]map[string]int64 `json:"counters"`
+			Pools    map[string]json.RawMessage  `json:"pools"`
+		}
+		if err := json.Unmarshal([]byte(m.String()), &decoded); err != nil {
+			t.Fatalf("invalid JSON: %v", err)
+		}
+		if decoded.Counters["Get/users"]["gets"] != 13 {
+			t.Errorf("unexpected counters: %v", decoded.Counters)
+		}
+		if _, ok := decoded.Pools["main"]; !ok {
+			t.Error("expected pool stats")
+		}
+	})
+
+	t.Run("Reset", func(t *testing.T) {
+		m.Reset()
+		snapshot := m.Snapshot()
+		if len(snapshot.Counters) != 0 || len(snapshot.Latencies) != 0 {
+			t.Errorf("expected no metrics after Reset, got %+v", snapshot)
+		}
+		if _, ok := snapshot.Pools["main"]; !ok {
+			t.Error("expected pools to be kept")
+		}
+	})
+}
+
+func TestMetrics_SQLite(t *testing.T) {
+	ctx := context.Background()
+	metrics := NewInMemoryMetrics()
+	db := dal.BackendOf(NewDatabase(openTestSQLiteDB(t, `CREATE TABLE users (ID TEXT PRIMARY KEY, Name TEXT)`), newSchema(), DbOptions{
+		ID:      "main",
+		Metrics: metrics,
+		Recordsets: map[string]*Recordset{
+			"users": NewRecordset("users", Table, []dal.FieldRef{dal.Field("ID")}),
+		},
+	})).(*database)
+	type user struct {
+		Name string
+	}
+	newRecord := func(id string) dalrecord.Record {
+		return dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("users", id), &user{Name: id})
+	}
+
+	for _, id := range []string{"u1", "u2"} {
+		if err := db.Insert(ctx, newRecord(id)); err != nil {
+			t.Fatalf("Insert(%s): %v", id, err)
+		}
+	}
+	if err := db.Insert(ctx, newRecord("u1")); err == nil {
+		t.Fatal("expected duplicate insert to fail")
+	}
+	if err := db.Get(ctx, newRecord("u1")); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if err := db.Get(ctx, newRecord("nobody")); !errors.Is(err, dalrecord.ErrRecordNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+	if err := db.GetMulti(ctx, []dalrecord.Record{newRecord("u1"), newRecord("u2"), newRecord("u3")}); err != nil {
+		t.Fatalf("GetMulti: %v", err)
+	}
+	if err := db.Set(ctx, newRecord("u3")); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := db.Update(ctx, dalrecord.NewKeyWithID("users", "u3"), []update.Update{update.ByFieldName("Name", "x")}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if err := db.DeleteMulti(ctx, []*dalrecord.Key{dalrecord.NewKeyWithID("users", "u2"), dalrecord.NewKeyWithID("users", "u3")}); err != nil {
+		t.Fatalf("DeleteMulti: %v", err)
+	}
+	if err := db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx dal.ReadwriteTransaction) error {
+		return tx.Insert(ctx, newRecord("u4"))
+	}); err != nil {
+		t.Fatalf("transaction: %v", err)
+	}
+	rollback := errors.New("rollback")
+	if err := db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx dal.ReadwriteTransaction) error {
+		return rollback
+	}); !errors.Is(err, rollback) {
+		t.Fatalf("expected rollback error, got %v", err)
+	}
+
+	snapshot := metrics.Snapshot()
+	for _, tt := range []struct {
+		op      Operation
+		counter Counter
+		want    int64
+	}{
+		{OpInsert, CounterInserts, 3},
+		{OpInsert, CounterConflicts, 1},
+		{OpInsert, CounterErrors, 1},
+		{OpGet, CounterGets, 1},
+		{OpGet, CounterMisses, 1},
+		{OpGetMulti, CounterGets, 2},
+		{OpGetMulti, CounterMisses, 1},
+		{OpSet, CounterInserts, 1},
+		{OpUpdate, CounterUpdates, 1},
+		{OpDeleteMulti, CounterDeletes, 2},
+	} {
+		if got := snapshot.Count(tt.op, "users", tt.counter); got != tt.want {
+			t.Errorf("%s/users %s = %d, want %d", tt.op, tt.counter, got, tt.want)
+		}
+	}
+	if got := snapshot.Count(OpTransaction, "", CounterCommits); got != 1 {
+		t.Errorf("commits = %d, want 1", got)
+	}
+	if got := snapshot.Count(OpTransaction, "", CounterRollbacks); got != 1 {
+		t.Errorf("rollbacks = %d, want 1", got)
+	}
+	if got := snapshot.Latencies[MetricsKey{OpGet, "users"}].Count; got != 2 {
+		t.Errorf("Get latency observations = %d, want 2", got)
+	}
+	if got := snapshot.Latencies[MetricsKey{OpTransaction, ""}].Count; got != 2 {
+		t.Errorf("transaction latency observations = %d, want 2", got)
+	}
+	if _, ok := snapshot.Pools["main"]; !ok {
+		t.Error("expected pool stats of the database")
+	}
+}
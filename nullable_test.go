@@ -0,0 +1,250 @@
+package dalgo2sql
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dal-go/dalgo/dal"
+	dalrecord "github.com/dal-go/record"
+	"github.com/dal-go/record/update"
+)
+
+type nullableProfile struct {
+	Nick     *string
+	Age      *int64
+	Score    *float64
+	Active   *bool
+	BornAt   *time.Time
+	Address  *jsonAddress `dalgo2sql:"json"`
+	Email    sql.NullString
+	Visits   sql.NullInt64
+	Rank     sql.NullInt32
+	Ratio    sql.NullFloat64
+	Verified sql.NullBool
+	SeenAt   sql.NullTime
+	Label    sql.Null[string]
+}
+
+const nullableProfilesDDL = `CREATE TABLE profiles (
+	ID       TEXT PRIMARY KEY,
+	Nick     TEXT,
+	Age      INTEGER,
+	Score    REAL,
+	Active   BOOLEAN,
+	BornAt   DATETIME,
+	Address  TEXT,
+	Email    TEXT,
+	Visits   INTEGER,
+	Rank     INTEGER,
+	Ratio    REAL,
+	Verified BOOLEAN,
+	SeenAt   DATETIME,
+	Label    TEXT
+)`
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func newFilledProfile() nullableProfile {
+	born := time.Date(1990, 4, 5, 6, 7, 8, 0, time.UTC)
+	seen := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	return nullableProfile{
+		Nick:     ptr("jo"),
+		Age:      ptr(int64(34)),
+		Score:    ptr(9.5),
+		Active:   ptr(true),
+		BornAt:   &born,
+		Address:  &jsonAddress{City: "Cork"},
+		Email:    sql.NullString{String: "jo@example.com", Valid: true},
+		Visits:   sql.NullInt64{Int64: 7, Valid: true},
+		Rank:     sql.NullInt32{Int32: 2, Valid: true},
+		Ratio:    sql.NullFloat64{Float64: 0.25, Valid: true},
+		Verified: sql.NullBool{Bool: false, Valid: true},
+		SeenAt:   sql.NullTime{Time: seen, Valid: true},
+		Label:    sql.Null[string]{V: "gold", Valid: true},
+	}
+}
+
+// assertProfile compares profiles field by field; times are compared with
+// time.Time.Equal as drivers may return them in a different location.
+func assertProfile(t *testing.T, got, want nullableProfile) {
+	t.Helper()
+	switch {
+	case (got.BornAt == nil) != (want.BornAt == nil):
+		t.Errorf("BornAt = %v, want %v", got.BornAt, want.BornAt)
+	case got.BornAt != nil && !got.BornAt.Equal(*want.BornAt):
+		t.Errorf("BornAt = %v, want %v", *got.BornAt, *want.BornAt)
+	}
+	if got.SeenAt.Valid != want.SeenAt.Valid || !got.SeenAt.Time.Equal(want.SeenAt.Time) {
+		t.Errorf("SeenAt = %+v, want %+v", got.SeenAt, want.SeenAt)
+	}
+	got.BornAt, want.BornAt = nil, nil
+	got.SeenAt, want.SeenAt = sql.NullTime{}, sql.NullTime{}
+	gotV, wantV := reflect.ValueOf(got), reflect.ValueOf(want)
+	for i := 0; i < gotV.NumField(); i++ {
+		if g, w := gotV.Field(i).Interface(), wantV.Field(i).Interface(); !reflect.DeepEqual(g, w) {
+			t.Errorf("%s = %#v, want %#v", gotV.Type().Field(i).Name, g, w)
+		}
+	}
+}
+
+func TestNullableFields_SQLiteRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	sqlDB := openTestSQLiteDB(t, nullableProfilesDDL)
+	db := dal.BackendOf(NewDatabase(sqlDB, newSchema(), DbOptions{
+		Dialect: DialectSQLite,
+		Recordsets: map[string]*Recordset{
+			"profiles": NewRecordset("profiles", Table, []dal.FieldRef{dal.Field("ID")}),
+		},
+	})).(*database)
+
+	want := map[string]nullableProfile{
+		"empty":  {},
+		"filled": newFilledProfile(),
+	}
+	for id, p := range want {
+		data := p
+		if err := db.Insert(ctx, dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("profiles", id), &data)); err != nil {
+			t.Fatalf("Insert(%s): %v", id, err)
+		}
+	}
+
+	t.Run("stored_as_NULL", func(t *testing.T) {
+		var nulls int
+		row := sqlDB.QueryRow(`SELECT
+			(Nick IS NULL) + (Age IS NULL) + (Score IS NULL) + (Active IS NULL) + (BornAt IS NULL) +
+			(Address IS NULL) + (Email IS NULL) + (Visits IS NULL) + (Rank IS NULL) + (Ratio IS NULL) +
+			(Verified IS NULL) + (SeenAt IS NULL) + (Label IS NULL)
+			FROM profiles WHERE ID = 'empty'`)
+		if err := row.Scan(&nulls); err != nil {
+			t.Fatal(err)
+		}
+		if nulls != 13 {
+			t.Errorf("expected all 13 columns to be NULL, got %d", nulls)
+		}
+	})
+
+	t.Run("Get", func(t *testing.T) {
+		for id, w := range want {
+			got := newFilledProfile() // pre-filled to make sure NULLs reset fields
+			if err := db.Get(ctx, dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("profiles", id), &got)); err != nil {
+				t.Fatalf("Get(%s): %v", id, err)
+			}
+			assertProfile(t, got, w)
+		}
+	})
+
+	t.Run("GetMulti", func(t *testing.T) {
+		got := map[string]*nullableProfile{"empty": ptr(newFilledProfile()), "filled": {}}
+		records := []dalrecord.Record{
+			dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("profiles", "empty"), got["empty"]),
+			dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("profiles", "filled"), got["filled"]),
+		}
+		if err := db.GetMulti(ctx, records); err != nil {
+			t.Fatalf("GetMulti: %v", err)
+		}
+		for id, w := range want {
+			assertProfile(t, *got[id], w)
+		}
+	})
+
+	t.Run("Get_map", func(t *testing.T) {
+		got := map[string]any{}
+		if err := db.Get(ctx, dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("profiles", "empty"), got)); err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		for _, column := range []string{"Nick", "Age", "Email", "Label"} {
+			if v, ok := got[column]; !ok || v != nil {
+				t.Errorf("%s = %#v (present: %v), want a nil entry", column, v, ok)
+			}
+		}
+	})
+
+	t.Run("Update_to_nil", func(t *testing.T) {
+		key := dalrecord.NewKeyWithID("profiles", "filled")
+		if err := db.Update(ctx, key, []update.Update{
+			update.ByFieldName("Nick", (*string)(nil)),
+			update.ByFieldName("Email", sql.NullString{}),
+			update.ByFieldName("Address", (*jsonAddress)(nil)),
+		}); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		var got nullableProfile
+		if err := db.Get(ctx, dalrecord.NewRecordWithData(key, &got)); err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if got.Nick != nil || got.Email.Valid || got.Address != nil {
+			t.Errorf("expected NULLs after update, got Nick=%v Email=%+v Address=%v", got.Nick, got.Email, got.Address)
+		}
+		if got.Age == nil || *got.Age != 34 {
+			t.Errorf("Age = %v, want 34", got.Age)
+		}
+	})
+}
+
+func TestNullableFields_MapData(t *testing.T) {
+	ctx := context.Background()
+	sqlDB := openTestSQLiteDB(t, `CREATE TABLE notes (ID TEXT PRIMARY KEY, Title TEXT, Body TEXT)`)
+	db := dal.BackendOf(NewDatabase(sqlDB, newSchema(), DbOptions{
+		Recordsets: map[string]*Recordset{
+			"notes": NewRecordset("notes", Table, []dal.FieldRef{dal.Field("ID")}),
+		},
+	})).(*database)
+
+	if err := db.Insert(ctx, dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("notes", "n1"),
+		map[string]any{"Title": ptr("hello"), "Body": (*string)(nil)})); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	if err := db.Insert(ctx, dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("notes", "n2"),
+		map[string]any{"Title": sql.NullString{}, "Body": "text"})); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+
+	n1, n2 := map[string]any{}, map[string]any{}
+	records := []dalrecord.Record{
+		dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("notes", "n1"), n1),
+		dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("notes", "n2"), n2),
+	}
+	if err := db.GetMulti(ctx, records); err != nil {
+		t.Fatalf("GetMulti: %v", err)
+	}
+	want1 := map[string]any{"ID": "n1", "Title": "hello", "Body": nil}
+	want2 := map[string]any{"ID": "n2", "Title": nil, "Body": "text"}
+	if !reflect.DeepEqual(n1, want1) {
+		t.Errorf("n1 = %#v, want %#v", n1, want1)
+	}
+	if !reflect.DeepEqual(n2, want2) {
+		t.Errorf("n2 = %#v, want %#v", n2, want2)
+	}
+}
+
+func TestColumnMapper_toSQL_Nullable(t *testing.T) {
+	m := columnMapper{}
+	tests := []struct {
+		name  string
+		value any
+		want  any
+	}{
+		{name: "nil", value: nil, want: nil},
+		{name: "nil_pointer", value: (*string)(nil), want: nil},
+		{name: "pointer", value: ptr("x"), want: "x"},
+		{name: "pointer_to_pointer", value: ptr(ptr(int64(5))), want: int64(5)},
+		{name: "null_string_is_kept_for_driver", value: sql.NullString{}, want: sql.NullString{}},
+		{name: "valuer_pointer_is_kept", value: &sql.NullInt64{Int64: 1, Valid: true}, want: &sql.NullInt64{Int64: 1, Valid: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := m.toSQL("col", nil, tt.value)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("toSQL() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
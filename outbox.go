@@ -0,0 +1,346 @@
+package dalgo2sql
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dal-go/dalgo/dal"
+)
+
+// DefaultOutboxTable is used when DbOptions.OutboxTable is not set.
+const DefaultOutboxTable = "dalgo_outbox"
+
+// OutboxEvent is an event enqueued in the outbox, see Outbox.
+type OutboxEvent struct {
+	// ID is assigned by the database.
+	ID int64
+	// Topic tells consumers what the event is about, e.g. "order.created".
+	Topic string
+	// Key identifies the entity of the event, e.g. for partitioning. Optional.
+	Key     string
+	Payload []byte
+	// CreatedAt and Attempts are set by the outbox: Attempts counts
+	// deliveries of the event including the current one.
+	CreatedAt time.Time
+	Attempts  int
+}
+
+// Outbox is implemented by the read-write transactions of this adapter:
+//
+//	err := db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx dal.ReadwriteTransaction) error {
+//		if err := tx.Insert(ctx, order); err != nil {
+//			return err
+//		}
+//		return tx.(dalgo2sql.Outbox).Enqueue(ctx, dalgo2sql.OutboxEvent{Topic: "order.created", Payload: payload})
+//	})
+//
+// Events are written to DbOptions.OutboxTable in the transaction, so they are
+// published if and only if it commits, by an OutboxDispatcher. The table is
+// not scoped by Tenancy and is expected to look like:
+//
+//	CREATE TABLE dalgo_outbox (
+//		id INTEGER PRIMARY KEY AUTOINCREMENT, -- BIGSERIAL, BIGINT AUTO_INCREMENT
+//		topic TEXT NOT NULL,
+//		event_key TEXT,
+//		payload BLOB, -- BYTEA
+//		created_at TIMESTAMP NOT NULL,
+//		attempts INTEGER NOT NULL DEFAULT 0,
+//		available_at BIGINT NOT NULL, -- Unix milliseconds
+//		lease_token TEXT,
+//		last_error TEXT
+//	)
+type Outbox interface {
+	Enqueue(ctx context.Context, events ...OutboxEvent) error
+}
+
+var _ Outbox = (*transaction)(nil)
+
+func (t transaction) Enqueue(ctx context.Context, events ...OutboxEvent) error {
+	return enqueue(ctx, t.sqlOptions, t.exec, events)
+}
+
+func (o DbOptions) outboxTable() string {
+	if o.OutboxTable == "" {
+		return DefaultOutboxTable
+	}
+	return o.OutboxTable
+}
+
+func enqueue(ctx context.Context, options DbOptions, exec statementExecutor, events []OutboxEvent) error {
+	table := options.outboxTable()
+	text := options.cachedSQL(
+		func() string { return statementShape("outbox", table) },
+		func() string {
+			return options.Placeholder.rewritePlaceholders(fmt.Sprintf(
+				"INSERT INTO %s (topic, event_key, payload, created_at, attempts, available_at) VALUES (?, ?, ?, ?, 0, ?)", table))
+		})
+	exec = options.traceStatement(table, exec)
+	now := options.now()
+	for i, event := range events {
+		if event.Topic == "" {
+			return fmt.Errorf("outbox event #%d of %d has no topic", i+1, len(events))
+		}
+		if _, err := exec(ctx, text, event.Topic, event.Key, event.Payload, now, now.UnixMilli()); err != nil {
+			return fmt.Errorf("failed to enqueue outbox event #%d of %d: %w", i+1, len(events), err)
+		}
+	}
+	return nil
+}
+
+// OutboxHandler publishes an event of the outbox. An error makes the
+// dispatcher retry the event later, so delivery is at least once and
+// handlers are expected to be idempotent.
+type OutboxHandler func(ctx context.Context, event OutboxEvent) error
+
+// DefaultOutboxBatchSize is used when OutboxDispatcherOptions.BatchSize is not set.
+const DefaultOutboxBatchSize = 100
+
+// OutboxDispatcherOptions tune an OutboxDispatcher. Zero values use defaults.
+type OutboxDispatcherOptions struct {
+	// BatchSize is the maximum number of events claimed at once.
+	BatchSize int
+	// PollInterval is the wait between polls that found fewer events than
+	// BatchSize. Defaults to a second.
+	PollInterval time.Duration
+	// Lease is how long claimed events are hidden from other dispatchers
+	// while they are handled. Events of a dispatcher that crashed are
+	// delivered again once their lease expires. Defaults to 30 seconds.
+	Lease time.Duration
+	// MaxAttempts stops retrying events that failed that many times, leaving
+	// them in the table with their last error. Zero retries forever.
+	MaxAttempts int
+	// Backoff returns the delay before retrying an event that failed on the
+	// given attempt. Defaults to doubling from a second up to 5 minutes.
+	Backoff func(attempt int) time.Duration
+	// OnError is called with errors of polls by Run, which keeps polling.
+	OnError func(err error)
+}
+
+func defaultOutboxBackoff(attempt int) time.Duration {
+	const maxDelay = 5 * time.Minute
+	if attempt > 9 {
+		return maxDelay
+	}
+	return min(time.Second<<max(attempt-1, 0), maxDelay)
+}
+
+// OutboxDispatcher delivers events enqueued in the outbox to a handler.
+// Several dispatchers may poll the same table: with DialectPostgres and
+// DialectMySQL events are claimed with SELECT ... FOR UPDATE SKIP LOCKED,
+// other dialects, e.g. SQLite, claim events by leasing them with a
+// conditional update.
+type OutboxDispatcher struct {
+	db      *database
+	handler OutboxHandler
+	options OutboxDispatcherOptions
+}
+
+// NewOutboxDispatcher creates a dispatcher of the outbox of a database
+// created by NewDatabase.
+func NewOutboxDispatcher(db dal.DB, handler OutboxHandler, options OutboxDispatcherOptions) (*OutboxDispatcher, error) {
+	dtb, ok := dal.BackendOf(db).(*database)
+	if !ok {
+		return nil, fmt.Errorf("%w: outbox of %T", dal.ErrNotSupported, dal.BackendOf(db))
+	}
+	if handler == nil {
+		return nil, errors.New("outbox handler is required")
+	}
+	if options.BatchSize <= 0 {
+		options.BatchSize = DefaultOutboxBatchSize
+	}
+	if options.PollInterval <= 0 {
+		options.PollInterval = time.Second
+	}
+	if options.Lease <= 0 {
+		options.Lease = 30 * time.Second
+	}
+	if options.Backoff == nil {
+		options.Backoff = defaultOutboxBackoff
+	}
+	return &OutboxDispatcher{db: dtb, handler: handler, options: options}, nil
+}
+
+// Run dispatches events until the context is done and returns its error.
+func (d *OutboxDispatcher) Run(ctx context.Context) error {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+		n, err := d.DispatchOnce(ctx)
+		if err != nil && ctx.Err() == nil && d.options.OnError != nil {
+			d.options.OnError(err)
+		}
+		if n < d.options.BatchSize || err != nil {
+			timer.Reset(d.options.PollInterval)
+		} else {
+			timer.Reset(0)
+		}
+	}
+}
+
+// DispatchOnce claims a batch of due events, hands them to the handler and
+// returns the number of events handled. Events the handler fails are
+// scheduled for a retry.
+func (d *OutboxDispatcher) DispatchOnce(ctx context.Context) (int, error) {
+	token, err := newLeaseToken()
+	if err != nil {
+		return 0, err
+	}
+	events, err := d.claim(ctx, token)
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim outbox events: %w", err)
+	}
+	for i, event := range events {
+		if handlerErr := d.handler(ctx, event); handlerErr != nil {
+			err = d.retry(ctx, event, token, handlerErr)
+		} else {
+			err = d.complete(ctx, event, token)
+		}
+		if err != nil {
+			return i, err
+		}
+	}
+	return len(events), nil
+}
+
+func newLeaseToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate outbox lease token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// dueCondition selects events that are neither leased nor waiting for a retry.
+func (d *OutboxDispatcher) dueCondition() string {
+	if d.options.MaxAttempts > 0 {
+		return fmt.Sprintf("available_at <= ? AND attempts < %d", d.options.MaxAttempts)
+	}
+	return "available_at <= ?"
+}
+
+// claim leases due events to the token and returns them.
+func (d *OutboxDispatcher) claim(ctx context.Context, token string) ([]OutboxEvent, error) {
+	options := d.db.options
+	table := options.outboxTable()
+	now := options.now()
+	leaseUntil := now.Add(d.options.Lease).UnixMilli()
+	selectDue := fmt.Sprintf("SELECT id FROM %s WHERE %s ORDER BY id LIMIT %d", table, d.dueCondition(), d.options.BatchSize)
+	// The WHERE clause of the lease is completed by the claiming strategy.
+	lease := fmt.Sprintf("UPDATE %s SET available_at = ?, lease_token = ?, attempts = attempts + 1 WHERE id", table)
+	switch options.Dialect {
+	case DialectPostgres, DialectMySQL:
+		lockClause, err := options.Dialect.rowLockClause(RowLock{Strength: LockForUpdate, Wait: LockSkipLocked})
+		if err != nil {
+			return nil, err
+		}
+		tx, err := d.db.db.BeginTx(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = tx.Rollback() }()
+		ids, err := selectIDs(ctx, options, tx.QueryContext, selectDue+lockClause, now.UnixMilli())
+		if err != nil || len(ids) == 0 {
+			return nil, err
+		}
+		q := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+		args := append([]any{leaseUntil, token}, ids...)
+		text := options.Placeholder.rewritePlaceholders(lease + " IN (" + q + ")")
+		if _, err = options.traceStatement(table, tx.ExecContext)(ctx, text, args...); err != nil {
+			return nil, err
+		}
+		if err = tx.Commit(); err != nil {
+			return nil, err
+		}
+	default:
+		ids, err := selectIDs(ctx, options, d.db.db.QueryContext, selectDue, now.UnixMilli())
+		if err != nil || len(ids) == 0 {
+			return nil, err
+		}
+		// Another dispatcher may have leased an event since it was selected,
+		// so it is only leased if it is still due.
+		text := options.Placeholder.rewritePlaceholders(lease + " = ? AND " + d.dueCondition())
+		exec := options.traceStatement(table, d.db.db.ExecContext)
+		for _, id := range ids {
+			if _, err = exec(ctx, text, leaseUntil, token, id, now.UnixMilli()); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return d.leased(ctx, token)
+}
+
+func selectIDs(ctx context.Context, options DbOptions, query executeQueryFunc, text string, args ...any) ([]any, error) {
+	rows, err := options.traceQuery(options.outboxTable(), query)(ctx, options.Placeholder.rewritePlaceholders(text), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	var ids []any
+	for rows.Next() {
+		var id int64
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// leased returns the events leased to a token.
+func (d *OutboxDispatcher) leased(ctx context.Context, token string) ([]OutboxEvent, error) {
+	options := d.db.options
+	table := options.outboxTable()
+	text := options.Placeholder.rewritePlaceholders(fmt.Sprintf(
+		"SELECT id, topic, event_key, payload, created_at, attempts FROM %s WHERE lease_token = ? ORDER BY id", table))
+	rows, err := options.traceQuery(table, d.db.db.QueryContext)(ctx, text, token)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	var events []OutboxEvent
+	for rows.Next() {
+		var event OutboxEvent
+		var key sql.NullString
+		if err = rows.Scan(&event.ID, &event.Topic, &key, &event.Payload, &event.CreatedAt, &event.Attempts); err != nil {
+			return nil, err
+		}
+		event.Key = key.String
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// complete removes a delivered event, unless its lease has been lost.
+func (d *OutboxDispatcher) complete(ctx context.Context, event OutboxEvent, token string) error {
+	options := d.db.options
+	table := options.outboxTable()
+	text := options.Placeholder.rewritePlaceholders(fmt.Sprintf("DELETE FROM %s WHERE id = ? AND lease_token = ?", table))
+	if _, err := options.traceStatement(table, d.db.db.ExecContext)(ctx, text, event.ID, token); err != nil {
+		return fmt.Errorf("failed to complete outbox event %d: %w", event.ID, err)
+	}
+	return nil
+}
+
+// retry releases the lease of a failed event and schedules its next attempt.
+func (d *OutboxDispatcher) retry(ctx context.Context, event OutboxEvent, token string, handlerErr error) error {
+	options := d.db.options
+	table := options.outboxTable()
+	text := options.Placeholder.rewritePlaceholders(fmt.Sprintf(
+		"UPDATE %s SET available_at = ?, lease_token = NULL, last_error = ? WHERE id = ? AND lease_token = ?", table))
+	availableAt := options.now().Add(d.options.Backoff(event.Attempts)).UnixMilli()
+	if _, err := options.traceStatement(table, d.db.db.ExecContext)(ctx, text, availableAt, handlerErr.Error(), event.ID, token); err != nil {
+		return fmt.Errorf("failed to schedule retry of outbox event %d: %w", event.ID, err)
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package dalgo2sql
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dal-go/dalgo/dal"
+)
+
+const outboxTestDDL = `
+	CREATE TABLE dalgo_outbox (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		topic TEXT NOT NULL,
+		event_key TEXT,
+		payload BLOB,
+		created_at TIMESTAMP NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		available_at BIGINT NOT NULL,
+		lease_token TEXT,
+		last_error TEXT
+	);`
+
+func TestDefaultOutboxBackoff(t *testing.T) {
+	for attempt, want := range map[int]time.Duration{0: time.Second, 1: time.Second, 2: 2 * time.Second, 4: 8 * time.Second, 9: 5 * time.Minute, 100: 5 * time.Minute} {
+		if got := defaultOutboxBackoff(attempt); got != want {
+			t.Errorf("defaultOutboxBackoff(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+}
+
+func TestOutbox_SQLite(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	sqlDB := openTestSQLiteDB(t, outboxTestDDL)
+	db := NewDatabase(sqlDB, newSchema(), DbOptions{Clock: func() time.Time { return now }})
+	ctx := context.Background()
+
+	enqueue := func(t *testing.T, commit bool, events ...OutboxEvent) {
+		t.Helper()
+		rollback := errors.New("rollback")
+		err := db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx dal.ReadwriteTransaction) error {
+			if err := tx.(Outbox).Enqueue(ctx, events...); err != nil {
+				return err
+			}
+			if !commit {
+				return rollback
+			}
+			return nil
+		})
+		if commit && err != nil || !commit && !errors.Is(err, rollback) {
+			t.Fatalf("RunReadwriteTransaction: %v", err)
+		}
+	}
+	pending := func(t *testing.T) (n int) {
+		t.Helper()
+		if err := sqlDB.QueryRow(`SELECT COUNT(*) FROM dalgo_outbox`).Scan(&n); err != nil {
+			t.Fatalf("COUNT: %v", err)
+		}
+		return n
+	}
+	var delivered []OutboxEvent
+	var failures int
+	handler := func(_ context.Context, event OutboxEvent) error {
+		if failures > 0 {
+			failures--
+			return errors.New("broker is down")
+		}
+		delivered = append(delivered, event)
+		return nil
+	}
+	newDispatcher := func(t *testing.T, options OutboxDispatcherOptions) *OutboxDispatcher {
+		t.Helper()
+		d, err := NewOutboxDispatcher(db, handler, options)
+		if err != nil {
+			t.Fatalf("NewOutboxDispatcher: %v", err)
+		}
+		return d
+	}
+
+	t.Run("Enqueue", func(t *testing.T) {
+		if err := db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx dal.ReadwriteTransaction) error {
+			return tx.(Outbox).Enqueue(ctx, OutboxEvent{})
+		}); err == nil {
+			t.Error("expected an event without a topic to be rejected")
+		}
+		enqueue(t, false, OutboxEvent{Topic: "lost"})
+		enqueue(t, true, OutboxEvent{Topic: "order.created", Key: "o1", Payload: []byte(`{"id":"o1"}`)}, OutboxEvent{Topic: "order.paid", Key: "o1"})
+		if n := pending(t); n != 2 {
+			t.Errorf("%d pending events, want the 2 committed ones", n)
+		}
+	})
+
+	t.Run("DispatchOnce", func(t *testing.T) {
+		d := newDispatcher(t, OutboxDispatcherOptions{})
+		if n, err := d.DispatchOnce(ctx); err != nil || n != 2 {
+			t.Fatalf("DispatchOnce = %d, %v; want 2", n, err)
+		}
+		if len(delivered) != 2 || delivered[0].Topic != "order.created" || delivered[1].Topic != "order.paid" {
+			t.Fatalf("delivered %+v, want the events in order", delivered)
+		}
+		if e := delivered[0]; e.Key != "o1" || string(e.Payload) != `{"id":"o1"}` || e.Attempts != 1 || !e.CreatedAt.Equal(now) {
+			t.Errorf("delivered %+v", e)
+		}
+		if n := pending(t); n != 0 {
+			t.Errorf("%d pending events, want delivered events to be removed", n)
+		}
+	})
+
+	t.Run("retry", func(t *testing.T) {
+		delivered, failures = nil, 1
+		enqueue(t, true, OutboxEvent{Topic: "retried"})
+		d := newDispatcher(t, OutboxDispatcherOptions{Backoff: func(int) time.Duration { return time.Minute }})
+		if n, err := d.DispatchOnce(ctx); err != nil || n != 1 || len(delivered) != 0 {
+			t.Fatalf("DispatchOnce = %d, %v, delivered %d; want a failed attempt", n, err, len(delivered))
+		}
+		var lastError string
+		if err := sqlDB.QueryRow(`SELECT last_error FROM dalgo_outbox`).Scan(&lastError); err != nil || lastError != "broker is down" {
+			t.Errorf("last_error = %q, %v", lastError, err)
+		}
+		if n, err := d.DispatchOnce(ctx); err != nil || n != 0 {
+			t.Fatalf("DispatchOnce = %d, %v; want the event to wait for its retry", n, err)
+		}
+		now = now.Add(time.Minute)
+		if n, err := d.DispatchOnce(ctx); err != nil || n != 1 || len(delivered) != 1 || delivered[0].Attempts != 2 {
+			t.Fatalf("DispatchOnce = %d, %v, delivered %+v; want the second attempt to succeed", n, err, delivered)
+		}
+	})
+
+	t.Run("MaxAttempts", func(t *testing.T) {
+		delivered, failures = nil, 1
+		enqueue(t, true, OutboxEvent{Topic: "poison"})
+		d := newDispatcher(t, OutboxDispatcherOptions{MaxAttempts: 1, Backoff: func(int) time.Duration { return 0 }})
+		for i := 0; i < 2; i++ {
+			if _, err := d.DispatchOnce(ctx); err != nil {
+				t.Fatalf("DispatchOnce: %v", err)
+			}
+		}
+		if len(delivered) != 0 || pending(t) != 1 {
+			t.Errorf("delivered %d, %d pending; want the event to be kept undelivered", len(delivered), pending(t))
+		}
+		if _, err := sqlDB.Exec(`DELETE FROM dalgo_outbox`); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("lease", func(t *testing.T) {
+		delivered, failures = nil, 0
+		enqueue(t, true, OutboxEvent{Topic: "leased"})
+		crashed := newDispatcher(t, OutboxDispatcherOptions{Lease: time.Minute})
+		if events, err := crashed.claim(ctx, "crashed"); err != nil || len(events) != 1 {
+			t.Fatalf("claim = %d events, %v; want 1", len(events), err)
+		}
+		d := newDispatcher(t, OutboxDispatcherOptions{})
+		if n, err := d.DispatchOnce(ctx); err != nil || n != 0 {
+			t.Fatalf("DispatchOnce = %d, %v; want the leased event to be skipped", n, err)
+		}
+		now = now.Add(time.Minute)
+		if n, err := d.DispatchOnce(ctx); err != nil || n != 1 || len(delivered) != 1 {
+			t.Fatalf("DispatchOnce = %d, %v; want the event to be delivered once its lease expired", n, err)
+		}
+		if err := crashed.complete(ctx, delivered[0], "crashed"); err != nil || pending(t) != 0 {
+			t.Errorf("complete with a lost lease: %v, %d pending", err, pending(t))
+		}
+	})
+
+	t.Run("Run", func(t *testing.T) {
+		delivered, failures = nil, 0
+		enqueue(t, true, OutboxEvent{Topic: "run"})
+		runCtx, cancel := context.WithCancel(ctx)
+		d, err := NewOutboxDispatcher(db, func(ctx context.Context, event OutboxEvent) error {
+			cancel()
+			return handler(ctx, event)
+		}, OutboxDispatcherOptions{PollInterval: time.Millisecond})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = d.Run(runCtx); !errors.Is(err, context.Canceled) {
+			t.Errorf("Run = %v, want context.Canceled", err)
+		}
+		if len(delivered) != 1 {
+			t.Errorf("delivered %d events, want 1", len(delivered))
+		}
+	})
+}
+
+func TestOutboxDispatcher_SkipLocked(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeDatabase(t, sqlDB)
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	db := NewDatabase(sqlDB, newSchema(), DbOptions{
+		Dialect:     DialectPostgres,
+		Placeholder: PlaceholderDollar,
+		Clock:       func() time.Time { return now },
+	})
+	var delivered int
+	d, err := NewOutboxDispatcher(db, func(context.Context, OutboxEvent) error {
+		delivered++
+		return nil
+	}, OutboxDispatcherOptions{BatchSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id FROM dalgo_outbox WHERE available_at <= \$1 ORDER BY id LIMIT 10 FOR UPDATE SKIP LOCKED`).
+		WithArgs(now.UnixMilli()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(7)))
+	mock.ExpectExec(`UPDATE dalgo_outbox SET available_at = \$1, lease_token = \$2, attempts = attempts \+ 1 WHERE id IN \(\$3\)`).
+		WithArgs(now.Add(30*time.Second).UnixMilli(), sqlmock.AnyArg(), int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`SELECT id, topic, event_key, payload, created_at, attempts FROM dalgo_outbox WHERE lease_token = \$1 ORDER BY id`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "topic", "event_key", "payload", "created_at", "attempts"}).
+			AddRow(int64(7), "order.created", nil, []byte("{}"), now, 1))
+	mock.ExpectExec(`DELETE FROM dalgo_outbox WHERE id = \$1 AND lease_token = \$2`).
+		WithArgs(int64(7), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if n, err := d.DispatchOnce(context.Background()); err != nil || n != 1 || delivered != 1 {
+		t.Errorf("DispatchOnce = %d, %v, delivered %d; want 1", n, err, delivered)
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -14,9 +14,10 @@ type readerBase struct {
 	rows     *sql.Rows
 	colNames []string
 	colTypes []*sql.ColumnType
+	mapper   columnMapper
 }
 
-func getReaderBase(ctx context.Context, query dal.Query, execute executeQueryFunc) (readerBase, error) {
+func getReaderBase(ctx context.Context, options DbOptions, query dal.Query, execute executeQueryFunc) (readerBase, error) {
 	var a []any
 	var text string
 	switch q := query.(type) {
@@ -33,14 +34,19 @@ func getReaderBase(ctx context.Context, query dal.Query, execute executeQueryFun
 		// upstream dalgo gains dialect-aware emission — see the
 		// `dalgo-dialect-aware-sql-emission` Idea.
 		text = emitSQL(q)
+		var err error
+		if text, a, err = options.scopeQuery(ctx, queryRecordsetName(query), text); err != nil {
+			return readerBase{}, err
+		}
 	}
 
-	rows, err := execute(ctx, text, a...)
+	rows, err := options.traceQuery(queryRecordsetName(query), execute)(ctx, text, a...)
 	if err != nil {
 		return readerBase{}, err
 	}
 	rb := readerBase{
-		rows: rows,
+		rows:   rows,
+		mapper: newColumnMapper(options, queryRecordsetName(query)),
 	}
 	if rb.colNames, err = rb.rows.Columns(); err != nil {
 		return rb, fmt.Errorf("failed to read column names: %w", err)
@@ -54,6 +60,31 @@ func getReaderBase(ctx context.Context, query dal.Query, execute executeQueryFun
 	return rb, nil
 }
 
+// decodes reports whether decodeValue converts values of the column: the
+// column has a codec registered for its database type or holds JSON, as
+// the driver reports a JSON type or the queried recordset declares it
+// WithJSONColumns, e.g. a TEXT column on SQLite.
+func (rb readerBase) decodes(i int) bool {
+	dbTypeName := rb.colTypes[i].DatabaseTypeName()
+	return rb.mapper.codecs.codecForColumnType(dbTypeName) != nil ||
+		isJSONColumnType(dbTypeName) || rb.mapper.jsonColumns[rb.colNames[i]]
+}
+
+// decodeValue converts a scanned value the way Get converts values of map
+// data: by the codec registered for the column's database type, if any,
+// decoding JSON columns and converting []byte to string.
+func (rb readerBase) decodeValue(i int, value any) (any, error) {
+	name, dbTypeName := rb.colNames[i], rb.colTypes[i].DatabaseTypeName()
+	if value != nil && isJSONColumnType(dbTypeName) && rb.mapper.codecs.codecForColumnType(dbTypeName) == nil {
+		var decoded any
+		if err := decodeJSONValue(name, value, &decoded); err != nil {
+			return nil, err
+		}
+		return decoded, nil
+	}
+	return rb.mapper.fromSQL(name, dbTypeName, value)
+}
+
 func (rb readerBase) scanValues() (values []any, err error) {
 	values = make([]any, len(rb.colNames))
 	scanArgs := make([]any, len(rb.colNames))
@@ -11,14 +11,14 @@ import (
 
 var _ dal.RecordsReader = (*recordsReader)(nil)
 
-func getRecordsReader(ctx context.Context, query dal.Query, execute executeQueryFunc) (rr *recordsReader, err error) {
+func getRecordsReader(ctx context.Context, options DbOptions, query dal.Query, execute executeQueryFunc) (rr *recordsReader, err error) {
 	rr = &recordsReader{
 		newRecord: func() dalrecord.Record {
 			return dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("Unknown", ""), make(map[string]any))
 		},
 	}
 
-	if rr.readerBase, err = getReaderBase(ctx, query, execute); err != nil {
+	if rr.readerBase, err = getReaderBase(ctx, options, query, execute); err != nil {
 		err = fmt.Errorf("failed to get SQL reader: %w", err)
 		return
 	}
@@ -52,15 +52,13 @@ func (r recordsReader) Next() (record dalrecord.Record, err error) {
 			return nil, err
 		}
 		for i, n := range r.colNames {
-			v := values[i]
 			// database/sql returns []byte for TEXT/VARCHAR columns with some
-			// drivers (notably go-sql-driver/mysql); store as string so the
-			// map is usable and JSON-serializes as text, not base64. Matches
-			// scanRowIntoMap on the Get path.
-			if b, ok := v.([]byte); ok {
-				v = string(b)
+			// drivers (notably go-sql-driver/mysql); decodeValue stores them
+			// as strings so the map is usable and JSON-serializes as text,
+			// not base64, as on the Get path.
+			if d[n], err = r.decodeValue(i, values[i]); err != nil {
+				return nil, err
 			}
-			d[n] = v
 		}
 	default:
 		// TODO: implement Scan into `*struct` and into `[]any`
@@ -82,10 +80,11 @@ func (r recordsReader) Close() error {
 // recordsReaderProvider is embedded into database and transaction
 type recordsReaderProvider struct {
 	executeQuery func(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	options      DbOptions
 }
 
 func (rrp recordsReaderProvider) ExecuteQueryToRecordsReader(ctx context.Context, query dal.Query) (dal.RecordsReader, error) {
-	return getRecordsReader(ctx, query, rrp.executeQuery)
+	return getRecordsReader(withOperation(ctx, OpQuery), rrp.options, query, rrp.executeQuery)
 }
 
 //func (rrp recordsReaderProvider) ReadAllRecords(ctx context.Context, query dal.Query, options ...dal.ReaderOption) ([]record.Record, error) {
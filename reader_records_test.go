@@ -27,7 +27,7 @@ func TestRecordsReader(t *testing.T) {
 			AddRow(2, "Jane")
 		_ = mock.ExpectQuery("SELECT id, name FROM users").WillReturnRows(rows)
 
-		rr, err := getRecordsReader(ctx, query, db.QueryContext)
+		rr, err := getRecordsReader(ctx, DbOptions{}, query, db.QueryContext)
 		if err != nil {
 			t.Fatalf("failed to get records reader: %v", err)
 		}
@@ -68,7 +68,7 @@ func TestRecordsReader(t *testing.T) {
 		rows := sqlmock.NewRows([]string{"id"}).AddRow("not-an-int")
 		mock.ExpectQuery("SELECT id FROM users").WillReturnRows(rows)
 
-		rr, _ := getRecordsReader(ctx, dal.NewTextQuery("SELECT id FROM users", nil), db.QueryContext)
+		rr, _ := getRecordsReader(ctx, DbOptions{}, dal.NewTextQuery("SELECT id FROM users", nil), db.QueryContext)
 		_, _ = rr.Next()
 	})
 
@@ -76,7 +76,7 @@ func TestRecordsReader(t *testing.T) {
 		rows := sqlmock.NewRows([]string{"id"}).AddRow(1)
 		mock.ExpectQuery("SELECT id FROM users").WillReturnRows(rows)
 
-		rr, _ := getRecordsReader(ctx, dal.NewTextQuery("SELECT id FROM users", nil), db.QueryContext)
+		rr, _ := getRecordsReader(ctx, DbOptions{}, dal.NewTextQuery("SELECT id FROM users", nil), db.QueryContext)
 		rr.newRecord = func() dalrecord.Record {
 			return dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("Unknown", ""), 123) // int is not supported
 		}
@@ -99,7 +99,7 @@ func TestRecordsReader(t *testing.T) {
 		rows := sqlmock.NewRows([]string{"id"}).AddRow(1).RowError(0, errors.New("row error"))
 		mock.ExpectQuery("SELECT id FROM users").WillReturnRows(rows)
 
-		rr, _ := getRecordsReader(ctx, dal.NewTextQuery("SELECT id FROM users", nil), db.QueryContext)
+		rr, _ := getRecordsReader(ctx, DbOptions{}, dal.NewTextQuery("SELECT id FROM users", nil), db.QueryContext)
 		_, _ = rr.Next() // consume first row
 		_, err := rr.Next()
 		if err == nil || err.Error() != "row error" {
@@ -12,23 +12,27 @@ import (
 
 var _ dal.RecordsetReader = (*recordsetReader)(nil)
 
-func getRecordsetReader(ctx context.Context, query dal.Query, execute executeQueryFunc, options ...recordset.Option) (rr *recordsetReader, err error) {
+func getRecordsetReader(ctx context.Context, dbOptions DbOptions, query dal.Query, execute executeQueryFunc, options ...recordset.Option) (rr *recordsetReader, err error) {
 	rr = &recordsetReader{}
-	if rr.readerBase, err = getReaderBase(ctx, query, execute); err != nil {
+	if rr.readerBase, err = getReaderBase(ctx, dbOptions, query, execute); err != nil {
 		return nil, err
 	}
 
 	rsOptions := recordset.NewOptions(options...)
 
 	var cols []recordset.Column[any]
-	for _, col := range rr.colTypes {
+	for i, col := range rr.colTypes {
 		name := col.Name()
 		var c recordset.Column[any]
 		scanType := col.ScanType()
 		dbTypeName := col.DatabaseTypeName()
 		dbType := recordset.ColDbType(dbTypeName)
 
-		if scanType == nil {
+		if rr.decodes(i) {
+			// JSON values are decoded into maps, slices and scalars, and codecs
+			// may return values of any type, so such columns are untyped.
+			c = recordset.UntypedCol(recordset.NewTypedColumn[any](name, nil, dbType))
+		} else if scanType == nil {
 			// This happens for some views in SQLite
 			c = recordset.NewColumn[string](name, "")
 		} else {
@@ -140,6 +144,11 @@ func (r *recordsetReader) Next() (row recordset.Row, rs recordset.Recordset, err
 		value := values[i]
 		col := r.rs.GetColumnByIndex(i)
 		vt := col.ValueType()
+		if r.decodes(i) {
+			if value, err = r.decodeValue(i, value); err != nil {
+				return
+			}
+		}
 		if value == nil {
 			if vt == reflect.TypeOf([]byte(nil)) {
 				value = []byte(nil)
@@ -25,7 +25,7 @@ func TestGetRecordsetReader_Binary(t *testing.T) {
 
 		mock.ExpectQuery(query.Text()).WillReturnRows(rows)
 
-		rr, err := getRecordsetReader(ctx, query, db.QueryContext)
+		rr, err := getRecordsetReader(ctx, DbOptions{}, query, db.QueryContext)
 		if err != nil {
 			t.Fatalf("failed to get recordset reader: %v", err)
 		}
@@ -52,7 +52,7 @@ func TestGetRecordsetReader_Binary(t *testing.T) {
 
 		mock.ExpectQuery(query.Text()).WillReturnRows(rows)
 
-		rr, err := getRecordsetReader(ctx, query, db.QueryContext)
+		rr, err := getRecordsetReader(ctx, DbOptions{}, query, db.QueryContext)
 		if err != nil {
 			t.Fatalf("failed to get recordset reader: %v", err)
 		}
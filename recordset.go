@@ -25,9 +25,34 @@ const (
 
 // Recordset hold recordset settings
 type Recordset struct {
-	name       string
-	t          RecordsetType
-	primaryKey []dal.FieldRef // Primary keys by table name
+	name        string
+	t           RecordsetType
+	primaryKey  []dal.FieldRef // Primary keys by table name
+	jsonColumns map[string]bool
+	filters     []namedFilter
+	softDelete  *SoftDelete
+	audit       *AuditColumns
+	version     string
+	history     bool
+	changelog   bool
+	expiry      string
+}
+
+// RecordsetOption customizes a Recordset created by NewRecordset
+type RecordsetOption func(rs *Recordset)
+
+// WithJSONColumns declares columns that hold nested structs, maps or slices
+// serialized as JSON. Struct fields can be marked individually with the
+// `dalgo2sql:"json"` tag instead. Use Dialect.JSONColumnType for the column DDL.
+func WithJSONColumns(names ...string) RecordsetOption {
+	return func(rs *Recordset) {
+		if rs.jsonColumns == nil {
+			rs.jsonColumns = make(map[string]bool, len(names))
+		}
+		for _, name := range names {
+			rs.jsonColumns[name] = true
+		}
+	}
 }
 
 func (v *Recordset) Name() string {
@@ -51,12 +76,16 @@ func (v *Recordset) PrimaryKeyFieldNames() []string {
 	return pk
 }
 
-func NewRecordset(name string, t RecordsetType, primaryKey []dal.FieldRef) *Recordset {
-	return &Recordset{
+func NewRecordset(name string, t RecordsetType, primaryKey []dal.FieldRef, options ...RecordsetOption) *Recordset {
+	rs := &Recordset{
 		name:       name,
 		t:          t,
 		primaryKey: primaryKey,
 	}
+	for _, o := range options {
+		o(rs)
+	}
+	return rs
 }
 
 func (v *Recordset) Type() RecordsetType {
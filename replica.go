@@ -0,0 +1,89 @@
+package dalgo2sql
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"sync/atomic"
+)
+
+// ReplicaBalancer chooses the read replica a read-only operation is routed to.
+// Implementations must be safe for concurrent use.
+type ReplicaBalancer interface {
+	// Pick returns the index of the replica to read from, or a negative
+	// index to read from the primary, e.g. when no replica is healthy.
+	Pick(ctx context.Context, replicas []*sql.DB) int
+}
+
+// ReplicaBalancerFunc adapts a function to ReplicaBalancer.
+type ReplicaBalancerFunc func(ctx context.Context, replicas []*sql.DB) int
+
+func (f ReplicaBalancerFunc) Pick(ctx context.Context, replicas []*sql.DB) int {
+	return f(ctx, replicas)
+}
+
+// NewRoundRobinBalancer returns a ReplicaBalancer that picks replicas in turn.
+// It is used when DbOptions.ReplicaBalancer is not set.
+func NewRoundRobinBalancer() ReplicaBalancer {
+	var next atomic.Uint64
+	return ReplicaBalancerFunc(func(_ context.Context, replicas []*sql.DB) int {
+		return int((next.Add(1) - 1) % uint64(len(replicas)))
+	})
+}
+
+// NewLeastInUseBalancer returns a ReplicaBalancer that picks the replica
+// with the fewest connections in use, the first one on a tie.
+func NewLeastInUseBalancer() ReplicaBalancer {
+	return ReplicaBalancerFunc(func(_ context.Context, replicas []*sql.DB) int {
+		picked, minInUse := 0, -1
+		for i, replica := range replicas {
+			if inUse := replica.Stats().InUse; minInUse < 0 || inUse < minInUse {
+				picked, minInUse = i, inUse
+			}
+		}
+		return picked
+	})
+}
+
+type readFromPrimaryContextKey struct{}
+
+// ReadFromPrimary returns a context that routes reads to the primary database
+// even if read replicas are configured, e.g. to read a record just written
+// when replicas may lag behind the primary (read-your-writes).
+func ReadFromPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readFromPrimaryContextKey{}, true)
+}
+
+func readsFromPrimary(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	forced, _ := ctx.Value(readFromPrimaryContextKey{}).(bool)
+	return forced
+}
+
+// replicaID returns the database ID pool statistics of the i-th replica are registered under.
+func replicaID(databaseID string, i int) string {
+	return databaseID + "/replica-" + strconv.Itoa(i)
+}
+
+// replica is a read replica with the executor of statements cached for it.
+type replica struct {
+	db    *sql.DB
+	query queryExecutor
+}
+
+// reader returns the database a read-only operation outside a transaction
+// runs against: a replica picked by the balancer unless there are no replicas
+// or the context requests reading from the primary.
+func (dtb *database) reader(ctx context.Context) (db *sql.DB, query queryExecutor) {
+	if len(dtb.replicas) == 0 || readsFromPrimary(ctx) {
+		return dtb.db, dtb.query
+	}
+	i := dtb.options.ReplicaBalancer.Pick(ctx, dtb.options.Replicas)
+	if i < 0 || i >= len(dtb.replicas) {
+		return dtb.db, dtb.query
+	}
+	r := dtb.replicas[i]
+	return r.db, r.query
+}
@@ -0,0 +1,178 @@
+package dalgo2sql
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/dal-go/dalgo/dal"
+	dalrecord "github.com/dal-go/record"
+)
+
+func TestRoundRobinBalancer(t *testing.T) {
+	b := NewRoundRobinBalancer()
+	replicas := make([]*sql.DB, 3)
+	for i, want := range []int{0, 1, 2, 0, 1} {
+		if got := b.Pick(context.Background(), replicas); got != want {
+			t.Errorf("pick %d = %d, want %d", i, got, want)
+		}
+	}
+}
+
+func TestReadFromPrimary(t *testing.T) {
+	if readsFromPrimary(context.Background()) {
+		t.Error("expected reads from replicas by default")
+	}
+	if !readsFromPrimary(ReadFromPrimary(context.Background())) {
+		t.Error("expected ReadFromPrimary to force reads from the primary")
+	}
+}
+
+func TestReplicas_SQLite(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	// Each database holds a record named after it, so reads tell where they were routed.
+	open := func(name string) *sql.DB {
+		t.Helper()
+		db, err := sql.Open("sqlite", filepath.Join(dir, name+".db"))
+		if err != nil {
+			t.Fatalf("sql.Open: %v", err)
+		}
+		t.Cleanup(func() { _ = db.Close() })
+		if _, err = db.Exec(`CREATE TABLE users (ID TEXT PRIMARY KEY, Name TEXT)`); err != nil {
+			t.Fatalf("CREATE TABLE: %v", err)
+		}
+		if _, err = db.Exec(`INSERT INTO users (ID, Name) VALUES ('u1', ?)`, name); err != nil {
+			t.Fatalf("INSERT: %v", err)
+		}
+		return db
+	}
+	primary := open("primary")
+	metrics := NewInMemoryMetrics()
+	options := DbOptions{
+		ID:       "main",
+		Replicas: []*sql.DB{open("replica-0"), open("replica-1")},
+		Metrics:  metrics,
+		Recordsets: map[string]*Recordset{
+			"users": NewRecordset("users", Table, []dal.FieldRef{dal.Field("ID")}),
+		},
+		StatementCacheSize: 8,
+	}
+	db := dal.BackendOf(NewDatabase(primary, newSchema(), options)).(*database)
+
+	type user struct {
+		Name string
+	}
+	key := func(id string) *dalrecord.Key { return dalrecord.NewKeyWithID("users", id) }
+	get := func(t *testing.T, ctx context.Context, r dal.ReadSession) string {
+		t.Helper()
+		var u user
+		if err := r.Get(ctx, dalrecord.NewRecordWithData(key("u1"), &u)); err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		return u.Name
+	}
+
+	t.Run("Get", func(t *testing.T) {
+		for i, want := range []string{"replica-0", "replica-1", "replica-0"} {
+			if got := get(t, ctx, db); got != want {
+				t.Errorf("read %d from %s, want %s", i, got, want)
+			}
+		}
+		if got := get(t, ReadFromPrimary(ctx), db); got != "primary" {
+			t.Errorf("read from %s, want primary", got)
+		}
+	})
+
+	t.Run("GetMulti", func(t *testing.T) {
+		var u user
+		records := []dalrecord.Record{dalrecord.NewRecordWithData(key("u1"), &u)}
+		if err := db.GetMulti(ReadFromPrimary(ctx), records); err != nil {
+			t.Fatalf("GetMulti: %v", err)
+		}
+		if u.Name != "primary" {
+			t.Errorf("read from %s, want primary", u.Name)
+		}
+	})
+
+	t.Run("writes_go_to_primary", func(t *testing.T) {
+		if err := db.Insert(ctx, dalrecord.NewRecordWithData(key("u2"), &user{Name: "new"})); err != nil {
+			t.Fatalf("Insert: %v", err)
+		}
+		if exists, err := db.Exists(ctx, key("u2")); err != nil || exists {
+			t.Errorf("Exists on a replica = %v, %v; want false", exists, err)
+		}
+		if exists, err := db.Exists(ReadFromPrimary(ctx), key("u2")); err != nil || !exists {
+			t.Errorf("Exists on the primary = %v, %v; want true", exists, err)
+		}
+	})
+
+	t.Run("transactions", func(t *testing.T) {
+		var readonly, readwrite string
+		err := db.RunReadonlyTransaction(ctx, func(ctx context.Context, tx dal.ReadTransaction) error {
+			readonly = get(t, ctx, tx)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("RunReadonlyTransaction: %v", err)
+		}
+		if readonly == "primary" {
+			t.Error("expected a read-only transaction to run on a replica")
+		}
+		err = db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx dal.ReadwriteTransaction) error {
+			readwrite = get(t, ctx, tx)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("RunReadwriteTransaction: %v", err)
+		}
+		if readwrite != "primary" {
+			t.Errorf("read-write transaction read from %s, want primary", readwrite)
+		}
+	})
+
+	t.Run("query", func(t *testing.T) {
+		read := func(ctx context.Context) string {
+			reader, err := db.ExecuteQueryToRecordsReader(ctx, dal.NewTextQuery("SELECT Name FROM users WHERE ID = 'u1'", nil))
+			if err != nil {
+				t.Fatalf("ExecuteQueryToRecordsReader: %v", err)
+			}
+			defer func() { _ = reader.Close() }()
+			record, err := reader.Next()
+			if err != nil {
+				t.Fatalf("Next: %v", err)
+			}
+			return record.Data().(map[string]any)["Name"].(string)
+		}
+		if got := read(ctx); got == "primary" {
+			t.Error("expected the query to run on a replica")
+		}
+		if got := read(ReadFromPrimary(ctx)); got != "primary" {
+			t.Errorf("query read from %s, want primary", got)
+		}
+	})
+
+	t.Run("balancer", func(t *testing.T) {
+		options := options
+		options.ReplicaBalancer = ReplicaBalancerFunc(func(context.Context, []*sql.DB) int { return -1 })
+		db := NewDatabase(primary, newSchema(), options)
+		if got := get(t, ctx, db); got != "primary" {
+			t.Errorf("read from %s, want primary for a negative pick", got)
+		}
+		options.ReplicaBalancer = NewLeastInUseBalancer()
+		db = NewDatabase(primary, newSchema(), options)
+		if got := get(t, ctx, db); got != "replica-0" {
+			t.Errorf("read from %s, want replica-0 when no connection is in use", got)
+		}
+	})
+
+	t.Run("pools", func(t *testing.T) {
+		pools := metrics.Snapshot().Pools
+		for _, id := range []string{"main", "main/replica-0", "main/replica-1"} {
+			if _, ok := pools[id]; !ok {
+				t.Errorf("pool %s is not registered, got %v", id, pools)
+			}
+		}
+	})
+}
@@ -0,0 +1,146 @@
+package dalgo2sql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	dalrecord "github.com/dal-go/record"
+)
+
+// DefaultVersionColumn is used when WithVersionColumn is given an empty name.
+const DefaultVersionColumn = "version"
+
+// ErrVersionConflict is matched by a *VersionConflictError with errors.Is.
+var ErrVersionConflict = errors.New("version conflict")
+
+// VersionConflictError is returned by writes of a versioned record
+// that has been changed since the caller read it, see WithVersionColumn.
+type VersionConflictError struct {
+	Key *dalrecord.Key
+	// Version is the version the write expected the row to have.
+	Version int64
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("%v: %v is not at version %d", ErrVersionConflict, e.Key, e.Version)
+}
+
+func (e *VersionConflictError) Unwrap() error {
+	return ErrVersionConflict
+}
+
+// WithVersionColumn enables optimistic locking of a recordset with an integer
+// version column: inserts set it to 1, and Set and Update increment it
+// conditioned on the version the caller has read. A write of a row that is
+// no longer at that version fails with a *VersionConflictError.
+//
+// Records carry their version in a field named after the column, which Get
+// fills and writes advance. Set of a record with such a field expects the
+// row at its version, while Update expects the version given by
+// ExpectVersion, and UpdateRecord that of its record. Writes without an
+// expected version increment the version unconditionally.
+func WithVersionColumn(column string) RecordsetOption {
+	if column == "" {
+		column = DefaultVersionColumn
+	}
+	return func(rs *Recordset) {
+		rs.version = column
+	}
+}
+
+type expectedVersionContextKey struct{}
+
+// ExpectVersion returns a context that makes Update of a versioned record
+// fail with a *VersionConflictError unless the row is at the version.
+func ExpectVersion(ctx context.Context, version int64) context.Context {
+	return context.WithValue(ctx, expectedVersionContextKey{}, version)
+}
+
+func expectedVersion(ctx context.Context) (version int64, ok bool) {
+	version, ok = ctx.Value(expectedVersionContextKey{}).(int64)
+	return version, ok
+}
+
+// versionColumn returns the version column of a recordset, or an empty string.
+func (o DbOptions) versionColumn(recordset string) string {
+	if rs := o.Recordsets[recordset]; rs != nil {
+		return rs.version
+	}
+	return ""
+}
+
+// expectVersion conditions writes in the scope on the row being at a version.
+func (s *statementScope) expectVersion(column string, version int64) {
+	s.conditions = append(s.conditions, column+" = ?")
+	s.args = append(s.args, version)
+}
+
+// versionField returns the field or map entry holding the version of a record.
+func versionField(record dalrecord.Record, column string) (reflect.Value, bool) {
+	if column == "" {
+		return reflect.Value{}, false
+	}
+	val := reflect.ValueOf(record.Data())
+	if kind := val.Kind(); kind == reflect.Interface || kind == reflect.Pointer {
+		val = val.Elem()
+	}
+	switch val.Kind() {
+	case reflect.Struct:
+		field := val.FieldByNameFunc(func(name string) bool { return strings.EqualFold(name, column) })
+		return field, field.IsValid()
+	case reflect.Map:
+		if val.Type().Key().Kind() == reflect.String {
+			for _, k := range val.MapKeys() {
+				if strings.EqualFold(k.String(), column) {
+					return val.MapIndex(k), true
+				}
+			}
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// recordVersion returns the version carried by a record.
+func recordVersion(record dalrecord.Record, column string) (int64, bool) {
+	field, ok := versionField(record, column)
+	if !ok {
+		return 0, false
+	}
+	if field.Kind() == reflect.Interface {
+		field = field.Elem()
+	}
+	switch {
+	case field.CanInt():
+		return field.Int(), true
+	case field.CanUint():
+		return int64(field.Uint()), true
+	}
+	return 0, false
+}
+
+// setRecordVersion stores the version of a written record in its data, if it has a version field.
+func setRecordVersion(record dalrecord.Record, column string, version int64) {
+	field, ok := versionField(record, column)
+	if !ok {
+		return
+	}
+	if val := reflect.Indirect(reflect.ValueOf(record.Data())); val.Kind() == reflect.Map {
+		if elem := val.Type().Elem(); elem.Kind() == reflect.Interface || reflect.Zero(elem).CanInt() {
+			for _, k := range val.MapKeys() {
+				if strings.EqualFold(k.String(), column) {
+					val.SetMapIndex(k, reflect.ValueOf(version).Convert(elem))
+				}
+			}
+		}
+		return
+	}
+	switch {
+	case field.CanSet() && field.CanInt():
+		field.SetInt(version)
+	case field.CanSet() && field.CanUint():
+		field.SetUint(uint64(version))
+	}
+}
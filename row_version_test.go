@@ -0,0 +1,179 @@
+package dalgo2sql
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dal-go/dalgo/dal"
+	dalrecord "github.com/dal-go/record"
+	"github.com/dal-go/record/update"
+)
+
+func TestRecordVersion(t *testing.T) {
+	type versioned struct {
+		Name    string
+		Version int
+	}
+	key := dalrecord.NewKeyWithID("items", "i1")
+
+	data := &versioned{Version: 3}
+	record := dalrecord.NewRecordWithData(key, data)
+	if v, ok := recordVersion(record, "version"); !ok || v != 3 {
+		t.Errorf("recordVersion = %d, %v; want 3", v, ok)
+	}
+	setRecordVersion(record, "version", 4)
+	if data.Version != 4 {
+		t.Errorf("Version = %d, want 4", data.Version)
+	}
+
+	m := map[string]any{"version": int64(7)}
+	record = dalrecord.NewRecordWithData(key, m)
+	if v, ok := recordVersion(record, "version"); !ok || v != 7 {
+		t.Errorf("recordVersion = %d, %v; want 7", v, ok)
+	}
+	setRecordVersion(record, "version", 8)
+	if m["version"] != int64(8) {
+		t.Errorf("version = %v, want 8", m["version"])
+	}
+
+	record = dalrecord.NewRecordWithData(key, &struct{ Name string }{})
+	if _, ok := recordVersion(record, "version"); ok {
+		t.Error("expected no version in a record without a version field")
+	}
+	if _, ok := recordVersion(record, ""); ok {
+		t.Error("expected no version for an empty column")
+	}
+}
+
+func TestVersionConflictError(t *testing.T) {
+	var err error = &VersionConflictError{Key: dalrecord.NewKeyWithID("items", "i1"), Version: 2}
+	if !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("expected %v to match ErrVersionConflict", err)
+	}
+}
+
+func TestRowVersion_DollarPlaceholders(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeDatabase(t, sqlDB)
+	db := NewDatabase(sqlDB, newSchema(), DbOptions{
+		Placeholder: PlaceholderDollar,
+		Recordsets: map[string]*Recordset{
+			"items": NewRecordset("items", Table, []dal.FieldRef{dal.Field("id")}, WithVersionColumn("")),
+		},
+	})
+	key := dalrecord.NewKeyWithID("items", "i1")
+	ctx := ExpectVersion(context.Background(), 3)
+
+	mock.ExpectExec(`UPDATE items SET\s+Name = \$1,\s+version = version \+ \$2\s+WHERE id = \$3 AND version = \$4`).
+		WithArgs("x", 1, "i1", int64(3)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	err = db.Update(ctx, key, []update.Update{update.ByFieldName("Name", "x")})
+	var conflict *VersionConflictError
+	if !errors.As(err, &conflict) || conflict.Version != 3 {
+		t.Errorf("expected a conflict at version 3, got %v", err)
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRowVersion_SQLite(t *testing.T) {
+	options := DbOptions{
+		Recordsets: map[string]*Recordset{
+			"items": NewRecordset("items", Table, []dal.FieldRef{dal.Field("ID")}, WithVersionColumn("")),
+		},
+	}
+	sqlDB := openTestSQLiteDB(t, `CREATE TABLE items (ID TEXT PRIMARY KEY, Name TEXT, version INTEGER NOT NULL);`)
+	db := dal.BackendOf(NewDatabase(sqlDB, newSchema(), options)).(*database)
+	ctx := context.Background()
+
+	type item struct {
+		Name    string
+		Version int64
+	}
+	key := dalrecord.NewKeyWithID("items", "i1")
+	version := func(t *testing.T) (v int64) {
+		t.Helper()
+		if err := sqlDB.QueryRow(`SELECT version FROM items WHERE ID = 'i1'`).Scan(&v); err != nil {
+			t.Fatalf("SELECT: %v", err)
+		}
+		return v
+	}
+	isConflict := func(err error) bool { return errors.Is(err, ErrVersionConflict) }
+
+	t.Run("Insert", func(t *testing.T) {
+		data := &item{Name: "one", Version: 42}
+		if err := db.Insert(ctx, dalrecord.NewRecordWithData(key, data)); err != nil {
+			t.Fatalf("Insert: %v", err)
+		}
+		if v := version(t); v != 1 {
+			t.Errorf("version = %d, want 1", v)
+		}
+		if data.Version != 1 {
+			t.Errorf("record version = %d, want 1", data.Version)
+		}
+	})
+
+	t.Run("Set", func(t *testing.T) {
+		read := &item{}
+		if err := db.Get(ctx, dalrecord.NewRecordWithData(key, read)); err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if read.Version != 1 {
+			t.Fatalf("Get version = %d, want 1", read.Version)
+		}
+		stale := *read
+		read.Name = "two"
+		if err := db.Set(ctx, dalrecord.NewRecordWithData(key, read)); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+		if v := version(t); v != 2 || read.Version != 2 {
+			t.Errorf("version = %d, record version = %d; want 2", v, read.Version)
+		}
+		stale.Name = "lost update"
+		if err := db.Set(ctx, dalrecord.NewRecordWithData(key, &stale)); !isConflict(err) {
+			t.Errorf("expected a version conflict, got %v", err)
+		}
+		var name string
+		if err := sqlDB.QueryRow(`SELECT Name FROM items WHERE ID = 'i1'`).Scan(&name); err != nil || name != "two" {
+			t.Errorf("Name = %q, %v; want two", name, err)
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		updates := []update.Update{update.ByFieldName("Name", "three")}
+		if err := db.Update(ExpectVersion(ctx, 1), key, updates); !isConflict(err) {
+			t.Errorf("expected a version conflict, got %v", err)
+		}
+		if err := db.Update(ExpectVersion(ctx, 2), key, updates); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		if v := version(t); v != 3 {
+			t.Errorf("version = %d, want 3", v)
+		}
+		if err := db.Update(ctx, key, updates); err != nil {
+			t.Fatalf("Update without expected version: %v", err)
+		}
+		if v := version(t); v != 4 {
+			t.Errorf("version = %d, want 4", v)
+		}
+	})
+
+	t.Run("UpdateRecord", func(t *testing.T) {
+		data := &item{Version: 4}
+		err := db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx dal.ReadwriteTransaction) error {
+			return tx.UpdateRecord(ctx, dalrecord.NewRecordWithData(key, data), []update.Update{update.ByFieldName("Name", "four")})
+		})
+		if err != nil {
+			t.Fatalf("UpdateRecord: %v", err)
+		}
+		if v := version(t); v != 5 || data.Version != 5 {
+			t.Errorf("version = %d, record version = %d; want 5", v, data.Version)
+		}
+	})
+}
@@ -0,0 +1,150 @@
+package dalgo2sql
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/dal-go/dalgo/dal"
+	"github.com/dal-go/record/update"
+)
+
+// statementScope holds what is added to statements on a recordset
+// to confine them to the rows the caller may access.
+type statementScope struct {
+	// table is the name of the recordset's table as written in statements.
+	table string
+	// conditions are predicates with "?" placeholders that are ANDed to
+	// WHERE clauses, with args holding their arguments.
+	conditions []string
+	args       []any
+	// columns are assigned values by inserts.
+	columns []string
+	values  []any
+	// stamps are columns set by writes regardless of record data, except
+	// insertOnly columns that updates leave alone, e.g. audit columns.
+	stamps     []stamp
+	insertOnly []string
+}
+
+// scope returns the scope of statements on a recordset for the context.
+func (o DbOptions) scope(ctx context.Context, recordset string) (s statementScope, err error) {
+	s.table = recordset
+	if o.Tenancy != nil {
+		if err = o.Tenancy.apply(ctx, &s); err != nil {
+			return s, fmt.Errorf("failed to scope statement on %s: %w", recordset, err)
+		}
+	}
+	rs := o.Recordsets[recordset]
+	if err = rs.applyFilters(ctx, &s); err != nil {
+		return s, fmt.Errorf("failed to scope statement on %s: %w", recordset, err)
+	}
+	rs.applyExpiry(ctx, o.now(), &s)
+	if rs != nil && rs.audit != nil {
+		rs.audit.apply(ctx, o.now(), &s)
+	}
+	if rs != nil && rs.version != "" {
+		s.stamps = append(s.stamps, stamp{column: rs.version, onInsert: int64(1), onUpdate: Increment(1)})
+	}
+	applyRecordUpdates(ctx, recordset, &s)
+	return s, nil
+}
+
+// where returns the conditions to append to a WHERE clause, e.g. " AND tenant_id = ?".
+func (s statementScope) where() string {
+	if len(s.conditions) == 0 {
+		return ""
+	}
+	return " AND " + strings.Join(s.conditions, " AND ")
+}
+
+// shape identifies the scope in statement cache keys.
+func (s statementScope) shape() string {
+	return s.table + s.where()
+}
+
+// whereClause ANDs the scope conditions to a WHERE condition.
+func (s statementScope) whereClause(condition string) string {
+	if len(s.conditions) == 0 {
+		return condition
+	}
+	return "(" + condition + ")" + s.where()
+}
+
+// checkUpdates rejects updates of columns assigned by the scope,
+// e.g. moving a row to another tenant.
+func (s statementScope) checkUpdates(updates []update.Update) error {
+	for _, u := range updates {
+		column := u.FieldName()
+		if fieldPath := u.FieldPath(); len(fieldPath) > 0 {
+			column = fieldPath[0]
+		}
+		if _, ok := s.assigned(column); ok {
+			return fmt.Errorf("%w: update of scoped column %s", dal.ErrNotSupported, column)
+		}
+	}
+	return nil
+}
+
+// assigned reports whether inserts assign the column, and the value they assign.
+func (s statementScope) assigned(column string) (value any, ok bool) {
+	for i, c := range s.columns {
+		if strings.EqualFold(c, column) {
+			return s.values[i], true
+		}
+	}
+	return nil, false
+}
+
+// scopeQuery confines the text of a structured query on a recordset to its
+// scope by replacing the recordset in the FROM clause with a derived table
+// that applies the scope conditions. Structured queries inline their values,
+// so the arguments of the derived table are the only ones of the statement.
+func (o DbOptions) scopeQuery(ctx context.Context, recordset, text string) (string, []any, error) {
+	if recordset == "" {
+		return text, nil, nil
+	}
+	s, err := o.scope(ctx, recordset)
+	if err != nil {
+		return "", nil, err
+	}
+	if s.table == recordset && len(s.conditions) == 0 {
+		return text, nil, nil
+	}
+	loc := regexp.MustCompile(`(?i)\bFROM\s+` + regexp.QuoteMeta(recordset) + `\b`).FindStringIndex(text)
+	if loc == nil {
+		return "", nil, fmt.Errorf("failed to scope query on %s: FROM clause not found", recordset)
+	}
+	source := s.table
+	if len(s.conditions) > 0 {
+		source = o.Placeholder.rewritePlaceholders(
+			fmt.Sprintf("(SELECT * FROM %s WHERE %s)", s.table, strings.Join(s.conditions, " AND ")))
+	}
+	// Unless the query aliases the recordset, the alias keeps column
+	// references qualified with the recordset name working.
+	rest := text[loc[1]:]
+	if !hasAlias(rest) {
+		source += " " + recordset
+	}
+	start := loc[1] - len(recordset)
+	return text[:start] + source + rest, s.args, nil
+}
+
+// hasAlias reports whether the text following a table name in a FROM clause starts with an alias.
+func hasAlias(rest string) bool {
+	if rest == "" || (rest[0] != ' ' && rest[0] != '\t') {
+		return false
+	}
+	line, _, _ := strings.Cut(rest, "\n")
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return false
+	}
+	switch strings.ToUpper(fields[0]) {
+	case "WHERE", "GROUP", "ORDER", "HAVING", "LIMIT", "OFFSET", "UNION",
+		"JOIN", "INNER", "LEFT", "RIGHT", "FULL", "CROSS", "NATURAL", "ON", "USING":
+		return false
+	}
+	return !strings.HasPrefix(fields[0], ",") && !strings.HasPrefix(fields[0], ")")
+}
@@ -9,41 +9,102 @@ import (
 )
 
 func (dtb *database) Set(ctx context.Context, record dalrecord.Record) error {
-	return setSingle(ctx, dtb.options, record, dtb.db.Query, dtb.db.ExecContext)
+	ctx = withOperation(ctx, OpSet)
+	return dtb.write(ctx, recordsChange(false, record), func(query queryExecutor, exec statementExecutor) error {
+		return setSingle(ctx, dtb.options, record, query, exec)
+	})
 }
 
 func (t transaction) Set(ctx context.Context, record dalrecord.Record) error {
-	return setSingle(ctx, t.sqlOptions, record, t.tx.Query, t.tx.ExecContext)
+	ctx = withOperation(ctx, OpSet)
+	if t.buffer.accepts(ctx, record.Key()) {
+		return t.buffer.add(ctx, bufferedSet, record.Key(), record, nil)
+	}
+	return t.write(ctx, recordsChange(false, record), func(query queryExecutor, exec statementExecutor) error {
+		return setSingle(ctx, t.sqlOptions, record, query, exec)
+	})
 }
 
 func (dtb *database) SetMulti(ctx context.Context, records []dalrecord.Record) error {
-	err := dtb.RunReadwriteTransaction(ctx, func(ctx context.Context, tx dal.ReadwriteTransaction) error {
-		return setMulti(ctx, dtb.options, records, dtb.db.Query, dtb.db.ExecContext)
+	return dtb.RunReadwriteTransaction(withOperation(ctx, OpSetMulti), func(ctx context.Context, tx dal.ReadwriteTransaction) error {
+		return tx.SetMulti(ctx, records)
 	})
-	return err
-
 }
 
 func (t transaction) SetMulti(ctx context.Context, records []dalrecord.Record) error {
-	return setMulti(ctx, t.sqlOptions, records, t.tx.Query, t.tx.ExecContext)
+	ctx = withOperation(ctx, OpSetMulti)
+	if t.buffer.accepts(ctx, recordKeys(records)...) {
+		for _, record := range records {
+			if err := t.buffer.add(ctx, bufferedSet, record.Key(), record, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return t.write(ctx, recordsChange(false, records...), func(query queryExecutor, exec statementExecutor) error {
+		return setMulti(ctx, t.sqlOptions, records, query, exec)
+	})
 }
 
 func setSingle(ctx context.Context, options DbOptions, record dalrecord.Record, execQuery queryExecutor, exec statementExecutor) error {
 	key := record.Key()
-	exists, err := existsSingle(options, key, execQuery)
+	execQuery = options.traceQuery(key.Collection(), execQuery)
+	scope, err := options.scope(ctx, getRecordsetName(key))
+	if err != nil {
+		return err
+	}
+	exists, err := existsSingle(ctx, options, scope, key, execQuery)
 	if err != nil {
 		return fmt.Errorf("failed to check if record exists: %w", err)
 	}
+	if !exists {
+		if err = deleteExpiredOnInsert(ctx, options, record, exec); err != nil {
+			return err
+		}
+		// Set overwrites soft-deleted rows whatever the policy of inserts.
+		if restored, err := restoreAndOverwrite(ctx, options, record, exec); err != nil || restored {
+			if restored && err == nil {
+				options.count(ctx, key.Collection(), CounterInserts, 1)
+			}
+			return err
+		}
+	}
+	exec = options.traceStatement(key.Collection(), exec)
 	var o operation
 	if exists {
 		o = updateOperation
 	} else {
 		o = insertOperation
 	}
-	qry := buildSingleRecordQuery(o, options, record)
-	if _, err := exec(ctx, qry.text, qry.args...); err != nil {
+	versionColumn := options.versionColumn(getRecordsetName(key))
+	version, versioned := recordVersion(record, versionColumn)
+	if versioned && exists {
+		scope.expectVersion(versionColumn, version)
+	}
+	qry, err := buildScopedRecordQuery(o, options, scope, record)
+	if err != nil {
 		return err
 	}
+	result, err := exec(ctx, qry.text, qry.args...)
+	if err != nil {
+		if isUniqueViolation(err) {
+			options.count(ctx, key.Collection(), CounterConflicts, 1)
+		}
+		return err
+	}
+	if o == insertOperation {
+		options.count(ctx, key.Collection(), CounterInserts, 1)
+		setRecordVersion(record, versionColumn, 1)
+		return nil
+	}
+	if versioned {
+		if count, err := result.RowsAffected(); err == nil && count == 0 {
+			options.count(ctx, key.Collection(), CounterConflicts, 1)
+			return &VersionConflictError{Key: key, Version: version}
+		}
+		setRecordVersion(record, versionColumn, version+1)
+	}
+	options.count(ctx, key.Collection(), CounterUpdates, 1)
 	return nil
 }
 
@@ -57,18 +118,23 @@ func setMulti(ctx context.Context, options DbOptions, records []dalrecord.Record
 	return nil
 }
 
-func existsSingle(options DbOptions, key *dalrecord.Key, execQuery queryExecutor) (bool, error) {
-	collection := key.Collection()
+func existsSingle(ctx context.Context, options DbOptions, scope statementScope, key *dalrecord.Key, execQuery queryExecutor) (bool, error) {
 	pk := options.PrimaryKeyFieldNames(key)
-	var where string
-	if len(pk) == 1 {
-		where = pk[0] + " = " + options.Placeholder.placeholder(1)
-	} else {
+	if len(pk) != 1 {
 		return false, fmt.Errorf("%w: composite primary keys are not suported yet", dal.ErrNotImplementedYet)
 	}
-	// `SELECT 1` is not supported by some SQL drivers so select 1st column from primary key
-	queryText := fmt.Sprintf("SELECT %s FROM %s WHERE %s", pk[0], collection, where)
-	rows, err := execQuery(queryText, key.ID)
+	queryText := options.cachedSQL(
+		func() string { return statementShape("existsPK", scope.shape(), pk[0]) },
+		func() string {
+			// `SELECT 1` is not supported by some SQL drivers so select 1st column from primary key
+			return options.Placeholder.rewritePlaceholders(
+				fmt.Sprintf("SELECT %s FROM %s WHERE %s = ?%s", pk[0], scope.table, pk[0], scope.where()))
+		})
+	args, err := primaryKeyArgs(newColumnMapper(options, getRecordsetName(key)), pk, key)
+	if err != nil {
+		return false, err
+	}
+	rows, err := execQuery(ctx, queryText, append(args, scope.args...)...)
 	if err != nil {
 		return false, err
 	}
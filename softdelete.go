@@ -0,0 +1,246 @@
+package dalgo2sql
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dal-go/dalgo/dal"
+	"github.com/dal-go/record"
+)
+
+// DefaultSoftDeleteColumn is used when SoftDelete.Column is not set.
+const DefaultSoftDeleteColumn = "deleted_at"
+
+// SoftDeleteFilter is the name of the recordset filter that hides
+// soft-deleted rows. WithoutFilters(ctx, SoftDeleteFilter) makes them visible.
+const SoftDeleteFilter = "soft_delete"
+
+// SoftDeletedInsertPolicy decides what inserting a record does when its key
+// belongs to a soft-deleted row.
+type SoftDeletedInsertPolicy int
+
+const (
+	// SoftDeletedInsertFails makes the insert fail as the key is taken.
+	SoftDeletedInsertFails SoftDeletedInsertPolicy = iota
+	// SoftDeletedInsertRestores restores the soft-deleted row and overwrites
+	// it with the columns of the inserted record.
+	SoftDeletedInsertRestores
+)
+
+// SoftDelete configures soft deletion of the rows of a recordset, see WithSoftDelete.
+type SoftDelete struct {
+	// Column holds the time a row was deleted, NULL for live rows.
+	// Empty uses DefaultSoftDeleteColumn.
+	Column   string
+	OnInsert SoftDeletedInsertPolicy
+}
+
+func (sd *SoftDelete) column() string {
+	if sd.Column == "" {
+		return DefaultSoftDeleteColumn
+	}
+	return sd.Column
+}
+
+// WithSoftDelete makes Delete, DeleteMulti and DeleteWhere set the deleted-at
+// column of rows to DbOptions.Clock instead of deleting them, and adds the
+// SoftDeleteFilter recordset filter so soft-deleted rows are not found by
+// Get, Exists and queries. Rows are restored or deleted for good with the
+// Restore and Purge methods of the database and its transactions.
+// Set on the key of a soft-deleted row restores the row and overwrites it
+// with the record, whatever the SoftDeletedInsertPolicy.
+func WithSoftDelete(sd SoftDelete) RecordsetOption {
+	return func(rs *Recordset) {
+		rs.softDelete = &sd
+		column := sd.column()
+		WithFilter(SoftDeleteFilter, StaticFilter(
+			dal.Comparison{Operator: dal.Equal, Left: dal.Field(column), Right: dal.Constant{Value: nil}},
+		))(rs)
+	}
+}
+
+// SoftDeleteSession is implemented by the database (see dal.BackendOf) and the
+// transactions of this adapter.
+type SoftDeleteSession interface {
+	// Restore undeletes soft-deleted records.
+	Restore(ctx context.Context, keys ...*record.Key) error
+	// Purge deletes records for good, whether they are soft-deleted or not.
+	Purge(ctx context.Context, keys ...*record.Key) error
+}
+
+var _ SoftDeleteSession = (*database)(nil)
+var _ SoftDeleteSession = (*transaction)(nil)
+
+func (dtb *database) Restore(ctx context.Context, keys ...*record.Key) error {
+	ctx = withOperation(ctx, OpRestore)
+	return dtb.write(ctx, keysChange(keys...), func(_ queryExecutor, exec statementExecutor) error {
+		return restoreMulti(ctx, dtb.options, keys, exec)
+	})
+}
+
+func (t transaction) Restore(ctx context.Context, keys ...*record.Key) error {
+	ctx = withOperation(ctx, OpRestore)
+	return t.write(ctx, keysChange(keys...), func(_ queryExecutor, exec statementExecutor) error {
+		return restoreMulti(ctx, t.sqlOptions, keys, exec)
+	})
+}
+
+func (dtb *database) Purge(ctx context.Context, keys ...*record.Key) error {
+	ctx = withPurge(withOperation(ctx, OpPurge))
+	return dtb.write(ctx, keysChange(keys...), func(_ queryExecutor, exec statementExecutor) error {
+		return deleteMulti(ctx, dtb.options, keys, exec)
+	})
+}
+
+func (t transaction) Purge(ctx context.Context, keys ...*record.Key) error {
+	ctx = withPurge(withOperation(ctx, OpPurge))
+	return t.write(ctx, keysChange(keys...), func(_ queryExecutor, exec statementExecutor) error {
+		return deleteMulti(ctx, t.sqlOptions, keys, exec)
+	})
+}
+
+// now returns the current time of DbOptions.Clock.
+func (o DbOptions) now() time.Time {
+	if o.Clock != nil {
+		return o.Clock()
+	}
+	return time.Now()
+}
+
+type purgeContextKey struct{}
+
+// withPurge makes deletes remove rows of soft-deleted recordsets for good,
+// including rows that have already been soft-deleted.
+func withPurge(ctx context.Context) context.Context {
+	return WithoutFilters(context.WithValue(ctx, purgeContextKey{}, true), SoftDeleteFilter)
+}
+
+// softDeleteColumn returns the deleted-at column if deletes of the recordset
+// are soft in the context, or an empty string.
+func (o DbOptions) softDeleteColumn(ctx context.Context, recordset string) string {
+	rs := o.Recordsets[recordset]
+	if rs == nil || rs.softDelete == nil {
+		return ""
+	}
+	if purge, _ := ctx.Value(purgeContextKey{}).(bool); purge {
+		return ""
+	}
+	return rs.softDelete.column()
+}
+
+// deleteStatement renders a statement deleting the rows of a scope that
+// match where, with "?" placeholders: a DELETE, or an UPDATE of the
+// deleted-at column if it is not empty. See deleteArgs.
+func deleteStatement(scope statementScope, column, where string) string {
+	if column != "" {
+		return fmt.Sprintf("UPDATE %s SET %s = ? WHERE %s", scope.table, column, where)
+	}
+	//goland:noinspection SqlNoDataSourceInspection
+	return fmt.Sprintf("DELETE FROM %s WHERE %s", scope.table, where)
+}
+
+// deleteArgs returns the arguments of a deleteStatement that precede the arguments of its WHERE clause.
+func (o DbOptions) deleteArgs(column string) []any {
+	if column != "" {
+		return []any{o.now()}
+	}
+	return nil
+}
+
+func restoreMulti(ctx context.Context, options DbOptions, keys []*record.Key, exec statementExecutor) error {
+	for i, key := range keys {
+		if err := restoreSingle(ctx, options, key, exec); err != nil {
+			return fmt.Errorf("failed to restore record #%d of %d: %w", i+1, len(keys), err)
+		}
+	}
+	return nil
+}
+
+func restoreSingle(ctx context.Context, options DbOptions, key *record.Key, exec statementExecutor) error {
+	collection := key.Collection()
+	rs := options.Recordsets[collection]
+	if rs == nil || rs.softDelete == nil {
+		return fmt.Errorf("%w: restore of %s that is not soft-deleted", dal.ErrNotSupported, collection)
+	}
+	_, err := execRestore(WithoutFilters(ctx, SoftDeleteFilter), options, collection, rs.softDelete.column(), key, exec)
+	return err
+}
+
+// execRestore clears the deleted-at column of a soft-deleted row
+// and reports whether there was such a row.
+func execRestore(ctx context.Context, options DbOptions, collection, column string, key *record.Key, exec statementExecutor) (bool, error) {
+	scope, err := options.scope(ctx, collection)
+	if err != nil {
+		return false, err
+	}
+	pk := options.PrimaryKeyFieldNames(key)
+	if len(pk) == 0 {
+		return false, fmt.Errorf("primary key is not defined for %s", collection)
+	}
+	args, err := primaryKeyArgs(newColumnMapper(options, collection), pk, key)
+	if err != nil {
+		return false, err
+	}
+	conditions := make([]string, len(pk))
+	for i, name := range pk {
+		conditions[i] = name + " = ?"
+	}
+	args = append(args, scope.args...)
+	text := options.cachedSQL(
+		func() string { return statementShape("restore", scope.shape(), column, strings.Join(conditions, ",")) },
+		func() string {
+			return options.Placeholder.rewritePlaceholders(fmt.Sprintf("UPDATE %s SET %s = NULL WHERE %s AND %s IS NOT NULL%s",
+				scope.table, column, strings.Join(conditions, " AND "), column, scope.where()))
+		})
+	result, err := options.traceStatement(collection, exec)(ctx, text, args...)
+	if err != nil {
+		return false, fmt.Errorf("failed to restore %s: %w", key, err)
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	options.count(ctx, collection, CounterUpdates, count)
+	return count > 0, nil
+}
+
+// restoreOnInsert restores a soft-deleted row with the key of a record to be
+// inserted and overwrites it with the record if the recordset's policy is
+// SoftDeletedInsertRestores. It reports whether the record has been written.
+func restoreOnInsert(ctx context.Context, options DbOptions, record record.Record, exec statementExecutor) (bool, error) {
+	rs := options.Recordsets[getRecordsetName(record.Key())]
+	if rs == nil || rs.softDelete == nil || rs.softDelete.OnInsert != SoftDeletedInsertRestores {
+		return false, nil
+	}
+	return restoreAndOverwrite(ctx, options, record, exec)
+}
+
+// restoreAndOverwrite restores a soft-deleted row with the key of a record
+// and overwrites it with the record. It reports whether there was such a row.
+func restoreAndOverwrite(ctx context.Context, options DbOptions, record record.Record, exec statementExecutor) (bool, error) {
+	key := record.Key()
+	collection := getRecordsetName(key)
+	rs := options.Recordsets[collection]
+	if key.ID == nil || rs == nil || rs.softDelete == nil {
+		return false, nil
+	}
+	ctx = WithoutFilters(ctx, SoftDeleteFilter)
+	restored, err := execRestore(ctx, options, collection, rs.softDelete.column(), key, exec)
+	if err != nil || !restored {
+		return false, err
+	}
+	scope, err := options.scope(ctx, collection)
+	if err != nil {
+		return true, err
+	}
+	q, err := buildScopedRecordQuery(updateOperation, options, scope, record)
+	if err != nil {
+		return true, err
+	}
+	if _, err = options.traceStatement(collection, exec)(ctx, q.text, q.args...); err != nil {
+		return true, err
+	}
+	return true, nil
+}
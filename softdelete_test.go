@@ -0,0 +1,224 @@
+package dalgo2sql
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dal-go/dalgo/dal"
+	dalrecord "github.com/dal-go/record"
+)
+
+func TestSoftDelete_DollarPlaceholders(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeDatabase(t, sqlDB)
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	db := dal.BackendOf(NewDatabase(sqlDB, newSchema(), DbOptions{
+		Placeholder: PlaceholderDollar,
+		Clock:       func() time.Time { return now },
+		Recordsets: map[string]*Recordset{
+			"users": NewRecordset("users", Table, []dal.FieldRef{dal.Field("id")}, WithSoftDelete(SoftDelete{})),
+		},
+	})).(*database)
+	ctx := context.Background()
+	key := dalrecord.NewKeyWithID("users", "u1")
+
+	mock.ExpectExec(`UPDATE users SET deleted_at = \$1 WHERE id = \$2 AND deleted_at IS NULL`).
+		WithArgs(now, "u1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if err = db.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	mock.ExpectExec(`UPDATE users SET deleted_at = NULL WHERE id = \$1 AND deleted_at IS NOT NULL`).
+		WithArgs("u1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if err = db.Restore(ctx, key); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+
+	mock.ExpectExec(`DELETE FROM users WHERE id = \$1$`).
+		WithArgs("u1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if err = db.Purge(ctx, key); err != nil {
+		t.Fatalf("Purge: %v", err)
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSoftDelete_SQLite(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	options := DbOptions{
+		Clock: func() time.Time { return now },
+		Recordsets: map[string]*Recordset{
+			"items": NewRecordset("items", Table, []dal.FieldRef{dal.Field("ID")}, WithSoftDelete(SoftDelete{})),
+			"notes": NewRecordset("notes", Table, []dal.FieldRef{dal.Field("ID")},
+				WithSoftDelete(SoftDelete{Column: "removed_at", OnInsert: SoftDeletedInsertRestores})),
+			"logs": NewRecordset("logs", Table, []dal.FieldRef{dal.Field("ID")}),
+		},
+	}
+	sqlDB := openTestSQLiteDB(t, `
+		CREATE TABLE items (ID TEXT PRIMARY KEY, Name TEXT, deleted_at TIMESTAMP);
+		CREATE TABLE notes (ID TEXT PRIMARY KEY, Name TEXT, removed_at TIMESTAMP);
+		CREATE TABLE logs (ID TEXT PRIMARY KEY, Name TEXT);
+		INSERT INTO items (ID, Name) VALUES ('i1', 'one'), ('i2', 'two'), ('i3', 'three'), ('i4', 'four');
+		INSERT INTO notes (ID, Name) VALUES ('n1', 'old');`)
+	db := dal.BackendOf(NewDatabase(sqlDB, newSchema(), options)).(*database)
+	ctx := context.Background()
+
+	type item struct {
+		Name string
+	}
+	key := func(id string) *dalrecord.Key { return dalrecord.NewKeyWithID("items", id) }
+	deleted := func(t *testing.T, table, column, id string) bool {
+		t.Helper()
+		var deletedAt any
+		if err := sqlDB.QueryRow(`SELECT `+column+` FROM `+table+` WHERE ID = ?`, id).Scan(&deletedAt); err != nil {
+			t.Fatalf("SELECT %s: %v", id, err)
+		}
+		return deletedAt != nil
+	}
+	count := func(t *testing.T, table string) (n int) {
+		t.Helper()
+		if err := sqlDB.QueryRow(`SELECT COUNT(*) FROM ` + table).Scan(&n); err != nil {
+			t.Fatalf("COUNT: %v", err)
+		}
+		return n
+	}
+	isNotFound := func(err error) bool { return errors.Is(err, dalrecord.ErrRecordNotFound) }
+
+	t.Run("Delete", func(t *testing.T) {
+		if err := db.Delete(ctx, key("i1")); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		if err := db.DeleteMulti(ctx, []*dalrecord.Key{key("i2"), key("i1")}); err != nil {
+			t.Fatalf("DeleteMulti: %v", err)
+		}
+		three := dal.Comparison{Operator: dal.Equal, Left: dal.Field("Name"), Right: dal.Constant{Value: "three"}}
+		if n, err := db.DeleteWhere(ctx, "items", three); err != nil || n != 1 {
+			t.Errorf("DeleteWhere = %d, %v; want 1", n, err)
+		}
+		for id, want := range map[string]bool{"i1": true, "i2": true, "i3": true, "i4": false} {
+			if got := deleted(t, "items", "deleted_at", id); got != want {
+				t.Errorf("%s: deleted = %v, want %v", id, got, want)
+			}
+		}
+		if n := count(t, "items"); n != 4 {
+			t.Errorf("%d rows, want soft-deleted rows to be kept", n)
+		}
+	})
+
+	t.Run("reads", func(t *testing.T) {
+		if err := db.Get(ctx, dalrecord.NewRecordWithData(key("i1"), &item{})); !isNotFound(err) {
+			t.Errorf("Get: expected not found, got %v", err)
+		}
+		if exists, err := db.Exists(ctx, key("i1")); err != nil || exists {
+			t.Errorf("Exists = %v, %v; want false", exists, err)
+		}
+		if err := db.Get(WithoutFilters(ctx, SoftDeleteFilter), dalrecord.NewRecordWithData(key("i1"), &item{})); err != nil {
+			t.Errorf("Get without filter: %v", err)
+		}
+		q := dal.NewQueryBuilder(dal.From(dal.NewRootCollectionRef("items", ""))).SelectIntoRecordset()
+		reader, err := db.ExecuteQueryToRecordsetReader(ctx, q)
+		if err != nil {
+			t.Fatalf("ExecuteQueryToRecordsetReader: %v", err)
+		}
+		defer func() { _ = reader.Close() }()
+		var n int
+		for ; err == nil; n++ {
+			_, _, err = reader.Next()
+		}
+		if !errors.Is(err, dal.ErrNoMoreRecords) {
+			t.Fatalf("Next: %v", err)
+		}
+		if n-1 != 1 {
+			t.Errorf("read %d rows, want 1", n-1)
+		}
+	})
+
+	t.Run("insert_fails", func(t *testing.T) {
+		if err := db.Insert(ctx, dalrecord.NewRecordWithData(key("i1"), &item{Name: "again"})); err == nil {
+			t.Error("expected insert over a soft-deleted row to fail")
+		}
+	})
+
+	t.Run("set_restores", func(t *testing.T) {
+		// items fail inserts over soft-deleted rows, Set restores them anyway.
+		if err := db.Set(ctx, dalrecord.NewRecordWithData(key("i3"), &item{Name: "set"})); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+		if deleted(t, "items", "deleted_at", "i3") {
+			t.Error("expected i3 to be restored")
+		}
+		got := &item{}
+		if err := db.Get(ctx, dalrecord.NewRecordWithData(key("i3"), got)); err != nil || got.Name != "set" {
+			t.Errorf("Get = %q, %v; want set", got.Name, err)
+		}
+		if n := count(t, "items"); n != 4 {
+			t.Errorf("%d rows, want Set to write the soft-deleted row", n)
+		}
+	})
+
+	t.Run("insert_restores", func(t *testing.T) {
+		noteKey := dalrecord.NewKeyWithID("notes", "n1")
+		if err := db.Delete(ctx, noteKey); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		if !deleted(t, "notes", "removed_at", "n1") {
+			t.Fatal("expected n1 to be soft-deleted")
+		}
+		if err := db.Insert(ctx, dalrecord.NewRecordWithData(noteKey, &item{Name: "new"})); err != nil {
+			t.Fatalf("Insert: %v", err)
+		}
+		note := &item{}
+		if err := db.Get(ctx, dalrecord.NewRecordWithData(noteKey, note)); err != nil || note.Name != "new" {
+			t.Errorf("Get = %q, %v; want new", note.Name, err)
+		}
+		if err := db.Delete(ctx, noteKey); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		if err := db.Set(ctx, dalrecord.NewRecordWithData(noteKey, &item{Name: "set"})); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+		if err := db.Get(ctx, dalrecord.NewRecordWithData(noteKey, note)); err != nil || note.Name != "set" {
+			t.Errorf("Get = %q, %v; want set", note.Name, err)
+		}
+		if n := count(t, "notes"); n != 1 {
+			t.Errorf("%d notes, want 1", n)
+		}
+	})
+
+	t.Run("Restore", func(t *testing.T) {
+		if err := db.Restore(ctx, key("i2")); err != nil {
+			t.Fatalf("Restore: %v", err)
+		}
+		if deleted(t, "items", "deleted_at", "i2") {
+			t.Error("expected i2 to be restored")
+		}
+		if err := db.Get(ctx, dalrecord.NewRecordWithData(key("i2"), &item{})); err != nil {
+			t.Errorf("Get: %v", err)
+		}
+		if err := db.Restore(ctx, dalrecord.NewKeyWithID("logs", "l1")); !errors.Is(err, dal.ErrNotSupported) {
+			t.Errorf("expected ErrNotSupported for a recordset without soft delete, got %v", err)
+		}
+	})
+
+	t.Run("Purge", func(t *testing.T) {
+		err := db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx dal.ReadwriteTransaction) error {
+			return tx.(SoftDeleteSession).Purge(ctx, key("i1"), key("i4"))
+		})
+		if err != nil {
+			t.Fatalf("Purge: %v", err)
+		}
+		if n := count(t, "items"); n != 2 {
+			t.Errorf("%d rows, want the soft-deleted and the live row to be purged", n)
+		}
+	})
+}
@@ -2,6 +2,7 @@ package dalgo2sql
 
 import (
 	"fmt"
+	"github.com/dal-go/dalgo/dal"
 	dalrecord "github.com/dal-go/record"
 	"reflect"
 	"slices"
@@ -45,6 +46,10 @@ func processPrimaryKey(primaryKey []string, key *dalrecord.Key, f func(i int, na
 			v = id[i]
 		case []time.Time:
 			v = id[i]
+		case []any:
+			v = id[i]
+		case []dalrecord.FieldVal:
+			v = fieldValByName(id, pk, i)
 		default:
 			panic(fmt.Sprintf("unsupported type for primary key value %T", id))
 		}
@@ -52,18 +57,83 @@ func processPrimaryKey(primaryKey []string, key *dalrecord.Key, f func(i int, na
 	}
 }
 
-func buildSingleRecordQuery(o operation, options DbOptions, record dalrecord.Record) (query query) {
+// fieldValByName returns the value of a composite key field matching the
+// primary key column by name, falling back to the field at the same position.
+func fieldValByName(fields []dalrecord.FieldVal, name string, i int) any {
+	for _, f := range fields {
+		if strings.EqualFold(f.Name, name) {
+			return f.Value
+		}
+	}
+	return fields[i].Value
+}
+
+func buildSingleRecordQuery(o operation, options DbOptions, record dalrecord.Record) (query query, err error) {
+	return buildScopedRecordQuery(o, options, statementScope{table: getRecordsetName(record.Key())}, record)
+}
+
+// buildScopedRecordQuery builds an INSERT or UPDATE statement of a record
+// confined to scope: inserts assign the scope columns and updates
+// are conditioned on them. Both set the scope stamps.
+func buildScopedRecordQuery(o operation, options DbOptions, scope statementScope, record dalrecord.Record) (query query, err error) {
 	key := record.Key()
 	collection := getRecordsetName(key)
 	pk := options.PrimaryKeyFieldNames(key)
+	cols, argPlaceholders, args, err := scopedRecordValues(o, options, scope, record)
+	if err != nil {
+		return query, err
+	}
+	query.args = args
 	switch o {
 	case insertOperation:
-		query.text = "INSERT INTO " + collection
+		query.text = options.cachedSQL(
+			func() string { return statementShape("insert", scope.table, strings.Join(cols, ",")) },
+			func() string {
+				// Rewrite "?" placeholders to the dialect-specific form (e.g. "$1" for Postgres).
+				return options.Placeholder.rewritePlaceholders(fmt.Sprintf("INSERT INTO %v(%v) VALUES (%v)",
+					scope.table,
+					strings.Join(cols, ", "),
+					strings.Join(argPlaceholders, ", "),
+				))
+			})
 	case updateOperation:
-		query.text = fmt.Sprintf("UPDATE %v SET ", collection)
+		if len(argPlaceholders) == 0 {
+			panic(fmt.Sprintf("no fields to updateOperation for: '%s'", collection))
+		}
+		keyArgs, err := primaryKeyArgs(newColumnMapper(options, collection), pk, key)
+		if err != nil {
+			return query, err
+		}
+		pkConditions := make([]string, len(pk))
+		for i, name := range pk {
+			pkConditions[i] = name + " = ?"
+		}
+		query.args = append(query.args, keyArgs...)
+		query.args = append(query.args, scope.args...)
+		query.text = options.cachedSQL(
+			func() string {
+				return statementShape("set", scope.shape(), strings.Join(argPlaceholders, ","), strings.Join(pkConditions, ","))
+			},
+			func() string {
+				return options.Placeholder.rewritePlaceholders(fmt.Sprintf("UPDATE %v SET  %v WHERE %v%v",
+					scope.table,
+					strings.Join(argPlaceholders, ", "),
+					strings.Join(pkConditions, " AND "),
+					scope.where(),
+				))
+			})
 	}
-	var cols []string
-	var argPlaceholders []string
+	return query, nil
+}
+
+// scopedRecordValues returns the columns a write of a record assigns, their
+// "?" placeholder expressions, or "column = ?" assignments for updates, and
+// the arguments of the placeholders.
+func scopedRecordValues(o operation, options DbOptions, scope statementScope, record dalrecord.Record) (cols, argPlaceholders []string, args []any, err error) {
+	key := record.Key()
+	collection := getRecordsetName(key)
+	pk := options.PrimaryKeyFieldNames(key)
+	mapper := newColumnMapper(options, collection)
 	record.SetError(nil)
 	data := record.Data()
 	val := reflect.ValueOf(data)
@@ -75,35 +145,51 @@ func buildSingleRecordQuery(o operation, options DbOptions, record dalrecord.Rec
 		if len(pk) == 0 {
 			panic(fmt.Sprintf("record key has value but no primary key defined for: '%s'", collection))
 		}
-		processPrimaryKey(pk, key, func(i int, name string, v any) {
+		keyArgs, err := primaryKeyArgs(mapper, pk, key)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		for i, name := range pk {
 			cols = append(cols, name)
-			query.args = append(query.args, v)
+			args = append(args, keyArgs[i])
 			argPlaceholders = append(argPlaceholders, "?")
-		})
+		}
 	}
 
-	setColsCount := 0
-
-	addField := func(name string, value any) {
-		if slices.Contains(pk, name) {
-			return
+	addField := func(name string, field *reflect.StructField, value any) error {
+		if slices.Contains(pk, name) || scope.ignores(o, name) {
+			return nil
+		}
+		if scoped, ok := scope.assigned(name); ok {
+			// The scope assigns the column, so the record may only leave it empty or agree.
+			if v := reflect.ValueOf(value); v.IsValid() && !v.IsZero() && !reflect.DeepEqual(value, scoped) {
+				return fmt.Errorf("%w: %s of record %s is %v, expected %v", dal.ErrNotSupported, name, key, value, scoped)
+			}
+			return nil
+		}
+		value, err := mapper.toSQL(name, field, value)
+		if err != nil {
+			return err
 		}
 		cols = append(cols, name)
-		query.args = append(query.args, value)
+		args = append(args, value)
 		switch o {
 		case insertOperation:
 			argPlaceholders = append(argPlaceholders, "?")
 		case updateOperation:
 			argPlaceholders = append(argPlaceholders, name+" = ?")
-			setColsCount++
 		}
+		return nil
 	}
 
 	switch val.Kind() {
 	case reflect.Struct:
 		valType := val.Type()
 		for i := 0; i < val.NumField(); i++ {
-			addField(valType.Field(i).Name, val.Field(i).Interface())
+			field := valType.Field(i)
+			if err = addField(field.Name, &field, val.Field(i).Interface()); err != nil {
+				return nil, nil, nil, err
+			}
 		}
 	case reflect.Map:
 		if val.Type().Key().Kind() != reflect.String {
@@ -117,31 +203,36 @@ func buildSingleRecordQuery(o operation, options DbOptions, record dalrecord.Rec
 		sort.Strings(names)
 		for _, name := range names {
 			v := val.MapIndex(reflect.ValueOf(name))
-			addField(name, v.Interface())
+			if err = addField(name, nil, v.Interface()); err != nil {
+				return nil, nil, nil, err
+			}
 		}
 	default:
 		panic(fmt.Sprintf("unsupported record data kind %s for collection '%s': expected struct or map[string]any", val.Kind(), collection))
 	}
+	stampColumns, stampValues := scope.stampsOf(o)
+	for i, name := range stampColumns {
+		// Stamps may be expressions like Increment, rendered as in Update.
+		expr, exprArgs, err := buildValueExpr(mapper, name, stampValues[i])
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		cols = append(cols, name)
+		args = append(args, exprArgs...)
+		switch o {
+		case insertOperation:
+			argPlaceholders = append(argPlaceholders, expr)
+		case updateOperation:
+			argPlaceholders = append(argPlaceholders, name+" = "+expr)
+		}
+	}
 
-	switch o {
-	case insertOperation:
-		query.text += fmt.Sprintf("(%v) VALUES (%v)",
-			strings.Join(cols, ", "),
-			strings.Join(argPlaceholders, ", "),
-		)
-	case updateOperation:
-		if setColsCount == 0 {
-			panic(fmt.Sprintf("no fields to updateOperation for: '%s'", collection))
+	if o == insertOperation {
+		for i, name := range scope.columns {
+			cols = append(cols, name)
+			args = append(args, scope.values[i])
+			argPlaceholders = append(argPlaceholders, "?")
 		}
-		var pkConditions []string
-		processPrimaryKey(pk, key, func(i int, name string, v any) {
-			pkConditions = append(pkConditions, name+" = ?")
-			query.args = append(query.args, v)
-		})
-		query.text += " " + strings.Join(argPlaceholders, ", ") +
-			fmt.Sprintf(" WHERE %v", strings.Join(pkConditions, " AND "))
 	}
-	// Rewrite "?" placeholders to the dialect-specific form (e.g. "$1" for Postgres).
-	query.text = options.Placeholder.rewritePlaceholders(query.text)
-	return query
+	return cols, argPlaceholders, args, nil
 }
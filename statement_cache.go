@@ -0,0 +1,271 @@
+package dalgo2sql
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"strings"
+	"sync"
+)
+
+// DefaultSQLCacheSize is used when DbOptions.SQLCacheSize is not set.
+const DefaultSQLCacheSize = 512
+
+// lruCache is a map bounded to capacity entries that evicts the least recently used one.
+// It is not safe for concurrent use.
+type lruCache[K comparable, V any] struct {
+	capacity int
+	order    *list.List // of *lruEntry[K, V], most recently used first
+	items    map[K]*list.Element
+	onEvict  func(key K, value V)
+}
+
+type lruEntry[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+func newLRUCache[K comparable, V any](capacity int, onEvict func(key K, value V)) *lruCache[K, V] {
+	return &lruCache[K, V]{
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[K]*list.Element, capacity),
+		onEvict:  onEvict,
+	}
+}
+
+func (c *lruCache[K, V]) get(key K) (value V, ok bool) {
+	e, ok := c.items[key]
+	if !ok {
+		return value, false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(*lruEntry[K, V]).value, true
+}
+
+func (c *lruCache[K, V]) add(key K, value V) {
+	if e, ok := c.items[key]; ok {
+		c.order.MoveToFront(e)
+		e.Value.(*lruEntry[K, V]).value = value
+		return
+	}
+	c.items[key] = c.order.PushFront(&lruEntry[K, V]{key: key, value: value})
+	for c.order.Len() > c.capacity {
+		c.removeElement(c.order.Back())
+	}
+}
+
+func (c *lruCache[K, V]) remove(key K) {
+	if e, ok := c.items[key]; ok {
+		c.removeElement(e)
+	}
+}
+
+func (c *lruCache[K, V]) removeElement(e *list.Element) {
+	entry := c.order.Remove(e).(*lruEntry[K, V])
+	delete(c.items, entry.key)
+	if c.onEvict != nil {
+		c.onEvict(entry.key, entry.value)
+	}
+}
+
+func (c *lruCache[K, V]) len() int {
+	return c.order.Len()
+}
+
+// statementCache caches SQL texts of hot CRUD statements by statement shape
+// (operation, recordset, columns...) and, optionally, statements prepared
+// for those texts on each *sql.DB (the primary and the read replicas).
+//
+// Only texts present in the SQL text cache are prepared, so ad-hoc
+// statements such as DeleteWhere or queries never occupy the statement cache.
+type statementCache struct {
+	mu sync.Mutex
+	// texts maps statement shapes to SQL texts.
+	texts *lruCache[string, string]
+	// textShapes counts the shapes in texts producing each SQL text.
+	textShapes map[string]int
+	// stmtCacheSize bounds prepared statements per *sql.DB, 0 if disabled.
+	stmtCacheSize int
+	// stmts maps SQL texts to statements prepared on a *sql.DB.
+	stmts map[*sql.DB]*lruCache[string, *cachedStmt]
+}
+
+type cachedStmt struct {
+	stmt    *sql.Stmt
+	users   int
+	evicted bool
+}
+
+// newStatementCache returns nil if SQL text caching is disabled.
+func newStatementCache(options DbOptions) *statementCache {
+	textCacheSize := options.SQLCacheSize
+	if textCacheSize == 0 {
+		textCacheSize = DefaultSQLCacheSize
+	}
+	if textCacheSize < 0 {
+		return nil
+	}
+	c := &statementCache{
+		textShapes:    make(map[string]int),
+		stmtCacheSize: max(0, options.StatementCacheSize),
+		stmts:         make(map[*sql.DB]*lruCache[string, *cachedStmt]),
+	}
+	c.texts = newLRUCache(textCacheSize, func(_ string, text string) {
+		if c.textShapes[text]--; c.textShapes[text] <= 0 {
+			delete(c.textShapes, text)
+			for _, stmts := range c.stmts {
+				stmts.remove(text)
+			}
+		}
+	})
+	return c
+}
+
+// preparedOn returns the prepared statements of db, creating the cache on first use.
+// The caller must hold c.mu.
+func (c *statementCache) preparedOn(db *sql.DB) *lruCache[string, *cachedStmt] {
+	stmts := c.stmts[db]
+	if stmts == nil {
+		stmts = newLRUCache(c.stmtCacheSize, func(_ string, e *cachedStmt) {
+			e.evicted = true
+			if e.users == 0 {
+				// Closing waits for rows read from the statement to be closed.
+				go func() { _ = e.stmt.Close() }()
+			}
+		})
+		c.stmts[db] = stmts
+	}
+	return stmts
+}
+
+// statementShape builds a statement cache key from the parts that determine the SQL text.
+func statementShape(parts ...string) string {
+	return strings.Join(parts, "\x00")
+}
+
+// cachedSQL returns the SQL text of a statement shape, calling build on a
+// cache miss or if the cache is disabled.
+func (o DbOptions) cachedSQL(shape func() string, build func() string) string {
+	c := o.statements
+	if c == nil {
+		return build()
+	}
+	key := shape()
+	c.mu.Lock()
+	text, ok := c.texts.get(key)
+	c.mu.Unlock()
+	if ok {
+		return text
+	}
+	text = build()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok = c.texts.get(key); !ok {
+		c.texts.add(key, text)
+		c.textShapes[text]++
+	}
+	return text
+}
+
+// acquire returns a statement prepared on db for text if text is a cached
+// SQL text and the statement cache is enabled. The release function must be
+// called once the statement has been executed.
+func (c *statementCache) acquire(ctx context.Context, db *sql.DB, text string) (stmt *sql.Stmt, release func(), ok bool) {
+	if c == nil || c.stmtCacheSize == 0 {
+		return nil, nil, false
+	}
+	c.mu.Lock()
+	if c.textShapes[text] == 0 {
+		c.mu.Unlock()
+		return nil, nil, false
+	}
+	if e, ok := c.preparedOn(db).get(text); ok {
+		e.users++
+		c.mu.Unlock()
+		return e.stmt, c.releaser(e), true
+	}
+	c.mu.Unlock()
+
+	prepared, err := db.PrepareContext(ctx, text)
+	if err != nil {
+		// Executing the statement unprepared reports the error, if it persists.
+		return nil, nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.textShapes[text] == 0 { // evicted while preparing
+		go func() { _ = prepared.Close() }()
+		return nil, nil, false
+	}
+	stmts := c.preparedOn(db)
+	if e, ok := stmts.get(text); ok { // prepared concurrently
+		go func() { _ = prepared.Close() }()
+		e.users++
+		return e.stmt, c.releaser(e), true
+	}
+	e := &cachedStmt{stmt: prepared, users: 1}
+	stmts.add(text, e)
+	return e.stmt, c.releaser(e), true
+}
+
+func (c *statementCache) releaser(e *cachedStmt) func() {
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if e.users--; e.users == 0 && e.evicted {
+			go func() { _ = e.stmt.Close() }()
+		}
+	}
+}
+
+// newQueryExecutor returns a queryExecutor that runs cached SQL texts as
+// statements prepared on db, rebound to tx if it is not nil. tx must have
+// been started on db.
+func newQueryExecutor(c *statementCache, db *sql.DB, tx *sql.Tx) queryExecutor {
+	var direct queryExecutor
+	if tx != nil {
+		direct = tx.QueryContext
+	} else {
+		direct = db.QueryContext
+	}
+	if c == nil || c.stmtCacheSize == 0 {
+		return direct
+	}
+	return func(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+		stmt, release, ok := c.acquire(ctx, db, query)
+		if !ok {
+			return direct(ctx, query, args...)
+		}
+		defer release()
+		if tx != nil {
+			stmt = tx.StmtContext(ctx, stmt)
+		}
+		return stmt.QueryContext(ctx, args...)
+	}
+}
+
+// newStatementExecutor returns a statementExecutor that runs cached SQL texts
+// as statements prepared on db, rebound to tx if it is not nil.
+func newStatementExecutor(c *statementCache, db *sql.DB, tx *sql.Tx) statementExecutor {
+	var direct statementExecutor
+	if tx != nil {
+		direct = tx.ExecContext
+	} else {
+		direct = db.ExecContext
+	}
+	if c == nil || c.stmtCacheSize == 0 {
+		return direct
+	}
+	return func(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+		stmt, release, ok := c.acquire(ctx, db, query)
+		if !ok {
+			return direct(ctx, query, args...)
+		}
+		defer release()
+		if tx != nil {
+			stmt = tx.StmtContext(ctx, stmt)
+		}
+		return stmt.ExecContext(ctx, args...)
+	}
+}
@@ -0,0 +1,211 @@
+package dalgo2sql
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dal-go/dalgo/dal"
+	dalrecord "github.com/dal-go/record"
+	"github.com/dal-go/record/update"
+)
+
+func TestLRUCache(t *testing.T) {
+	var evicted []string
+	c := newLRUCache(2, func(key string, _ int) { evicted = append(evicted, key) })
+	c.add("a", 1)
+	c.add("b", 2)
+	if v, ok := c.get("a"); !ok || v != 1 { // "a" becomes the most recently used
+		t.Fatalf("get(a) = %v, %v", v, ok)
+	}
+	c.add("c", 3)
+	if _, ok := c.get("b"); ok {
+		t.Error("expected b to be evicted")
+	}
+	c.add("a", 10)
+	if v, _ := c.get("a"); v != 10 {
+		t.Errorf("get(a) = %v, want 10", v)
+	}
+	c.remove("c")
+	c.remove("unknown")
+	if want := []string{"b", "c"}; !reflect.DeepEqual(evicted, want) {
+		t.Errorf("evicted = %v, want %v", evicted, want)
+	}
+	if c.len() != 1 {
+		t.Errorf("len() = %d, want 1", c.len())
+	}
+}
+
+func TestDbOptions_cachedSQL(t *testing.T) {
+	var builds int
+	build := func() string {
+		builds++
+		return "SELECT 1"
+	}
+	shape := func() string { return statementShape("get", "users") }
+
+	t.Run("disabled", func(t *testing.T) {
+		builds = 0
+		for i := 0; i < 2; i++ {
+			if text := (DbOptions{}).cachedSQL(shape, build); text != "SELECT 1" {
+				t.Errorf("text = %q", text)
+			}
+		}
+		if builds != 2 {
+			t.Errorf("builds = %d, want 2", builds)
+		}
+		if c := newStatementCache(DbOptions{SQLCacheSize: -1}); c != nil {
+			t.Error("expected a negative size to disable the cache")
+		}
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		builds = 0
+		options := DbOptions{SQLCacheSize: 1}
+		options.statements = newStatementCache(options)
+		for i := 0; i < 2; i++ {
+			options.cachedSQL(shape, build)
+		}
+		if builds != 1 {
+			t.Errorf("builds = %d, want 1", builds)
+		}
+		if options.statements.textShapes["SELECT 1"] != 1 {
+			t.Errorf("expected the text to be registered, got %v", options.statements.textShapes)
+		}
+		options.cachedSQL(func() string { return "other" }, func() string { return "SELECT 2" })
+		if _, ok := options.statements.textShapes["SELECT 1"]; ok {
+			t.Error("expected the evicted text to be unregistered")
+		}
+	})
+}
+
+func TestStatementCache_PreparesCachedTexts(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeDatabase(t, sqlDB)
+	db := NewDatabase(sqlDB, newSchema(), DbOptions{
+		StatementCacheSize: 2,
+		Recordsets: map[string]*Recordset{
+			"users": NewRecordset("users", Table, []dal.FieldRef{dal.Field("id")}),
+		},
+	})
+	ctx := context.Background()
+	type user struct {
+		Name string
+	}
+
+	prepared := mock.ExpectPrepare("SELECT Name FROM users WHERE id = ?")
+	prepared.ExpectQuery().WithArgs("u1").WillReturnRows(sqlmock.NewRows([]string{"Name"}).AddRow("John"))
+	prepared.ExpectQuery().WithArgs("u2").WillReturnRows(sqlmock.NewRows([]string{"Name"}).AddRow("Jane"))
+	// Ad-hoc statements are not prepared.
+	mock.ExpectExec("DELETE FROM users WHERE Name = ?").WithArgs("x").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	for _, id := range []string{"u1", "u2"} {
+		if err = db.Get(ctx, dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("users", id), &user{})); err != nil {
+			t.Fatalf("Get(%s): %v", id, err)
+		}
+	}
+	where := dal.Comparison{Operator: dal.Equal, Left: dal.Field("Name"), Right: dal.Constant{Value: "x"}}
+	if _, err = dal.BackendOf(db).(*database).DeleteWhere(ctx, "users", where); err != nil {
+		t.Fatalf("DeleteWhere: %v", err)
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStatementCache_QueriesHonourContext(t *testing.T) {
+	db := dal.BackendOf(NewDatabase(openTestSQLiteDB(t, `CREATE TABLE users (ID TEXT PRIMARY KEY, Name TEXT)`), newSchema(), DbOptions{
+		StatementCacheSize: 2,
+		Recordsets: map[string]*Recordset{
+			"users": NewRecordset("users", Table, []dal.FieldRef{dal.Field("ID")}),
+		},
+	})).(*database)
+	type user struct {
+		Name string
+	}
+	key := dalrecord.NewKeyWithID("users", "u1")
+	if err := db.Insert(context.Background(), dalrecord.NewRecordWithData(key, &user{Name: "John"})); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	// The first Get prepares the statement, the second runs the cached one.
+	for _, canceled := range []bool{false, true} {
+		ctx, cancel := context.WithCancel(context.Background())
+		if canceled {
+			cancel()
+		}
+		err := db.Get(ctx, dalrecord.NewRecordWithData(key, &user{}))
+		cancel()
+		if canceled && !errors.Is(err, context.Canceled) {
+			t.Errorf("Get with a canceled context: expected context.Canceled, got %v", err)
+		} else if !canceled && err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+	}
+}
+
+func TestStatementCache_SQLite(t *testing.T) {
+	ctx := context.Background()
+	options := DbOptions{
+		StatementCacheSize: 2, // smaller than the number of statements to exercise eviction
+		Recordsets: map[string]*Recordset{
+			"users": NewRecordset("users", Table, []dal.FieldRef{dal.Field("ID")}),
+		},
+	}
+	db := dal.BackendOf(NewDatabase(openTestSQLiteDB(t, `CREATE TABLE users (ID TEXT PRIMARY KEY, Name TEXT)`), newSchema(), options)).(*database)
+	type user struct {
+		Name string
+	}
+	key := func(id string) *dalrecord.Key { return dalrecord.NewKeyWithID("users", id) }
+	get := func(t *testing.T, r dal.ReadSession, id string) string {
+		t.Helper()
+		var u user
+		if err := r.Get(ctx, dalrecord.NewRecordWithData(key(id), &u)); err != nil {
+			t.Fatalf("Get(%s): %v", id, err)
+		}
+		return u.Name
+	}
+
+	for round := 0; round < 2; round++ { // the second round runs on cached statements
+		if err := db.Insert(ctx, dalrecord.NewRecordWithData(key("u1"), &user{Name: "John"})); err != nil {
+			t.Fatalf("Insert: %v", err)
+		}
+		if err := db.Set(ctx, dalrecord.NewRecordWithData(key("u1"), &user{Name: "Jo"})); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+		if exists, err := db.Exists(ctx, key("u1")); err != nil || !exists {
+			t.Fatalf("Exists: %v, %v", exists, err)
+		}
+		err := db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx dal.ReadwriteTransaction) error {
+			if name := get(t, tx, "u1"); name != "Jo" {
+				t.Errorf("Name = %q, want Jo", name)
+			}
+			return tx.Update(ctx, key("u1"), []update.Update{update.ByFieldName("Name", "Joe")})
+		})
+		if err != nil {
+			t.Fatalf("transaction: %v", err)
+		}
+		if name := get(t, db, "u1"); name != "Joe" {
+			t.Errorf("Name = %q, want Joe", name)
+		}
+		if err = db.Delete(ctx, key("u1")); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+	}
+	c := db.options.statements
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stmts := c.stmts[db.db]
+	if n := stmts.len(); n != 2 {
+		t.Errorf("prepared statements = %d, want 2", n)
+	}
+	for text, e := range stmts.items {
+		if stmt := e.Value.(*lruEntry[string, *cachedStmt]).value; stmt.users != 0 || stmt.evicted {
+			t.Errorf("%q: users = %d, evicted = %v", text, stmt.users, stmt.evicted)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package dalgo2sql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// ErrNoTenant is returned by operations on tenant-scoped recordsets
+// when the context carries no tenant, see WithTenant.
+var ErrNoTenant = errors.New("tenant is not set in context")
+
+// TenancyMode selects how rows of different tenants are separated.
+type TenancyMode int
+
+const (
+	// TenantColumn keeps rows of all tenants in shared tables with a column
+	// holding the tenant ID. The column is added to the WHERE clause of every
+	// statement and set by inserts.
+	TenantColumn TenancyMode = iota
+	// TenantSchema keeps each tenant in its own schema (database in MySQL)
+	// named after the tenant ID, so table names are qualified as "tenant.table".
+	TenantSchema
+	// TenantTablePrefix keeps each tenant in its own set of tables
+	// named "tenant_table".
+	TenantTablePrefix
+)
+
+// DefaultTenantColumn is used when Tenancy.Column is not set.
+const DefaultTenantColumn = "tenant_id"
+
+// Tenancy scopes statements to the tenant carried by the context, so data of
+// other tenants is neither read nor written by mistake. Text queries are
+// executed as is and must filter by tenant themselves.
+type Tenancy struct {
+	Mode TenancyMode
+	// Column holds the tenant ID in TenantColumn mode.
+	// Empty uses DefaultTenantColumn.
+	Column string
+	// Shared lists recordsets that are not scoped by tenant, e.g. lookup tables.
+	Shared []string
+}
+
+func (t *Tenancy) column() string {
+	if t.Column == "" {
+		return DefaultTenantColumn
+	}
+	return t.Column
+}
+
+type tenantContextKey struct{}
+
+// WithTenant returns a context that scopes operations to a tenant.
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenantID)
+}
+
+// TenantFromContext returns the tenant set by WithTenant.
+func TenantFromContext(ctx context.Context) (tenantID string, ok bool) {
+	if ctx == nil {
+		return "", false
+	}
+	tenantID, ok = ctx.Value(tenantContextKey{}).(string)
+	return tenantID, ok && tenantID != ""
+}
+
+// apply scopes statements on a recordset to the tenant of the context.
+func (t *Tenancy) apply(ctx context.Context, s *statementScope) error {
+	if slices.Contains(t.Shared, s.table) {
+		return nil
+	}
+	tenantID, ok := TenantFromContext(ctx)
+	if !ok {
+		return ErrNoTenant
+	}
+	switch t.Mode {
+	case TenantColumn:
+		column := t.column()
+		s.conditions = append(s.conditions, column+" = ?")
+		s.args = append(s.args, tenantID)
+		s.columns = append(s.columns, column)
+		s.values = append(s.values, tenantID)
+	case TenantSchema, TenantTablePrefix:
+		// The tenant ID becomes part of an identifier, so it must not be able to inject SQL.
+		if err := validateIdentifier(tenantID); err != nil {
+			return fmt.Errorf("invalid tenant ID: %w", err)
+		}
+		if t.Mode == TenantSchema {
+			s.table = tenantID + "." + s.table
+		} else {
+			s.table = tenantID + "_" + s.table
+		}
+	default:
+		return fmt.Errorf("unknown tenancy mode %d", t.Mode)
+	}
+	return nil
+}
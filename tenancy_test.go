@@ -0,0 +1,492 @@
+package dalgo2sql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dal-go/dalgo/dal"
+	dalrecord "github.com/dal-go/record"
+	"github.com/dal-go/record/update"
+)
+
+func TestTenantFromContext(t *testing.T) {
+	if _, ok := TenantFromContext(context.Background()); ok {
+		t.Error("expected no tenant in a background context")
+	}
+	if _, ok := TenantFromContext(WithTenant(context.Background(), "")); ok {
+		t.Error("expected an empty tenant to be treated as not set")
+	}
+	if tenant, ok := TenantFromContext(WithTenant(context.Background(), "acme")); !ok || tenant != "acme" {
+		t.Errorf("got %q, %v; want acme, true", tenant, ok)
+	}
+}
+
+func TestDbOptions_scope(t *testing.T) {
+	acme := WithTenant(context.Background(), "acme")
+	for _, tt := range []struct {
+		name    string
+		tenancy *Tenancy
+		ctx     context.Context
+		want    statementScope
+		wantErr error
+	}{
+		{
+			name: "no_tenancy",
+			ctx:  context.Background(),
+			want: statementScope{table: "users"},
+		},
+		{
+			name:    "column",
+			tenancy: &Tenancy{},
+			ctx:     acme,
+			want: statementScope{
+				table:      "users",
+				conditions: []string{"tenant_id = ?"},
+				args:       []any{"acme"},
+				columns:    []string{"tenant_id"},
+				values:     []any{"acme"},
+			},
+		},
+		{
+			name:    "custom_column",
+			tenancy: &Tenancy{Column: "org"},
+			ctx:     acme,
+			want: statementScope{
+				table:      "users",
+				conditions: []string{"org = ?"},
+				args:       []any{"acme"},
+				columns:    []string{"org"},
+				values:     []any{"acme"},
+			},
+		},
+		{
+			name:    "schema",
+			tenancy: &Tenancy{Mode: TenantSchema},
+			ctx:     acme,
+			want:    statementScope{table: "acme.users"},
+		},
+		{
+			name:    "table_prefix",
+			tenancy: &Tenancy{Mode: TenantTablePrefix},
+			ctx:     acme,
+			want:    statementScope{table: "acme_users"},
+		},
+		{
+			name:    "shared",
+			tenancy: &Tenancy{Shared: []string{"users"}},
+			ctx:     context.Background(),
+			want:    statementScope{table: "users"},
+		},
+		{
+			name:    "no_tenant",
+			tenancy: &Tenancy{},
+			ctx:     context.Background(),
+			wantErr: ErrNoTenant,
+		},
+		{
+			name:    "invalid_tenant_in_identifier",
+			tenancy: &Tenancy{Mode: TenantSchema},
+			ctx:     WithTenant(context.Background(), "x; DROP TABLE users"),
+			wantErr: errAny,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DbOptions{Tenancy: tt.tenancy}.scope(tt.ctx, "users")
+			switch {
+			case tt.wantErr == errAny:
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			case err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDbOptions_scopeQuery(t *testing.T) {
+	acme := WithTenant(context.Background(), "acme")
+	column := DbOptions{Tenancy: &Tenancy{}}
+	for _, tt := range []struct {
+		name      string
+		options   DbOptions
+		recordset string
+		text      string
+		want      string
+		wantArgs  []any
+		wantErr   bool
+	}{
+		{
+			name:      "not_scoped",
+			options:   DbOptions{},
+			recordset: "users",
+			text:      "SELECT *\nFROM users\nWHERE Name = 'x'",
+			want:      "SELECT *\nFROM users\nWHERE Name = 'x'",
+		},
+		{
+			name:      "column",
+			options:   column,
+			recordset: "users",
+			text:      "SELECT *\nFROM users\nWHERE Name = 'x' OR Name = 'y'",
+			want:      "SELECT *\nFROM (SELECT * FROM users WHERE tenant_id = ?) users\nWHERE Name = 'x' OR Name = 'y'",
+			wantArgs:  []any{"acme"},
+		},
+		{
+			name:      "column_single_line",
+			options:   column,
+			recordset: "users",
+			text:      "SELECT * FROM users WHERE Name = 'x'",
+			want:      "SELECT * FROM (SELECT * FROM users WHERE tenant_id = ?) users WHERE Name = 'x'",
+			wantArgs:  []any{"acme"},
+		},
+		{
+			name:      "aliased",
+			options:   column,
+			recordset: "users",
+			text:      "SELECT u.Name\nFROM users AS u",
+			want:      "SELECT u.Name\nFROM (SELECT * FROM users WHERE tenant_id = ?) AS u",
+			wantArgs:  []any{"acme"},
+		},
+		{
+			name:      "dollar_placeholders",
+			options:   DbOptions{Tenancy: &Tenancy{}, Placeholder: PlaceholderDollar},
+			recordset: "users",
+			text:      "SELECT *\nFROM users",
+			want:      "SELECT *\nFROM (SELECT * FROM users WHERE tenant_id = $1) users",
+			wantArgs:  []any{"acme"},
+		},
+		{
+			name:      "schema",
+			options:   DbOptions{Tenancy: &Tenancy{Mode: TenantSchema}},
+			recordset: "users",
+			text:      "SELECT *\nFROM users\nLIMIT 10",
+			want:      "SELECT *\nFROM acme.users users\nLIMIT 10",
+		},
+		{
+			name:      "not_a_prefix_of_another_table",
+			options:   column,
+			recordset: "user",
+			text:      "SELECT *\nFROM users",
+			wantErr:   true,
+		},
+		{
+			name:      "text_query",
+			options:   column,
+			recordset: "",
+			text:      "SELECT 1",
+			want:      "SELECT 1",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, args, err := tt.options.scopeQuery(acme, tt.recordset, tt.text)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args = %v, want %v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestTenancy_DollarPlaceholders(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeDatabase(t, sqlDB)
+	db := NewDatabase(sqlDB, newSchema(), DbOptions{
+		Placeholder: PlaceholderDollar,
+		Tenancy:     &Tenancy{},
+		Recordsets: map[string]*Recordset{
+			"users": NewRecordset("users", Table, []dal.FieldRef{dal.Field("id")}),
+		},
+	})
+	ctx := WithTenant(context.Background(), "acme")
+	key := dalrecord.NewKeyWithID("users", "u1")
+
+	mock.ExpectQuery(`SELECT Name FROM users WHERE id = \$1 AND tenant_id = \$2`).
+		WithArgs("u1", "acme").
+		WillReturnRows(sqlmock.NewRows([]string{"Name"}).AddRow("John"))
+	var u struct{ Name string }
+	if err = db.Get(ctx, dalrecord.NewRecordWithData(key, &u)); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	mock.ExpectExec(`UPDATE users SET\s+Name = \$1\s+WHERE id = \$2 AND tenant_id = \$3`).
+		WithArgs("Jo", "u1", "acme").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if err = db.Update(ctx, key, []update.Update{update.ByFieldName("Name", "Jo")}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	mock.ExpectExec(`DELETE FROM users WHERE id IN \(\$1, \$2\) AND tenant_id = \$3`).
+		WithArgs("u1", "u2", "acme").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	err = deleteMultiInSingleTable(ctx, dal.BackendOf(db).(*database).options, []*dalrecord.Key{key, dalrecord.NewKeyWithID("users", "u2")}, sqlDB.ExecContext)
+	if err != nil {
+		t.Fatalf("deleteMultiInSingleTable: %v", err)
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTenancy_Column_SQLite(t *testing.T) {
+	options := DbOptions{
+		Tenancy: &Tenancy{Shared: []string{"countries"}},
+		Recordsets: map[string]*Recordset{
+			"users":     NewRecordset("users", Table, []dal.FieldRef{dal.Field("ID")}),
+			"countries": NewRecordset("countries", Table, []dal.FieldRef{dal.Field("ID")}),
+		},
+	}
+	sqlDB := openTestSQLiteDB(t, `
+		CREATE TABLE users (ID TEXT PRIMARY KEY, tenant_id TEXT NOT NULL, Name TEXT);
+		CREATE TABLE countries (ID TEXT PRIMARY KEY, Name TEXT);`)
+	db := dal.BackendOf(NewDatabase(sqlDB, newSchema(), options)).(*database)
+	acme := WithTenant(context.Background(), "acme")
+	other := WithTenant(context.Background(), "other")
+
+	type user struct {
+		Name string
+	}
+	key := func(id string) *dalrecord.Key { return dalrecord.NewKeyWithID("users", id) }
+	name := func(t *testing.T, id string) string {
+		t.Helper()
+		var name string
+		if err := sqlDB.QueryRow(`SELECT Name FROM users WHERE ID = ?`, id).Scan(&name); err != nil {
+			t.Fatalf("SELECT: %v", err)
+		}
+		return name
+	}
+	isNotFound := func(err error) bool { return errors.Is(err, dalrecord.ErrRecordNotFound) }
+
+	if err := db.Insert(acme, dalrecord.NewRecordWithData(key("u1"), &user{Name: "John"})); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	var tenant string
+	if err := sqlDB.QueryRow(`SELECT tenant_id FROM users WHERE ID = 'u1'`).Scan(&tenant); err != nil || tenant != "acme" {
+		t.Fatalf("tenant_id = %q, %v; want acme", tenant, err)
+	}
+
+	t.Run("no_tenant", func(t *testing.T) {
+		err := db.Get(context.Background(), dalrecord.NewRecordWithData(key("u1"), &user{}))
+		if !errors.Is(err, ErrNoTenant) {
+			t.Errorf("expected ErrNoTenant, got %v", err)
+		}
+	})
+
+	t.Run("Get", func(t *testing.T) {
+		var u user
+		if err := db.Get(acme, dalrecord.NewRecordWithData(key("u1"), &u)); err != nil || u.Name != "John" {
+			t.Errorf("own tenant: %v, %+v", err, u)
+		}
+		if err := db.Get(other, dalrecord.NewRecordWithData(key("u1"), &user{})); !isNotFound(err) {
+			t.Errorf("other tenant: expected not found, got %v", err)
+		}
+	})
+
+	t.Run("GetMulti", func(t *testing.T) {
+		records := []dalrecord.Record{
+			dalrecord.NewRecordWithData(key("u1"), &user{}),
+			dalrecord.NewRecordWithData(key("u2"), &user{}),
+		}
+		if err := db.GetMulti(other, records); err != nil {
+			t.Fatalf("GetMulti: %v", err)
+		}
+		if records[0].Exists() {
+			t.Error("expected a record of another tenant not to be found")
+		}
+	})
+
+	t.Run("Exists", func(t *testing.T) {
+		if exists, err := db.Exists(other, key("u1")); err != nil || exists {
+			t.Errorf("other tenant: %v, %v", exists, err)
+		}
+		if exists, err := db.Exists(acme, key("u1")); err != nil || !exists {
+			t.Errorf("own tenant: %v, %v", exists, err)
+		}
+	})
+
+	t.Run("Set", func(t *testing.T) {
+		// The row of another tenant is invisible, so Set tries to insert and hits the primary key.
+		if err := db.Set(other, dalrecord.NewRecordWithData(key("u1"), &user{Name: "Mallory"})); err == nil {
+			t.Error("expected Set over a row of another tenant to fail")
+		}
+		if err := db.Set(acme, dalrecord.NewRecordWithData(key("u1"), &user{Name: "Johnny"})); err != nil {
+			t.Errorf("Set: %v", err)
+		}
+		if got := name(t, "u1"); got != "Johnny" {
+			t.Errorf("Name = %q, want Johnny", got)
+		}
+	})
+
+	t.Run("Insert_conflicting_tenant", func(t *testing.T) {
+		type tenantUser struct {
+			Name      string
+			Tenant_id string
+		}
+		err := db.Insert(acme, dalrecord.NewRecordWithData(key("u3"), &tenantUser{Name: "Eve", Tenant_id: "other"}))
+		if !errors.Is(err, dal.ErrNotSupported) {
+			t.Errorf("expected ErrNotSupported, got %v", err)
+		}
+		if err = db.Insert(acme, dalrecord.NewRecordWithData(key("u3"), &tenantUser{Name: "Ann"})); err != nil {
+			t.Errorf("expected an empty tenant column to be filled, got %v", err)
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		updates := []update.Update{update.ByFieldName("Name", "Mallory")}
+		if err := db.Update(other, key("u1"), updates); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		if n, err := db.UpdateWhere(other, "users", dal.Comparison{Operator: dal.Equal, Left: dal.Field("ID"), Right: dal.Constant{Value: "u1"}}, updates); err != nil || n != 0 {
+			t.Errorf("UpdateWhere = %d, %v; want 0", n, err)
+		}
+		if got := name(t, "u1"); got != "Johnny" {
+			t.Errorf("Name = %q, want Johnny", got)
+		}
+		err := db.Update(acme, key("u1"), []update.Update{update.ByFieldName("tenant_id", "other")})
+		if !errors.Is(err, dal.ErrNotSupported) {
+			t.Errorf("expected moving a row to another tenant to be rejected, got %v", err)
+		}
+	})
+
+	t.Run("query", func(t *testing.T) {
+		q := dal.NewQueryBuilder(dal.From(dal.NewRootCollectionRef("users", ""))).SelectIntoRecordset()
+		count := func(ctx context.Context) (n int) {
+			reader, err := db.ExecuteQueryToRecordsetReader(ctx, q)
+			if err != nil {
+				t.Fatalf("ExecuteQueryToRecordsetReader: %v", err)
+			}
+			defer func() { _ = reader.Close() }()
+			for {
+				if _, _, err = reader.Next(); err != nil {
+					if !errors.Is(err, dal.ErrNoMoreRecords) {
+						t.Fatalf("Next: %v", err)
+					}
+					return n
+				}
+				n++
+			}
+		}
+		if n := count(other); n != 0 {
+			t.Errorf("other tenant reads %d rows, want 0", n)
+		}
+		if n := count(acme); n != 2 {
+			t.Errorf("own tenant reads %d rows, want 2", n)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		if err := db.Delete(other, key("u1")); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		if err := db.DeleteMulti(other, []*dalrecord.Key{key("u1"), key("u3")}); err != nil {
+			t.Fatalf("DeleteMulti: %v", err)
+		}
+		if n, err := db.DeleteWhere(other, "users", dal.Comparison{Operator: dal.Equal, Left: dal.Field("Name"), Right: dal.Constant{Value: "Johnny"}}); err != nil || n != 0 {
+			t.Errorf("DeleteWhere = %d, %v; want 0", n, err)
+		}
+		if exists, err := db.Exists(acme, key("u1")); err != nil || !exists {
+			t.Errorf("expected the row to survive deletes of another tenant: %v, %v", exists, err)
+		}
+		if err := db.Delete(acme, key("u1")); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		if exists, _ := db.Exists(acme, key("u1")); exists {
+			t.Error("expected the row to be deleted")
+		}
+	})
+
+	t.Run("shared", func(t *testing.T) {
+		ctx := context.Background()
+		if err := db.Insert(ctx, dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("countries", "ie"), &user{Name: "Ireland"})); err != nil {
+			t.Fatalf("Insert: %v", err)
+		}
+		if exists, err := db.Exists(ctx, dalrecord.NewKeyWithID("countries", "ie")); err != nil || !exists {
+			t.Errorf("Exists = %v, %v", exists, err)
+		}
+	})
+}
+
+func TestTenancy_SchemaAndPrefix_SQLite(t *testing.T) {
+	dir := t.TempDir()
+	type user struct {
+		Name string
+	}
+	key := dalrecord.NewKeyWithID("users", "u1")
+	recordsets := map[string]*Recordset{
+		"users": NewRecordset("users", Table, []dal.FieldRef{dal.Field("ID")}),
+	}
+	acme := WithTenant(context.Background(), "acme")
+	other := WithTenant(context.Background(), "other")
+
+	t.Run("schema", func(t *testing.T) {
+		sqlDB, err := sql.Open("sqlite", filepath.Join(dir, "main.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = sqlDB.Close() })
+		sqlDB.SetMaxOpenConns(1) // attached databases are per connection
+		for _, tenant := range []string{"acme", "other"} {
+			if _, err = sqlDB.Exec(`ATTACH DATABASE ? AS `+tenant, filepath.Join(dir, tenant+".db")); err != nil {
+				t.Fatalf("ATTACH: %v", err)
+			}
+			if _, err = sqlDB.Exec(`CREATE TABLE ` + tenant + `.users (ID TEXT PRIMARY KEY, Name TEXT)`); err != nil {
+				t.Fatalf("CREATE TABLE: %v", err)
+			}
+		}
+		db := NewDatabase(sqlDB, newSchema(), DbOptions{Tenancy: &Tenancy{Mode: TenantSchema}, Recordsets: recordsets})
+		if err = db.Insert(acme, dalrecord.NewRecordWithData(key, &user{Name: "John"})); err != nil {
+			t.Fatalf("Insert: %v", err)
+		}
+		var n int
+		if err = sqlDB.QueryRow(`SELECT COUNT(*) FROM acme.users`).Scan(&n); err != nil || n != 1 {
+			t.Errorf("acme.users has %d rows, %v; want 1", n, err)
+		}
+		if exists, err := db.Exists(other, key); err != nil || exists {
+			t.Errorf("Exists in other schema = %v, %v; want false", exists, err)
+		}
+	})
+
+	t.Run("table_prefix", func(t *testing.T) {
+		sqlDB := openTestSQLiteDB(t, `
+			CREATE TABLE acme_users (ID TEXT PRIMARY KEY, Name TEXT);
+			CREATE TABLE other_users (ID TEXT PRIMARY KEY, Name TEXT);`)
+		db := NewDatabase(sqlDB, newSchema(), DbOptions{Tenancy: &Tenancy{Mode: TenantTablePrefix}, Recordsets: recordsets})
+		if err := db.Insert(acme, dalrecord.NewRecordWithData(key, &user{Name: "John"})); err != nil {
+			t.Fatalf("Insert: %v", err)
+		}
+		var u user
+		if err := db.Get(acme, dalrecord.NewRecordWithData(key, &u)); err != nil || u.Name != "John" {
+			t.Errorf("Get = %+v, %v", u, err)
+		}
+		if exists, err := db.Exists(other, key); err != nil || exists {
+			t.Errorf("Exists in other tenant = %v, %v; want false", exists, err)
+		}
+	})
+}
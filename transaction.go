@@ -12,20 +12,29 @@ import (
 
 var _ dal.Transaction = (*transaction)(nil)
 
-func newTransaction(tx *sql.Tx, sqlOptions DbOptions, txOptions dal.TransactionOptions) transaction {
+// newTransaction wraps tx started on db.
+func newTransaction(db *sql.DB, tx *sql.Tx, sqlOptions DbOptions, txOptions dal.TransactionOptions) transaction {
 	return transaction{
 		tx:                    tx,
-		recordsReaderProvider: recordsReaderProvider{executeQuery: tx.QueryContext},
+		query:                 newQueryExecutor(sqlOptions.statements, db, tx),
+		exec:                  newStatementExecutor(sqlOptions.statements, db, tx),
+		recordsReaderProvider: recordsReaderProvider{executeQuery: tx.QueryContext, options: sqlOptions},
 		sqlOptions:            sqlOptions,
 		txOptions:             txOptions,
 	}
 }
 
 type transaction struct {
-	tx *sql.Tx
+	tx    *sql.Tx
+	query queryExecutor
+	exec  statementExecutor
 	recordsReaderProvider
 	sqlOptions DbOptions // TODO: document why & how to use
 	txOptions  dal.TransactionOptions
+	// buffer holds the writes of a transaction started WithBufferedWrites.
+	buffer *writeBuffer
+	// identities holds the rows read by a transaction started WithIdentityMap.
+	identities *identityMap
 }
 
 func (t transaction) Options() dal.TransactionOptions {
@@ -37,7 +46,7 @@ func (t transaction) ID() string {
 }
 
 func (t transaction) Select(ctx context.Context, query dal.Query) (dal.Reader, error) {
-	return getRecordsReader(ctx, query, t.tx.QueryContext)
+	return getRecordsReader(withOperation(ctx, OpQuery), t.sqlOptions, query, t.executeQuery)
 }
 
 var _ dal.ReadTransaction = (*readTransaction)(nil)
@@ -45,17 +54,28 @@ var _ dal.ReadTransaction = (*readTransaction)(nil)
 type readTransaction = transaction
 
 func (t readTransaction) ExecuteQueryToRecordsetReader(ctx context.Context, query dal.Query, options ...recordset.Option) (dal.RecordsetReader, error) {
-	return getRecordsetReader(ctx, query, t.tx.QueryContext, options...)
+	return getRecordsetReader(withOperation(ctx, OpQuery), t.sqlOptions, query, t.executeQuery, options...)
 }
 
 var _ dal.ReadwriteTransaction = (*readwriteTransaction)(nil)
 
 type readwriteTransaction = readTransaction
 
-func newReadwriteTransaction(tx *sql.Tx, sqlOptions DbOptions, txOptions dal.TransactionOptions) readwriteTransaction {
-	return newTransaction(tx, sqlOptions, txOptions)
+func newReadwriteTransaction(db *sql.DB, tx *sql.Tx, sqlOptions DbOptions, txOptions dal.TransactionOptions) readwriteTransaction {
+	return newTransaction(db, tx, sqlOptions, txOptions)
 }
 
 func (t transaction) UpdateRecord(ctx context.Context, record dalrecord.Record, updates []update.Update, preconditions ...dal.Precondition) error {
-	return t.Update(ctx, record.Key(), updates, preconditions...)
+	versionColumn := t.sqlOptions.versionColumn(record.Key().Collection())
+	version, versioned := recordVersion(record, versionColumn)
+	if versioned {
+		ctx = ExpectVersion(ctx, version)
+	}
+	if err := t.Update(ctx, record.Key(), updates, preconditions...); err != nil {
+		return err
+	}
+	if versioned {
+		setRecordVersion(record, versionColumn, version+1)
+	}
+	return nil
 }
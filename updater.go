@@ -2,7 +2,10 @@ package dalgo2sql
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/dal-go/dalgo/dal"
 	"github.com/dal-go/record"
@@ -10,47 +13,99 @@ import (
 )
 
 func (dtb *database) Update(ctx context.Context, key *record.Key, updates []update.Update, preconditions ...dal.Precondition) error {
-	return updateSingle(ctx, dtb.options, dtb.db.ExecContext, key, updates, preconditions...)
+	ctx = withOperation(ctx, OpUpdate)
+	return dtb.write(ctx, keysChange(key).updating(updates), func(_ queryExecutor, exec statementExecutor) error {
+		return updateSingle(ctx, dtb.options, exec, key, updates, preconditions...)
+	})
 }
 
 func (t transaction) Update(ctx context.Context, key *record.Key, updates []update.Update, preconditions ...dal.Precondition) error {
-	return updateSingle(ctx, t.sqlOptions, t.tx.ExecContext, key, updates, preconditions...)
+	ctx = withOperation(ctx, OpUpdate)
+	if len(updates) > 0 && t.buffer.accepts(ctx, key) {
+		return t.buffer.add(ctx, bufferedUpdate, key, nil, updates)
+	}
+	return t.write(ctx, keysChange(key).updating(updates), func(_ queryExecutor, exec statementExecutor) error {
+		return updateSingle(ctx, t.sqlOptions, exec, key, updates, preconditions...)
+	})
 }
 
 func (dtb *database) UpdateMulti(ctx context.Context, keys []*record.Key, updates []update.Update, preconditions ...dal.Precondition) error {
-	return updateMulti(ctx, dtb.options, dtb.db.ExecContext, keys, updates, preconditions...)
+	ctx = withOperation(ctx, OpUpdateMulti)
+	return dtb.write(ctx, keysChange(keys...).updating(updates), func(_ queryExecutor, exec statementExecutor) error {
+		return updateMulti(ctx, dtb.options, exec, keys, updates, preconditions...)
+	})
 }
 
 func (t transaction) UpdateMulti(ctx context.Context, keys []*record.Key, updates []update.Update, preconditions ...dal.Precondition) error {
-	return updateMulti(ctx, t.sqlOptions, t.tx.ExecContext, keys, updates, preconditions...)
+	ctx = withOperation(ctx, OpUpdateMulti)
+	if len(updates) > 0 && t.buffer.accepts(ctx, keys...) {
+		for _, key := range keys {
+			if err := t.buffer.add(ctx, bufferedUpdate, key, nil, updates); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return t.write(ctx, keysChange(keys...).updating(updates), func(_ queryExecutor, exec statementExecutor) error {
+		return updateMulti(ctx, t.sqlOptions, exec, keys, updates, preconditions...)
+	})
 }
 
 func updateSingle(ctx context.Context, options DbOptions, execStatement statementExecutor, key *record.Key, updates []update.Update, _ ...dal.Precondition) error {
-	qry := query{
-		text: fmt.Sprintf("UPDATE %v SET", key.Collection()),
+	var qry query
+	scope, err := options.scope(ctx, key.Collection())
+	if err != nil {
+		return err
 	}
-	n := 1
-	for _, u := range updates {
-		qry.text += fmt.Sprintf("\n\t%v = %s", u.FieldName(), options.Placeholder.placeholder(n))
-		qry.args = append(qry.args, u.Value())
-		n++
+	if err = scope.checkUpdates(updates); err != nil {
+		return err
+	}
+	if updates, err = scope.stampUpdates(updates); err != nil {
+		return err
 	}
+	versionColumn := options.versionColumn(key.Collection())
+	version, versioned := expectedVersion(ctx)
+	versioned = versioned && versionColumn != ""
+	if versioned {
+		scope.expectVersion(versionColumn, version)
+	}
+	setClause, setArgs, err := buildSetClause(options, key.Collection(), updates)
+	if err != nil {
+		return err
+	}
+	qry.args = append(qry.args, setArgs...)
 	primaryKey := options.PrimaryKeyFieldNames(key)
 	switch len(primaryKey) {
 	case 0:
 		return fmt.Errorf("primary key is not defined for %s", getRecordsetName(key))
 	case 1:
-		qry.text += fmt.Sprintf("\n\tWHERE %v = %s", primaryKey[0], options.Placeholder.placeholder(n))
 	default:
 		return fmt.Errorf("%w: updateOperation by composite primary key is not supported yet", dal.ErrNotImplementedYet)
 	}
-	qry.args = append(qry.args, key.ID)
-	result, err := execStatement(ctx, qry.text, qry.args...)
+	keyArgs, err := primaryKeyArgs(newColumnMapper(options, key.Collection()), primaryKey, key)
+	if err != nil {
+		return err
+	}
+	qry.args = append(append(qry.args, keyArgs...), scope.args...)
+	qry.text = options.cachedSQL(
+		func() string { return statementShape("update", scope.shape(), setClause, primaryKey[0]) },
+		func() string {
+			text := fmt.Sprintf("UPDATE %v SET%s\n\tWHERE %v = ?%s", scope.table, setClause, primaryKey[0], scope.where())
+			return options.Placeholder.rewritePlaceholders(text)
+		})
+	result, err := options.traceStatement(key.Collection(), execStatement)(ctx, qry.text, qry.args...)
 	if err != nil {
 		return fmt.Errorf("failed to updateOperation a single record: %w", err)
 	}
-	if count, err := result.RowsAffected(); err == nil && count > 1 {
-		return fmt.Errorf("expected to updateOperation a single row, number of affected rows: %v", count)
+	if count, err := result.RowsAffected(); err == nil {
+		if versioned && count == 0 {
+			options.count(ctx, key.Collection(), CounterConflicts, 1)
+			return &VersionConflictError{Key: key, Version: version}
+		}
+		options.count(ctx, key.Collection(), CounterUpdates, count)
+		if count > 1 {
+			return fmt.Errorf("expected to updateOperation a single row, number of affected rows: %v", count)
+		}
 	}
 	return nil
 }
@@ -63,3 +118,189 @@ func updateMulti(ctx context.Context, options DbOptions, execStatement statement
 	}
 	return nil
 }
+
+// Increment returns an update value that makes the adapter emit
+// "field = field + ?" instead of overwriting the field, e.g.
+//
+//	update.ByFieldName("Visits", dalgo2sql.Increment(1))
+//
+// The dal-go/record/update package has no increment operation of its own,
+// so the sentinel is provided by this adapter.
+func Increment(delta any) any {
+	return increment{delta: delta}
+}
+
+type increment struct {
+	delta any
+}
+
+// buildSetClause renders updates as the body of an UPDATE ... SET statement
+// using "?" placeholders. Besides plain values it understands the
+// update.DeleteField and update.ServerTimestamp sentinels, Increment and
+// nested field paths, which are written into JSON columns by dialect functions.
+// Column names are validated like the fields of where conditions.
+// Several nested updates of the same JSON column are composed into a single
+// assignment, as SQL allows a column to be assigned only once per statement.
+// Plain values are converted by the collection's column mapper (JSON columns, codecs).
+func buildSetClause(options DbOptions, collection string, updates []update.Update) (text string, args []any, err error) {
+	if len(updates) == 0 {
+		return "", nil, errors.New("no updates provided")
+	}
+	mapper := newColumnMapper(options, collection)
+	type assignment struct {
+		expr string
+		args []any
+	}
+	var columns []string
+	assignments := make(map[string]*assignment, len(updates))
+	for _, u := range updates {
+		column := u.FieldName()
+		var path []string
+		if fieldPath := u.FieldPath(); len(fieldPath) > 0 {
+			column, path = fieldPath[0], fieldPath[1:]
+		}
+		if err = validateIdentifier(column); err != nil {
+			return "", nil, fmt.Errorf("invalid update of %s: %w", collection, err)
+		}
+		a, assigned := assignments[column]
+		if !assigned {
+			a = &assignment{expr: column}
+			assignments[column] = a
+			columns = append(columns, column)
+		}
+		var expr string
+		var exprArgs []any
+		if len(path) == 0 {
+			if expr, exprArgs, err = buildValueExpr(mapper, column, u.Value()); err != nil {
+				return "", nil, err
+			}
+			a.expr, a.args = expr, exprArgs
+			continue
+		}
+		if expr, exprArgs, err = buildNestedValueExpr(options.Dialect, a.expr, column, path, u.Value()); err != nil {
+			return "", nil, err
+		}
+		a.expr, a.args = expr, append(a.args, exprArgs...)
+	}
+	for i, column := range columns {
+		if i > 0 {
+			text += ","
+		}
+		a := assignments[column]
+		text += "\n\t" + column + " = " + a.expr
+		args = append(args, a.args...)
+	}
+	return text, args, nil
+}
+
+func buildValueExpr(mapper columnMapper, column string, value any) (expr string, args []any, err error) {
+	switch value {
+	case update.DeleteField:
+		return "NULL", nil, nil
+	case update.ServerTimestamp:
+		return "CURRENT_TIMESTAMP", nil, nil
+	default:
+		if inc, ok := value.(increment); ok {
+			return column + " + ?", []any{inc.delta}, nil
+		}
+		if value, err = mapper.toSQL(column, nil, value); err != nil {
+			return "", nil, err
+		}
+		return "?", []any{value}, nil
+	}
+}
+
+// buildNestedValueExpr wraps target, the current expression of a JSON column,
+// into a dialect function that sets or removes the nested path.
+func buildNestedValueExpr(dialect Dialect, target, column string, path []string, value any) (expr string, args []any, err error) {
+	if _, isIncrement := value.(increment); isIncrement {
+		return "", nil, fmt.Errorf("%w: increment of nested field %s.%s", dal.ErrNotSupported, column, strings.Join(path, "."))
+	}
+	if value == update.ServerTimestamp {
+		return "", nil, fmt.Errorf("%w: server timestamp for nested field %s.%s", dal.ErrNotSupported, column, strings.Join(path, "."))
+	}
+	if value == update.DeleteField {
+		if expr, err = dialect.jsonRemoveExpr(target, path); err != nil {
+			return "", nil, err
+		}
+		return expr, nil, nil
+	}
+	if expr, err = dialect.jsonSetExpr(target, path); err != nil {
+		return "", nil, err
+	}
+	var b []byte
+	if b, err = json.Marshal(value); err != nil {
+		return "", nil, fmt.Errorf("failed to encode value for nested field %s.%s: %w", column, strings.Join(path, "."), err)
+	}
+	return expr, []any{string(b)}, nil
+}
+
+// SetMutator is implemented by the database (see dal.BackendOf) and the
+// transactions of this adapter.
+type SetMutator interface {
+	// UpdateWhere applies updates to the rows of the collection matching the condition.
+	UpdateWhere(ctx context.Context, collection string, where dal.Condition, updates []update.Update) (int64, error)
+	// DeleteWhere deletes the rows of the collection matching the condition.
+	DeleteWhere(ctx context.Context, collection string, where dal.Condition) (int64, error)
+}
+
+var _ SetMutator = (*database)(nil)
+var _ SetMutator = (*transaction)(nil)
+
+// UpdateWhere applies updates to every row of the collection that matches the
+// where condition using a single UPDATE statement and returns the number of
+// affected rows. A nil condition is rejected to avoid accidental full-table updates.
+func (dtb *database) UpdateWhere(ctx context.Context, collection string, where dal.Condition, updates []update.Update) (n int64, err error) {
+	ctx = withOperation(ctx, OpUpdateWhere)
+	err = dtb.write(ctx, whereChange(collection, where).updating(updates).counting(&n), func(_ queryExecutor, exec statementExecutor) (err error) {
+		n, err = updateWhere(ctx, dtb.options, exec, collection, where, updates)
+		return err
+	})
+	return n, err
+}
+
+// UpdateWhere is the in-transaction counterpart of database.UpdateWhere.
+func (t transaction) UpdateWhere(ctx context.Context, collection string, where dal.Condition, updates []update.Update) (n int64, err error) {
+	ctx = withOperation(ctx, OpUpdateWhere)
+	err = t.write(ctx, whereChange(collection, where).updating(updates).counting(&n), func(_ queryExecutor, exec statementExecutor) (err error) {
+		n, err = updateWhere(ctx, t.sqlOptions, exec, collection, where, updates)
+		return err
+	})
+	return n, err
+}
+
+func updateWhere(ctx context.Context, options DbOptions, execStatement statementExecutor, collection string, where dal.Condition, updates []update.Update) (int64, error) {
+	if where == nil {
+		return 0, fmt.Errorf("update of %s requires a where condition", collection)
+	}
+	scope, err := options.scope(ctx, collection)
+	if err != nil {
+		return 0, err
+	}
+	if err = scope.checkUpdates(updates); err != nil {
+		return 0, err
+	}
+	if updates, err = scope.stampUpdates(updates); err != nil {
+		return 0, err
+	}
+	setClause, args, err := buildSetClause(options, collection, updates)
+	if err != nil {
+		return 0, err
+	}
+	condition, conditionArgs, err := buildCondition(where)
+	if err != nil {
+		return 0, fmt.Errorf("failed to build where condition for %s: %w", collection, err)
+	}
+	qry := query{
+		text: fmt.Sprintf("UPDATE %v SET%s\n\tWHERE %s", scope.table, setClause, scope.whereClause(condition)),
+		args: append(append(args, conditionArgs...), scope.args...),
+	}
+	qry.text = options.Placeholder.rewritePlaceholders(qry.text)
+	result, err := options.traceStatement(collection, execStatement)(ctx, qry.text, qry.args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update records of %s: %w", collection, err)
+	}
+	count, err := result.RowsAffected()
+	options.count(ctx, collection, CounterUpdates, count)
+	return count, err
+}
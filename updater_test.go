@@ -2,6 +2,8 @@ package dalgo2sql
 
 import (
 	"context"
+	"errors"
+	"reflect"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
@@ -70,6 +72,98 @@ func TestUpdater(t *testing.T) {
 			t.Errorf("unexpected error: %v", err)
 		}
 	})
+
+	t.Run("Update_multiple_fields", func(t *testing.T) {
+		sqlDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer closeDatabase(t, sqlDB)
+
+		db := dal.BackendOf(NewDatabase(sqlDB, newSchema(), DbOptions{
+			Recordsets: map[string]*Recordset{
+				"users": NewRecordset("users", Table, []dal.FieldRef{dal.Field("ID")}),
+			},
+			Placeholder: PlaceholderDollar,
+		})).(*database)
+
+		updates := []update.Update{
+			update.ByFieldName("Name", "n"),
+			update.ByFieldName("Age", 42),
+		}
+		mock.ExpectExec("UPDATE users SET\n\tName = $1,\n\tAge = $2\n\tWHERE ID = $3").
+			WithArgs("n", 42, "id1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		if err = db.Update(ctx, dalrecord.NewKeyWithID("users", "id1"), updates); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
+
+func TestUpdateWhere(t *testing.T) {
+	ctx := context.Background()
+	where := dal.Comparison{Operator: dal.LessThen, Left: dal.Field("CreatedAt"), Right: dal.Constant{Value: 100}}
+
+	t.Run("database", func(t *testing.T) {
+		sqlDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer closeDatabase(t, sqlDB)
+		db := dal.BackendOf(NewDatabase(sqlDB, newSchema(), DbOptions{})).(*database)
+
+		mock.ExpectExec("UPDATE orders SET\n\tArchived = ?\n\tWHERE CreatedAt < ?").
+			WithArgs(true, 100).
+			WillReturnResult(sqlmock.NewResult(0, 3))
+
+		count, err := db.UpdateWhere(ctx, "orders", where, []update.Update{update.ByFieldName("Archived", true)})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if count != 3 {
+			t.Errorf("count = %d, want 3", count)
+		}
+	})
+
+	t.Run("transaction", func(t *testing.T) {
+		sqlDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer closeDatabase(t, sqlDB)
+		db := dal.BackendOf(NewDatabase(sqlDB, newSchema(), DbOptions{Placeholder: PlaceholderDollar}))
+
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE orders SET\n\tArchived = $1\n\tWHERE CreatedAt < $2").
+			WithArgs(true, 100).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectCommit()
+
+		var count int64
+		err = db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx dal.ReadwriteTransaction) (err error) {
+			count, err = tx.(SetMutator).UpdateWhere(ctx, "orders", where, []update.Update{update.ByFieldName("Archived", true)})
+			return err
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if count != 2 {
+			t.Errorf("count = %d, want 2", count)
+		}
+	})
+
+	t.Run("nil_condition", func(t *testing.T) {
+		if _, err := updateWhere(ctx, DbOptions{}, nil, "orders", nil, []update.Update{update.ByFieldName("Archived", true)}); err == nil {
+			t.Error("expected error for nil condition")
+		}
+	})
+
+	t.Run("no_updates", func(t *testing.T) {
+		if _, err := updateWhere(ctx, DbOptions{}, nil, "orders", where, nil); err == nil {
+			t.Error("expected error for empty updates")
+		}
+	})
 }
 
 func TestUpserter(t *testing.T) {
@@ -100,3 +194,168 @@ func TestUpserter(t *testing.T) {
 		}
 	})
 }
+
+func TestBuildSetClause(t *testing.T) {
+	tests := []struct {
+		name     string
+		dialect  Dialect
+		updates  []update.Update
+		wantText string
+		wantArgs []any
+		wantErr  error
+	}{
+		{
+			name:     "increment",
+			updates:  []update.Update{update.ByFieldName("Visits", Increment(2))},
+			wantText: "\n\tVisits = Visits + ?",
+			wantArgs: []any{2},
+		},
+		{
+			name:     "delete_field",
+			updates:  []update.Update{update.DeleteByFieldName("Nickname")},
+			wantText: "\n\tNickname = NULL",
+		},
+		{
+			name:     "server_timestamp",
+			updates:  []update.Update{update.ByFieldName("UpdatedAt", update.ServerTimestamp)},
+			wantText: "\n\tUpdatedAt = CURRENT_TIMESTAMP",
+		},
+		{
+			name:     "single_segment_path",
+			updates:  []update.Update{update.ByFieldPath(update.FieldPath{"Name"}, "x")},
+			wantText: "\n\tName = ?",
+			wantArgs: []any{"x"},
+		},
+		{
+			name:     "nested_path_sqlite",
+			dialect:  DialectSQLite,
+			updates:  []update.Update{update.ByFieldName("Profile.Address.City", "Dublin")},
+			wantText: "\n\tProfile = json_set(COALESCE(Profile, '{}'), '$.Address.City', json(?))",
+			wantArgs: []any{`"Dublin"`},
+		},
+		{
+			name:     "nested_path_postgres",
+			dialect:  DialectPostgres,
+			updates:  []update.Update{update.ByFieldPath(update.FieldPath{"Profile", "Age"}, 42)},
+			wantText: "\n\tProfile = jsonb_set(COALESCE(Profile, '{}'::jsonb), '{Age}', ?::jsonb, true)",
+			wantArgs: []any{"42"},
+		},
+		{
+			name:     "nested_path_mysql",
+			dialect:  DialectMySQL,
+			updates:  []update.Update{update.ByFieldPath(update.FieldPath{"Profile", "Tags"}, []string{"a"})},
+			wantText: "\n\tProfile = JSON_SET(COALESCE(Profile, JSON_OBJECT()), '$.Tags', CAST(? AS JSON))",
+			wantArgs: []any{`["a"]`},
+		},
+		{
+			name:     "nested_delete_sqlite",
+			dialect:  DialectSQLite,
+			updates:  []update.Update{update.DeleteByFieldPath("Profile", "Age")},
+			wantText: "\n\tProfile = json_remove(Profile, '$.Age')",
+		},
+		{
+			name:     "nested_delete_postgres",
+			dialect:  DialectPostgres,
+			updates:  []update.Update{update.DeleteByFieldPath("Profile", "Address", "City")},
+			wantText: "\n\tProfile = (Profile) #- '{Address,City}'",
+		},
+		{
+			name:    "nested_path_generic_dialect",
+			updates: []update.Update{update.ByFieldName("Profile.Age", 1)},
+			wantErr: dal.ErrNotSupported,
+		},
+		{
+			name:    "nested_increment",
+			dialect: DialectSQLite,
+			updates: []update.Update{update.ByFieldName("Stats.Visits", Increment(1))},
+			wantErr: dal.ErrNotSupported,
+		},
+		{
+			name:    "nested_server_timestamp",
+			dialect: DialectSQLite,
+			updates: []update.Update{update.ByFieldName("Stats.SeenAt", update.ServerTimestamp)},
+			wantErr: dal.ErrNotSupported,
+		},
+		{
+			name:    "nested_same_column_composed",
+			dialect: DialectSQLite,
+			updates: []update.Update{
+				update.ByFieldName("Profile.City", "Dublin"),
+				update.DeleteByFieldPath("Profile", "Age"),
+			},
+			wantText: "\n\tProfile = json_remove(json_set(COALESCE(Profile, '{}'), '$.City', json(?)), '$.Age')",
+			wantArgs: []any{`"Dublin"`},
+		},
+		{
+			name:     "multiple",
+			updates:  []update.Update{update.ByFieldName("A", 1), update.DeleteByFieldName("B")},
+			wantText: "\n\tA = ?,\n\tB = NULL",
+			wantArgs: []any{1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, args, err := buildSetClause(DbOptions{Dialect: tt.dialect}, "users", tt.updates)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if text != tt.wantText {
+				t.Errorf("text = %q, want %q", text, tt.wantText)
+			}
+			if len(args) != len(tt.wantArgs) || (len(args) > 0 && !reflect.DeepEqual(args, tt.wantArgs)) {
+				t.Errorf("args = %v, want %v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestBuildSetClause_rejectsInvalidColumns(t *testing.T) {
+	for _, u := range []update.Update{
+		update.ByFieldName("Name = 'x', Admin", true),
+		update.ByFieldPath([]string{"Profile; DROP TABLE users", "City"}, "Dublin"),
+	} {
+		if _, _, err := buildSetClause(DbOptions{Dialect: DialectSQLite}, "users", []update.Update{u}); err == nil {
+			t.Errorf("expected an error for the update of %v", u.FieldPath())
+		}
+	}
+}
+
+func TestUpdate_NestedFieldPath_SQLite(t *testing.T) {
+	ctx := context.Background()
+	sqlDB := openTestSQLiteDB(t, `CREATE TABLE users (ID TEXT PRIMARY KEY, Visits INTEGER, Profile TEXT)`)
+	if _, err := sqlDB.Exec(`INSERT INTO users (ID, Visits, Profile) VALUES ('u1', 1, '{"Age":30}')`); err != nil {
+		t.Fatal(err)
+	}
+	db := dal.BackendOf(NewDatabase(sqlDB, newSchema(), DbOptions{
+		Dialect: DialectSQLite,
+		Recordsets: map[string]*Recordset{
+			"users": NewRecordset("users", Table, []dal.FieldRef{dal.Field("ID")}),
+		},
+	})).(*database)
+
+	err := db.Update(ctx, dalrecord.NewKeyWithID("users", "u1"), []update.Update{
+		update.ByFieldName("Visits", Increment(2)),
+		update.ByFieldName("Profile.City", "Dublin"),
+		update.DeleteByFieldPath("Profile", "Age"),
+	})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	var visits int
+	var profile string
+	if err = sqlDB.QueryRow("SELECT Visits, Profile FROM users WHERE ID = 'u1'").Scan(&visits, &profile); err != nil {
+		t.Fatal(err)
+	}
+	if visits != 3 {
+		t.Errorf("Visits = %d, want 3", visits)
+	}
+	if profile != `{"City":"Dublin"}` {
+		t.Errorf("Profile = %s, want {\"City\":\"Dublin\"}", profile)
+	}
+}
@@ -0,0 +1,450 @@
+package dalgo2sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/dal-go/record"
+	"github.com/dal-go/record/update"
+)
+
+type bufferedWritesContextKey struct{}
+
+// WithBufferedWrites returns a context that makes RunReadwriteTransaction
+// buffer the Insert, Set, Update and Delete writes of its transaction and
+// their Multi variants instead of executing them right away.
+//
+// Buffered writes are coalesced per key: an insert or a set followed by
+// updates is written as a single insert or set, an insert followed by a
+// delete is not written at all, and a later set or delete replaces earlier
+// writes. They are flushed in the order their keys were first written,
+// with consecutive inserts into a table batched into multi-row INSERT
+// statements and consecutive deletes into DELETE ... IN statements, before
+// any other statement of the transaction, e.g. a read that could observe
+// them, and before commit. Errors of buffered writes are returned by the
+// call that flushes them.
+//
+// Writes are executed right away if they can't be buffered: inserts with
+// an ID to generate, writes of recordsets WithHistory or WithChangelog,
+// writes with an idempotency key and updates with an expected version.
+func WithBufferedWrites(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bufferedWritesContextKey{}, true)
+}
+
+func bufferedWrites(ctx context.Context) bool {
+	buffered, _ := ctx.Value(bufferedWritesContextKey{}).(bool)
+	return buffered
+}
+
+type bufferedOp int
+
+const (
+	bufferedNone bufferedOp = iota // coalesced away
+	bufferedInsert
+	bufferedSet
+	bufferedUpdate
+	bufferedDelete
+)
+
+// bufferedWrite is the coalesced write of a key.
+type bufferedWrite struct {
+	// ctx is the context of the write that decided the operation.
+	ctx    context.Context
+	op     bufferedOp
+	key    *record.Key
+	record record.Record
+	// updates are the updates of an update, or the updates applied to the
+	// record of an insert or a set.
+	updates []update.Update
+}
+
+// writeBuffer holds the buffered writes of a transaction and the executors
+// of the transaction they are flushed with.
+type writeBuffer struct {
+	options    DbOptions
+	identities *identityMap
+	query      queryExecutor
+	exec       statementExecutor
+	writes     []*bufferedWrite
+	pending    map[string]*bufferedWrite
+}
+
+// buffered returns the transaction with a write buffer that is flushed
+// before any other statement of the transaction is executed.
+func (t transaction) buffered() transaction {
+	b := &writeBuffer{options: t.sqlOptions, identities: t.identities, query: t.query, exec: t.exec, pending: make(map[string]*bufferedWrite)}
+	t.buffer = b
+	t.query = func(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+		if err := b.flush(); err != nil {
+			return nil, err
+		}
+		return b.query(ctx, query, args...)
+	}
+	t.exec = func(ctx context.Context, query string, args ...any) (sql.Result, error) {
+		if err := b.flush(); err != nil {
+			return nil, err
+		}
+		return b.exec(ctx, query, args...)
+	}
+	executeQuery := t.executeQuery
+	t.executeQuery = func(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+		if err := b.flush(); err != nil {
+			return nil, err
+		}
+		return executeQuery(ctx, query, args...)
+	}
+	return t
+}
+
+// accepts reports whether writes of the keys made with the context can be buffered.
+func (b *writeBuffer) accepts(ctx context.Context, keys ...*record.Key) bool {
+	if b == nil {
+		return false
+	}
+	if _, ok := IdempotencyKeyFromContext(ctx); ok {
+		return false
+	}
+	if _, ok := expectedVersion(ctx); ok {
+		return false
+	}
+	for _, key := range keys {
+		if key.ID == nil || b.options.tracksChanges([]string{key.Collection()}) {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *writeBuffer) add(ctx context.Context, op bufferedOp, key *record.Key, r record.Record, updates []update.Update) error {
+	b.identities.forgetKeys(ctx, key)
+	id := rowKey(ctx, key)
+	if w := b.pending[id]; w != nil {
+		coalesced, err := b.coalesce(w, ctx, op, r, updates)
+		if err != nil || coalesced {
+			return err
+		}
+		if err = b.flush(); err != nil {
+			return err
+		}
+	}
+	w := &bufferedWrite{ctx: ctx, op: op, key: key, record: r, updates: updates}
+	b.writes = append(b.writes, w)
+	b.pending[id] = w
+	return nil
+}
+
+// coalesce merges a write into the buffered write of its key and reports
+// whether it could, otherwise the buffered write has to be flushed first.
+func (b *writeBuffer) coalesce(w *bufferedWrite, ctx context.Context, op bufferedOp, r record.Record, updates []update.Update) (bool, error) {
+	switch op {
+	case bufferedUpdate:
+		switch w.op {
+		case bufferedInsert, bufferedSet:
+			if !b.assignable(w.key, updates) {
+				return false, nil
+			}
+			// Reject what Update would reject, e.g. updates of the tenant column.
+			scope, err := b.options.scope(ctx, getRecordsetName(w.key))
+			if err != nil {
+				return false, err
+			}
+			if err = scope.checkUpdates(updates); err != nil {
+				return false, err
+			}
+			if _, err = scope.stampUpdates(updates); err != nil {
+				return false, err
+			}
+		case bufferedUpdate:
+			if !mergeableUpdates(w.updates, updates) {
+				return false, nil
+			}
+		default:
+			return false, nil
+		}
+		w.updates = append(slices.Clip(w.updates), updates...)
+	case bufferedSet:
+		switch w.op {
+		case bufferedInsert:
+			w.record, w.updates = r, nil
+		case bufferedSet, bufferedUpdate:
+			w.ctx, w.op, w.record, w.updates = ctx, bufferedSet, r, nil
+		default:
+			return false, nil
+		}
+	case bufferedDelete:
+		if w.op == bufferedInsert {
+			w.op = bufferedNone
+			delete(b.pending, rowKey(ctx, w.key))
+			return true, nil
+		}
+		w.ctx, w.op, w.record, w.updates = ctx, bufferedDelete, nil, nil
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// assignable reports whether updates can be written by the insert or set
+// of a record, as plain values of valid columns other than the primary key.
+// Updates of invalid columns are left to Update to reject.
+func (b *writeBuffer) assignable(key *record.Key, updates []update.Update) bool {
+	pk := b.options.PrimaryKeyFieldNames(key)
+	for _, u := range updates {
+		column := u.FieldName()
+		if path := u.FieldPath(); len(path) > 1 {
+			return false
+		} else if len(path) == 1 {
+			column = path[0]
+		}
+		if validateIdentifier(column) != nil {
+			return false
+		}
+		if _, isIncrement := u.Value().(increment); isIncrement || slices.Contains(pk, column) {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeableUpdates reports whether later updates can be written by the
+// statement of earlier ones, which is not the case for increments of
+// columns the earlier updates assign.
+func mergeableUpdates(earlier, later []update.Update) bool {
+	column := func(u update.Update) string {
+		if path := u.FieldPath(); len(path) > 0 {
+			return path[0]
+		}
+		return u.FieldName()
+	}
+	for _, u := range later {
+		if _, isIncrement := u.Value().(increment); !isIncrement {
+			continue
+		}
+		if slices.ContainsFunc(earlier, func(e update.Update) bool { return column(e) == column(u) }) {
+			return false
+		}
+	}
+	return true
+}
+
+type recordUpdatesContextKey struct{}
+
+// recordUpdates are updates coalesced into the insert or set of a record.
+type recordUpdates struct {
+	recordset string
+	updates   []update.Update
+}
+
+// applyRecordUpdates makes writes of records of the recordset assign the
+// columns of the updates coalesced into them instead of the record values.
+func applyRecordUpdates(ctx context.Context, recordset string, s *statementScope) {
+	ru, ok := ctx.Value(recordUpdatesContextKey{}).(recordUpdates)
+	if !ok || ru.recordset != recordset {
+		return
+	}
+	scoped := len(s.stamps)
+	for _, u := range ru.updates {
+		column := u.FieldName()
+		if path := u.FieldPath(); len(path) == 1 {
+			column = path[0]
+		}
+		value := u.Value()
+		if value == nil {
+			value = update.DeleteField
+		}
+		st := stamp{column: column, onInsert: value, onUpdate: value}
+		// Later updates of a column win, columns stamped by the scope,
+		// e.g. audit columns, win over updates as in Update.
+		if i := slices.IndexFunc(s.stamps[scoped:], func(st stamp) bool { return strings.EqualFold(st.column, column) }); i >= 0 {
+			s.stamps[scoped+i] = st
+		} else if !s.ignores(insertOperation, column) {
+			s.stamps = append(s.stamps, st)
+		}
+	}
+}
+
+// writeCtx returns the context a buffered write is flushed with.
+func (w *bufferedWrite) writeCtx() context.Context {
+	if len(w.updates) == 0 || w.op == bufferedUpdate {
+		return w.ctx
+	}
+	return context.WithValue(w.ctx, recordUpdatesContextKey{}, recordUpdates{recordset: getRecordsetName(w.key), updates: w.updates})
+}
+
+// flush executes the buffered writes. The buffer is emptied first,
+// so writes that fail are not retried by a later flush.
+func (b *writeBuffer) flush() error {
+	if b == nil || len(b.writes) == 0 {
+		return nil
+	}
+	writes := slices.DeleteFunc(b.writes, func(w *bufferedWrite) bool { return w.op == bufferedNone })
+	b.writes = nil
+	clear(b.pending)
+	for i := 0; i < len(writes); {
+		w := writes[i]
+		// Consecutive writes of a collection in a tenant are batched.
+		tenantID, _ := TenantFromContext(w.ctx)
+		n := 1
+		for ; i+n < len(writes); n++ {
+			next := writes[i+n]
+			nextTenantID, _ := TenantFromContext(next.ctx)
+			if next.op != w.op || next.key.Collection() != w.key.Collection() || nextTenantID != tenantID {
+				break
+			}
+		}
+		batch := writes[i : i+n]
+		var err error
+		switch w.op {
+		case bufferedInsert:
+			err = b.insertBatch(batch)
+		case bufferedSet:
+			for _, w := range batch {
+				if err = setSingle(w.writeCtx(), b.options, w.record, b.query, b.exec); err != nil {
+					break
+				}
+			}
+		case bufferedUpdate:
+			for _, w := range batch {
+				if err = updateSingle(w.ctx, b.options, b.exec, w.key, w.updates); err != nil {
+					break
+				}
+			}
+		case bufferedDelete:
+			err = b.deleteBatch(batch)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to flush buffered writes: %w", err)
+		}
+		i += n
+	}
+	return nil
+}
+
+// insertBatch inserts records with multi-row INSERT statements, or one by
+// one if the recordset needs statements per record, e.g. WithExpiry.
+func (b *writeBuffer) insertBatch(batch []*bufferedWrite) error {
+	options := b.options
+	collection := getRecordsetName(batch[0].key)
+	if rs := options.Recordsets[collection]; rs != nil && (rs.expiry != "" || rs.softDelete != nil && rs.softDelete.OnInsert == SoftDeletedInsertRestores) {
+		for _, w := range batch {
+			if err := execInsert(w.writeCtx(), options, w.record, b.exec); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	type row struct {
+		w            *bufferedWrite
+		table        string
+		cols         []string
+		placeholders string
+		args         []any
+	}
+	rows := make([]row, len(batch))
+	for i, w := range batch {
+		ctx := w.writeCtx()
+		scope, err := options.scope(ctx, collection)
+		if err != nil {
+			return err
+		}
+		cols, placeholders, args, err := scopedRecordValues(insertOperation, options, scope, w.record)
+		if err != nil {
+			return err
+		}
+		rows[i] = row{w: w, table: scope.table, cols: cols, placeholders: "(" + strings.Join(placeholders, ", ") + ")", args: args}
+	}
+	for start := 0; start < len(rows); {
+		first := rows[start]
+		perStatement := options.keysPerStatement(len(first.args))
+		end := start + 1
+		for end < len(rows) && end-start < perStatement && rows[end].table == first.table &&
+			rows[end].placeholders == first.placeholders && slices.Equal(rows[end].cols, first.cols) {
+			end++
+		}
+		values := make([]string, 0, end-start)
+		var args []any
+		for _, r := range rows[start:end] {
+			values = append(values, r.placeholders)
+			args = append(args, r.args...)
+		}
+		text := options.Placeholder.rewritePlaceholders(fmt.Sprintf("INSERT INTO %v(%v) VALUES %v",
+			first.table, strings.Join(first.cols, ", "), strings.Join(values, ", ")))
+		ctx := first.w.ctx
+		if _, err := options.traceStatement(collection, b.exec)(ctx, text, args...); err != nil {
+			if isUniqueViolation(err) {
+				options.count(ctx, collection, CounterConflicts, 1)
+			}
+			return err
+		}
+		options.count(ctx, collection, CounterInserts, int64(end-start))
+		for _, r := range rows[start:end] {
+			setRecordVersion(r.w.record, options.versionColumn(collection), 1)
+		}
+		start = end
+	}
+	return nil
+}
+
+// deleteBatch deletes the keys of a collection with DELETE ... IN statements.
+// Consecutive keys are deleted together while their contexts make the same
+// statement, e.g. with the same filters and soft-delete mode.
+func (b *writeBuffer) deleteBatch(batch []*bufferedWrite) error {
+	collection := getRecordsetName(batch[0].key)
+	for start := 0; start < len(batch); {
+		ctx := batch[start].ctx
+		shape, err := b.deleteShapeOf(ctx, collection)
+		if err != nil {
+			return err
+		}
+		keys := []*record.Key{batch[start].key}
+		end := start + 1
+		for ; end < len(batch); end++ {
+			next, err := b.deleteShapeOf(batch[end].ctx, collection)
+			if err != nil {
+				return err
+			}
+			if !reflect.DeepEqual(next, shape) {
+				break
+			}
+			keys = append(keys, batch[end].key)
+		}
+		if err = deleteMulti(ctx, b.options, keys, b.exec); err != nil {
+			return err
+		}
+		start = end
+	}
+	return nil
+}
+
+// deleteShape is what the context of a delete decides about its statement.
+type deleteShape struct {
+	operation Operation
+	column    string
+	where     string
+	args      []any
+}
+
+func (b *writeBuffer) deleteShapeOf(ctx context.Context, collection string) (deleteShape, error) {
+	scope, err := b.options.scope(ctx, collection)
+	if err != nil {
+		return deleteShape{}, err
+	}
+	return deleteShape{
+		operation: operationFromContext(ctx),
+		column:    b.options.softDeleteColumn(ctx, collection),
+		where:     scope.shape(),
+		args:      scope.args,
+	}, nil
+}
+
+func recordKeys(records []record.Record) []*record.Key {
+	keys := make([]*record.Key, len(records))
+	for i, r := range records {
+		keys[i] = r.Key()
+	}
+	return keys
+}
@@ -0,0 +1,286 @@
+package dalgo2sql
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dal-go/dalgo/dal"
+	dalrecord "github.com/dal-go/record"
+	"github.com/dal-go/record/update"
+)
+
+func TestBufferedWrites_statements(t *testing.T) {
+	type item struct {
+		Name string
+	}
+	newItem := func(id, name string) dalrecord.Record {
+		return dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("items", id), &item{Name: name})
+	}
+	ctx := WithBufferedWrites(context.Background())
+
+	t.Run("coalesced_inserts", func(t *testing.T) {
+		sqlDB, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer closeDatabase(t, sqlDB)
+		db := NewDatabase(sqlDB, newSchema(), DbOptions{})
+		mock.ExpectBegin()
+		mock.ExpectExec(`INSERT INTO items\(ID, Name\) VALUES \(\?, \?\), \(\?, \?\)`).
+			WithArgs("a", "A2", "b", "B").
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectCommit()
+		err = db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx dal.ReadwriteTransaction) error {
+			if err := tx.InsertMulti(ctx, []dalrecord.Record{newItem("a", "A"), newItem("b", "B"), newItem("c", "C")}); err != nil {
+				return err
+			}
+			if err := tx.Update(ctx, dalrecord.NewKeyWithID("items", "a"), []update.Update{update.ByFieldName("Name", "A2")}); err != nil {
+				return err
+			}
+			return tx.Delete(ctx, dalrecord.NewKeyWithID("items", "c"))
+		})
+		if err != nil {
+			t.Fatalf("RunReadwriteTransaction: %v", err)
+		}
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("merged_updates_and_deletes", func(t *testing.T) {
+		sqlDB, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer closeDatabase(t, sqlDB)
+		db := NewDatabase(sqlDB, newSchema(), DbOptions{})
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE items SET\s+Name = \?,\s+Hits = Hits \+ \?\s+WHERE ID = \?`).
+			WithArgs("A", 1, "a").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`DELETE FROM items WHERE ID IN \(\?, \?\)`).
+			WithArgs("b", "c").
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectCommit()
+		err = db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx dal.ReadwriteTransaction) error {
+			key := dalrecord.NewKeyWithID("items", "a")
+			if err := tx.Update(ctx, key, []update.Update{update.ByFieldName("Name", "A")}); err != nil {
+				return err
+			}
+			if err := tx.Update(ctx, key, []update.Update{update.ByFieldName("Hits", Increment(1))}); err != nil {
+				return err
+			}
+			if err := tx.Update(ctx, dalrecord.NewKeyWithID("items", "b"), []update.Update{update.ByFieldName("Name", "B")}); err != nil {
+				return err
+			}
+			return tx.DeleteMulti(ctx, []*dalrecord.Key{dalrecord.NewKeyWithID("items", "b"), dalrecord.NewKeyWithID("items", "c")})
+		})
+		if err != nil {
+			t.Fatalf("RunReadwriteTransaction: %v", err)
+		}
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("increments_are_not_merged", func(t *testing.T) {
+		sqlDB, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer closeDatabase(t, sqlDB)
+		db := NewDatabase(sqlDB, newSchema(), DbOptions{})
+		mock.ExpectBegin()
+		for range 2 {
+			mock.ExpectExec(`UPDATE items SET\s+Hits = Hits \+ \?\s+WHERE ID = \?`).
+				WithArgs(1, "a").
+				WillReturnResult(sqlmock.NewResult(0, 1))
+		}
+		mock.ExpectCommit()
+		err = db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx dal.ReadwriteTransaction) error {
+			key := dalrecord.NewKeyWithID("items", "a")
+			for range 2 {
+				if err := tx.Update(ctx, key, []update.Update{update.ByFieldName("Hits", Increment(1))}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("RunReadwriteTransaction: %v", err)
+		}
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("invalid_columns_are_not_coalesced", func(t *testing.T) {
+		sqlDB, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer closeDatabase(t, sqlDB)
+		db := NewDatabase(sqlDB, newSchema(), DbOptions{})
+		mock.ExpectBegin()
+		mock.ExpectExec(`INSERT INTO items\(ID, Name\) VALUES \(\?, \?\)`).
+			WithArgs("a", "A").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectRollback()
+		err = db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx dal.ReadwriteTransaction) error {
+			if err := tx.Insert(ctx, newItem("a", "A")); err != nil {
+				return err
+			}
+			return tx.Update(ctx, dalrecord.NewKeyWithID("items", "a"), []update.Update{update.ByFieldName("Name = 'x', Admin", true)})
+		})
+		if err == nil {
+			t.Fatal("expected the update of an invalid column to fail")
+		}
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("deletes_split_by_context", func(t *testing.T) {
+		sqlDB, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer closeDatabase(t, sqlDB)
+		now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		db := NewDatabase(sqlDB, newSchema(), DbOptions{
+			Clock: func() time.Time { return now },
+			Recordsets: map[string]*Recordset{
+				"items": NewRecordset("items", Table, []dal.FieldRef{dal.Field("ID")}, WithSoftDelete(SoftDelete{})),
+			},
+		})
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE items SET deleted_at = \? WHERE ID IN \(\?, \?\) AND deleted_at IS NULL$`).
+			WithArgs(now, "a", "b").
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec(`UPDATE items SET deleted_at = \? WHERE ID = \?$`).
+			WithArgs(now, "c").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		err = db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx dal.ReadwriteTransaction) error {
+			if err := tx.Delete(ctx, dalrecord.NewKeyWithID("items", "a")); err != nil {
+				return err
+			}
+			if err := tx.Delete(ctx, dalrecord.NewKeyWithID("items", "b")); err != nil {
+				return err
+			}
+			return tx.Delete(WithoutFilters(ctx, SoftDeleteFilter), dalrecord.NewKeyWithID("items", "c"))
+		})
+		if err != nil {
+			t.Fatalf("RunReadwriteTransaction: %v", err)
+		}
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("not_buffered_by_default", func(t *testing.T) {
+		sqlDB, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer closeDatabase(t, sqlDB)
+		db := NewDatabase(sqlDB, newSchema(), DbOptions{})
+		mock.ExpectBegin()
+		mock.ExpectExec(`INSERT INTO items\(ID, Name\) VALUES \(\?, \?\)`).
+			WithArgs("a", "A").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`DELETE FROM items WHERE ID = \?`).
+			WithArgs("a").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		err = db.RunReadwriteTransaction(context.Background(), func(ctx context.Context, tx dal.ReadwriteTransaction) error {
+			if err := tx.Insert(ctx, newItem("a", "A")); err != nil {
+				return err
+			}
+			return tx.Delete(ctx, dalrecord.NewKeyWithID("items", "a"))
+		})
+		if err != nil {
+			t.Fatalf("RunReadwriteTransaction: %v", err)
+		}
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestBufferedWrites_SQLite(t *testing.T) {
+	type item struct {
+		Name string
+		Hits int
+	}
+	sqlDB := openTestSQLiteDB(t, `CREATE TABLE items (ID TEXT PRIMARY KEY, Name TEXT, Hits INTEGER NOT NULL DEFAULT 0);`)
+	db := NewDatabase(sqlDB, newSchema(), DbOptions{})
+	ctx := WithBufferedWrites(context.Background())
+	get := func(t *testing.T, id string) (got item, exists bool) {
+		t.Helper()
+		r := dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("items", id), &got)
+		err := db.Get(context.Background(), r)
+		if err != nil && !dalrecord.IsNotFound(err) {
+			t.Fatalf("Get: %v", err)
+		}
+		return got, r.Exists()
+	}
+
+	t.Run("flushed_before_reads", func(t *testing.T) {
+		err := db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx dal.ReadwriteTransaction) error {
+			if err := tx.Insert(ctx, dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("items", "i1"), &item{Name: "one"})); err != nil {
+				return err
+			}
+			if err := tx.Update(ctx, dalrecord.NewKeyWithID("items", "i1"), []update.Update{update.ByFieldName("Hits", 5)}); err != nil {
+				return err
+			}
+			var got item
+			if err := tx.Get(ctx, dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("items", "i1"), &got)); err != nil {
+				return err
+			}
+			if got != (item{Name: "one", Hits: 5}) {
+				t.Errorf("got %+v in the transaction, want the buffered writes", got)
+			}
+			return tx.Update(ctx, dalrecord.NewKeyWithID("items", "i1"), []update.Update{update.ByFieldName("Hits", Increment(1))})
+		})
+		if err != nil {
+			t.Fatalf("RunReadwriteTransaction: %v", err)
+		}
+		if got, _ := get(t, "i1"); got != (item{Name: "one", Hits: 6}) {
+			t.Errorf("got %+v, want the writes flushed at commit", got)
+		}
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		err := db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx dal.ReadwriteTransaction) error {
+			if err := tx.Set(ctx, dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("items", "i2"), &item{Name: "two"})); err != nil {
+				return err
+			}
+			return context.Canceled
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("RunReadwriteTransaction = %v, want context.Canceled", err)
+		}
+		if _, exists := get(t, "i2"); exists {
+			t.Error("want the buffered write of a failed transaction to be discarded")
+		}
+	})
+
+	t.Run("error_at_commit", func(t *testing.T) {
+		err := db.RunReadwriteTransaction(ctx, func(ctx context.Context, tx dal.ReadwriteTransaction) error {
+			if err := tx.Insert(ctx, dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("items", "i3"), &item{Name: "three"})); err != nil {
+				return err
+			}
+			return tx.Insert(ctx, dalrecord.NewRecordWithData(dalrecord.NewKeyWithID("items", "i1"), &item{Name: "duplicate"}))
+		})
+		if err == nil {
+			t.Fatal("expected the buffered insert of an existing key to fail the commit")
+		}
+		if _, exists := get(t, "i3"); exists {
+			t.Error("want the transaction rolled back")
+		}
+	})
+}